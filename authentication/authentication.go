@@ -2,23 +2,43 @@ package authentication
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/batt0s/batnovels/database"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// Kullanıcı bulunamadığında da bir hash karşılaştırılır, cevap süresinden kullanıcı adının var olduğu anlaşılmasın.
+// Maliyeti User.SetPassword ile aynı olmalı.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("batnovels"), bcrypt.DefaultCost)
+
+// Kullanıcı yoksa da şifre yanlışsa da ErrorInvalidCredentials döner. Şifre yanlışsa kullanıcı da döner,
+// denemeyi kaydetmek için kullanılır ama istemciye gösterilmemeli.
 func Authenticate(username, passwd string, users database.UserRepo) (database.User, error) {
 	var user database.User
 	var err error
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	user, err = users.FindByUsername(ctx, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(passwd))
+		return user, ErrorInvalidCredentials
+	}
 	if err != nil {
 		return user, err
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwd))
-	if err != nil {
-		return user, ErrorIncorrectPassword
+	if CheckPassword(user, passwd) != nil {
+		return user, ErrorInvalidCredentials
+	}
+	return user, nil
+}
+
+// Giriş yapmış kullanıcıdan hassas işlemler için şifresini tekrar istemek için
+func CheckPassword(user database.User, passwd string) error {
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwd)) != nil {
+		return ErrorIncorrectPassword
 	}
-	return user, err
+	return nil
 }
@@ -3,5 +3,6 @@ package authentication
 import "errors"
 
 var (
-	ErrorIncorrectPassword = errors.New("given password for given username is incorrect")
+	ErrorIncorrectPassword  = errors.New("given password for given username is incorrect")
+	ErrorInvalidCredentials = errors.New("invalid username or password")
 )
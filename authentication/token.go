@@ -0,0 +1,71 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// Refresh token ve özeti. Veritabanında sadece özet tutulur.
+func NewRefreshToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// Kişisel API anahtarı, listede gösterilecek ön eki ve özeti. Ön ek anahtarın bir parçası olduğu için
+// tahmin etmeye yaramaz, kullanıcının anahtarlarını ayırt etmesi içindir.
+func NewAPIKey() (string, string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key := "bn_" + base64.RawURLEncoding.EncodeToString(buf)
+	return key, key[:11], HashToken(key), nil
+}
+
+// Token'lar yüksek entropili olduğu için tuzsuz sha256 yeterli
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// E-postayla gönderilen tek kullanımlık token. "id.imza" biçimindedir, imza amaç ve binding'i de
+// kapsar. Binding e-posta ya da şifre özeti olduğu için bunlar değişince eski linkler geçersizleşir.
+func NewSignedToken(secret []byte, purpose string, binding string) (string, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(buf)
+	return id + "." + signToken(secret, purpose, id, binding), id, nil
+}
+
+// İmzayı kontrol etmeden id'yi döner, kayıt bulunduktan sonra CheckSignedToken çağrılmalı
+func SignedTokenID(token string) (string, bool) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || id == "" || sig == "" {
+		return "", false
+	}
+	return id, true
+}
+
+func CheckSignedToken(secret []byte, purpose string, token string, binding string) bool {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signToken(secret, purpose, id, binding)))
+}
+
+func signToken(secret []byte, purpose string, id string, binding string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + "\x00" + id + "\x00" + binding))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/mailer"
+	"gorm.io/gorm"
+)
+
+const (
+	verifyEmailTTL   = 48 * time.Hour
+	resetPasswordTTL = time.Hour
+	// Aynı adrese bu süre içinde ikinci bir e-posta gönderilmez
+	mailThrottle = time.Minute
+	// bcrypt 72 byte'tan sonrasını yok sayar
+	minPasswordLength = 6
+	maxPasswordLength = 72
+)
+
+type TokenRequestBody struct {
+	Token string `json:"token"`
+}
+
+type PasswordForgotRequestBody struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetRequestBody struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type mailData struct {
+	Name    string
+	Link    string
+	Expires string
+}
+
+// E-postalar isteği bekletmemek için arka planda gönderilir, hatalar sadece log'a yazılır
+func (app *App) sendMail(tmpl *mailer.Template, to string, data mailData) {
+	msg, err := tmpl.Render(to, data)
+	if err != nil {
+		log.Println("[mailer]", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := app.Mailer.Send(ctx, msg); err != nil {
+			log.Println("[mailer]", err)
+		}
+	}()
+}
+
+// Token'ı kaydedip linki e-postayla gönderir. Binding değişirse (e-posta ya da şifre) link geçersizleşir.
+func (app *App) sendToken(user database.User, purpose string, binding string, ttl time.Duration, path string, tmpl *mailer.Template) error {
+	token, id, err := authentication.NewSignedToken([]byte(app.Secret), purpose, binding)
+	if err != nil {
+		return err
+	}
+	_, err = app.Database.Tokens.Create(context.Background(), database.UserToken{
+		ID:        id,
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+	app.sendMail(tmpl, user.Email, mailData{
+		Name:    user.Name,
+		Link:    app.SiteURL + path + "?token=" + url.QueryEscape(token),
+		Expires: formatTTL(ttl),
+	})
+	return nil
+}
+
+func (app *App) sendVerification(user database.User) error {
+	return app.sendToken(user, database.TokenVerifyEmail, user.Email, verifyEmailTTL, "/verify-email", mailer.VerifyEmail)
+}
+
+// Son mailThrottle içinde aynı amaçla token gönderildiyse true döner
+func (app *App) mailThrottled(user database.User, purpose string) (bool, error) {
+	count, err := app.Database.Tokens.CountSince(context.Background(), user.ID, purpose, time.Now().Add(-mailThrottle))
+	return count > 0, err
+}
+
+// Token'ı çözüp kaydını ve kullanıcısını döner. İmza binding'e göre kontrol edildiği için
+// kullanıcı bulunduktan sonra yapılır.
+func (app *App) checkToken(purpose string, token string, binding func(database.User) string) (database.UserToken, database.User, error) {
+	id, ok := authentication.SignedTokenID(token)
+	if !ok {
+		return database.UserToken{}, database.User{}, database.ErrorInvalidToken
+	}
+	record, err := app.Database.Tokens.Find(context.Background(), purpose, id)
+	if err != nil {
+		return record, database.User{}, err
+	}
+	user, err := app.Database.Users.Find(context.Background(), record.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = database.ErrorInvalidToken
+		}
+		return record, user, err
+	}
+	if !authentication.CheckSignedToken([]byte(app.Secret), purpose, token, binding(user)) {
+		return record, user, database.ErrorInvalidToken
+	}
+	return record, user, nil
+}
+
+func sendTokenError(w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrorInvalidToken) {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	} else {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	log.Println(err)
+}
+
+func (app *App) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	body, err := getRequestBody[TokenRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	record, user, err := app.checkToken(database.TokenVerifyEmail, body.Token, func(u database.User) string { return u.Email })
+	if err != nil {
+		sendTokenError(w, err)
+		return
+	}
+	if err := app.Database.Tokens.Use(context.Background(), record); err != nil {
+		sendTokenError(w, err)
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+		if err := app.Database.Users.Update(context.Background(), user); err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Doğrulama e-postasını tekrar gönderir
+func (app *App) VerifyEmailResend(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		sendResponse(w, http.StatusConflict, map[string]string{"error": "email is already verified"})
+		return
+	}
+	throttled, err := app.mailThrottled(user, database.TokenVerifyEmail)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if throttled {
+		w.Header().Set("Retry-After", "60")
+		sendResponse(w, http.StatusTooManyRequests, map[string]string{"error": "please wait before asking for another email"})
+		return
+	}
+	if err := app.sendVerification(user); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Adresin kayıtlı olup olmadığı belli olmasın diye her durumda 200 döner
+func (app *App) PasswordForgot(w http.ResponseWriter, r *http.Request) {
+	body, err := getRequestBody[PasswordForgotRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	email := strings.TrimSpace(body.Email)
+	if email == "" {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "email is required"})
+		return
+	}
+	user, err := app.Database.Users.FindByEmail(context.Background(), email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println(err)
+		}
+		sendResponse(w, http.StatusOK, nil)
+		return
+	}
+	throttled, err := app.mailThrottled(user, database.TokenResetPassword)
+	if err != nil {
+		log.Println(err)
+	}
+	if err == nil && !throttled {
+		if err := app.sendToken(user, database.TokenResetPassword, user.Password, resetPasswordTTL, "/reset-password", mailer.ResetPassword); err != nil {
+			log.Println(err)
+		}
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Şifre değişince bütün oturumlar ve API anahtarları kapatılır. Link e-postaya geldiği için adres de doğrulanmış sayılır.
+func (app *App) PasswordReset(w http.ResponseWriter, r *http.Request) {
+	body, err := getRequestBody[PasswordResetRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if !validPassword(body.Password) {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "password must be between 6 and 72 characters"})
+		return
+	}
+	record, user, err := app.checkToken(database.TokenResetPassword, body.Token, func(u database.User) string { return u.Password })
+	if err != nil {
+		sendTokenError(w, err)
+		return
+	}
+	if err := app.Database.Tokens.Use(context.Background(), record); err != nil {
+		sendTokenError(w, err)
+		return
+	}
+	if err := user.SetPassword(body.Password); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := app.Database.Users.Update(context.Background(), user); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Sessions.RevokeAll(context.Background(), user.ID); err != nil {
+		log.Println(err)
+	}
+	if err := app.Database.APIKeys.RevokeAll(context.Background(), user.ID); err != nil {
+		log.Println(err)
+	}
+	// E-postasına erişebildiğini gösterdi, yanlış denemelerle kilitlenmişse de girebilsin
+	app.resetLoginLock(user.Username)
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) purgeUserTokens(ctx context.Context) {
+	purged, err := app.Database.Tokens.Purge(ctx, time.Now())
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("[scheduler] Purged %d expired email tokens", purged)
+	}
+}
+
+func validPassword(password string) bool {
+	return len(password) >= minPasswordLength && len(password) <= maxPasswordLength
+}
+
+func formatTTL(ttl time.Duration) string {
+	hours := int(ttl / time.Hour)
+	switch {
+	case ttl%time.Hour != 0:
+		return ttl.String()
+	case hours == 1:
+		return "1 hour"
+	default:
+		return strconv.Itoa(hours) + " hours"
+	}
+}
@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"gorm.io/gorm"
+)
+
+const maxAPIKeyDays = 365
+
+type apiKeyCtxKey struct{}
+
+type APIKeyRequestBody struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// 0 ise anahtarın süresi dolmaz
+	ExpiresInDays int `json:"expires_in_days"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Anahtarın kendisi sadece oluşturulunca gösterilir
+type APIKeyCreatedResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// Verifier'ın yanında çalışır. "Authorization: ApiKey <anahtar>" geçerliyse anahtarın sahibi için
+// context'e token konur, böylece Authenticator ve userContextBody değişmeden çalışır.
+// Başlık var ama anahtar geçersizse istek 401 ile reddedilir.
+func (app *App) APIKeyVerifier(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, secret, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "ApiKey") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, err := app.Database.APIKeys.FindByHash(r.Context(), authentication.HashToken(strings.TrimSpace(secret)))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				sendResponse(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired api key"})
+			} else {
+				sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				log.Println(err)
+			}
+			return
+		}
+		if !app.keyCanWrite(r) {
+			sendResponse(w, http.StatusForbidden, map[string]string{"error": "api keys can't be used on this route"})
+			return
+		}
+		user, err := app.Database.Users.Find(r.Context(), key.UserID)
+		if err != nil {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": "invalid or expired api key"})
+			log.Println(err)
+			return
+		}
+		now := time.Now()
+		claims := map[string]interface{}{
+			"authorized": true,
+			"user":       user.Username,
+			"jti":        "apikey:" + key.ID,
+			"iat":        now.Unix(),
+		}
+		if key.ExpiresAt != nil {
+			claims["exp"] = key.ExpiresAt.Unix()
+		}
+		token, _, err := app.AuthToken.Encode(claims)
+		if err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
+			log.Println(err)
+			return
+		}
+		if err := app.Database.APIKeys.Touch(r.Context(), key, now); err != nil {
+			log.Println(err)
+		}
+		ctx := jwtauth.NewContext(r.Context(), token, nil)
+		ctx = context.WithValue(ctx, apiKeyCtxKey{}, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// İzin kontrolü yapan middleware'lerin döndürdüğü handler. Anahtarla yazma isteği sadece zincirinde
+// bunlardan biri olan route'lara yapılabilir, diğer yazma route'ları anahtarlara kapalıdır.
+type scopedHandler http.HandlerFunc
+
+func (h scopedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h(w, r)
+}
+
+// Oturumla gelen istekleri etkilemez. İzni handler kendisi kontrol ediyorsa, örneğin moderasyonda,
+// route'u kapsamı olan anahtarlara açmak için kullanılır.
+func RequireKeyScope(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return scopedHandler(func(w http.ResponseWriter, r *http.Request) {
+			if !keyAllows(r, permission) {
+				sendResponse(w, http.StatusForbidden, map[string]string{"error": "api key is missing scope " + permission})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Zincirinde izin kontrolü olan yazma route'ları. Middleware'ler boş bir handler'a uygulanıp
+// dönen handler'ın tipine bakılır.
+func keyWriteRoutes(router chi.Routes) (map[string]bool, error) {
+	routes := map[string]bool{}
+	err := chi.Walk(router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		for _, middleware := range middlewares {
+			if _, ok := middleware(http.NotFoundHandler()).(scopedHandler); ok {
+				routes[routeKey(method, route)] = true
+			}
+		}
+		return nil
+	})
+	return routes, err
+}
+
+// Walk ve RoutePattern sondaki / için farklı pattern döner
+func routeKey(method string, pattern string) string {
+	if pattern != "/" {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return method + " " + pattern
+}
+
+// Okuma istekleri her route'ta serbest
+func (app *App) keyCanWrite(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	rctx := chi.NewRouteContext()
+	return app.Router.Match(rctx, r.Method, path) && app.keyWrites[routeKey(r.Method, rctx.RoutePattern())]
+}
+
+// Hesap güvenliğiyle ilgili işlemler anahtarla yapılamaz, şifreyle açılmış oturum gerekir
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := apiKeyFromContext(r.Context()); ok {
+			sendResponse(w, http.StatusForbidden, map[string]string{"error": "not allowed with an api key"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func apiKeyFromContext(ctx context.Context) (database.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyCtxKey{}).(database.APIKey)
+	return key, ok
+}
+
+// Anahtarla gelen isteklerde izin anahtarın kapsamında da olmalı, oturumla gelenlerde her zaman true
+func keyAllows(r *http.Request, permission string) bool {
+	key, ok := apiKeyFromContext(r.Context())
+	return !ok || key.HasScope(permission)
+}
+
+func (app *App) APIKeyList(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	keys, err := app.Database.APIKeys.List(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	response := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = apiKeyResponse(key)
+	}
+	sendResponse(w, http.StatusOK, response)
+}
+
+func (app *App) APIKeyAdd(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[APIKeyRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if body.ExpiresInDays < 0 || body.ExpiresInDays > maxAPIKeyDays {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "expires_in_days must be between 0 and 365"})
+		return
+	}
+	secret, prefix, hash, err := authentication.NewAPIKey()
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	key := database.APIKey{
+		UserID:  user.ID,
+		Name:    strings.TrimSpace(body.Name),
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  strings.Join(body.Scopes, ","),
+	}
+	if body.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, body.ExpiresInDays)
+		key.ExpiresAt = &expires
+	}
+	key, err = app.Database.APIKeys.Create(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidAPIKey) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusCreated, APIKeyCreatedResponse{
+		APIKeyResponse: apiKeyResponse(key),
+		Key:            secret,
+	})
+}
+
+func (app *App) APIKeyRevoke(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	if err := app.Database.APIKeys.Revoke(context.Background(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func apiKeyResponse(key database.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
@@ -1,13 +1,22 @@
 package controllers
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/events"
+	"github.com/batt0s/batnovels/mailer"
+	"github.com/batt0s/batnovels/oidc"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -18,22 +27,51 @@ import (
 
 type App struct {
 	Addr      string
+	SiteURL   string // feed ve kataloglardaki linkler için frontend adresi
 	AppMode   string
 	Secret    string
 	AuthToken *jwtauth.JWTAuth
 	Router    *chi.Mux
 	Server    http.Server
 	Database  *database.Database
+	Hub       *events.Hub
+	Mailer    mailer.Mailer
+	// Yapılandırılmış OpenID Connect sağlayıcıları, isimleriyle
+	OIDC map[string]*oidc.Client
+	// Çöp kutusundaki kayıtlar bu kadar gün sonra kalıcı olarak silinir, 0 ise silinmez
+	TrashRetentionDays int
+	// İstemci adresi bu adreslerden gelen isteklerde X-Forwarded-For'dan okunur
+	TrustedProxies []netip.Prefix
+
+	notifications chan notificationEvent
+	notifierDone  chan struct{}
+	stopNotifier  context.CancelFunc
+	// Worker kuyruğu son kez boşaltmadan önce işaretler, sonraki bildirimler isteğin içinde dağıtılır
+	notifierMu      sync.RWMutex
+	notifierStopped bool
+	// API anahtarıyla yazma isteği yapılabilecek route'lar
+	keyWrites map[string]bool
 }
 
 func (app *App) Init() error {
-	database, err := database.New("sqlite", "dev.db", &gorm.Config{
+	// DATABASE_DRIVER sqlite ya da postgres, DATABASE_URL sqlite için dosya yolu
+	driver := strings.TrimSpace(os.Getenv("DATABASE_DRIVER"))
+	if driver == "" {
+		driver = "sqlite"
+	}
+	source := strings.TrimSpace(os.Getenv("DATABASE_URL"))
+	if source == "" && driver == "sqlite" {
+		source = "dev.db"
+	}
+	db, err := database.New(driver, source, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
 		return err
 	}
-	app.Database = database
+	app.Database = db
+	app.notifications = make(chan notificationEvent, notificationQueueSize)
+	app.Hub = events.NewHub(eventHistorySize)
 
 	var host, port string
 	host = strings.TrimSpace(os.Getenv("HOST"))
@@ -46,6 +84,34 @@ func (app *App) Init() error {
 	}
 	addr := host + ":" + port
 
+	siteURL := strings.TrimRight(strings.TrimSpace(os.Getenv("SITE_URL")), "/")
+	if siteURL == "" {
+		siteURL = "http://" + addr
+	}
+
+	trashRetentionDays := 30
+	if days := strings.TrimSpace(os.Getenv("TRASH_RETENTION_DAYS")); days != "" {
+		trashRetentionDays, err = strconv.Atoi(days)
+		if err != nil || trashRetentionDays < 0 {
+			return fmt.Errorf("invalid TRASH_RETENTION_DAYS %q", days)
+		}
+	}
+
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return err
+	}
+
+	mail, err := newMailer(siteURL)
+	if err != nil {
+		return err
+	}
+
+	providers, err := newOIDCProviders(siteURL)
+	if err != nil {
+		return err
+	}
+
 	var secret string
 	secret = strings.TrimSpace(os.Getenv("SECRET"))
 	if secret == "" {
@@ -58,52 +124,332 @@ func (app *App) Init() error {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"https://*", "http://*"},
-		AllowedMethods: []string{"GET", "POST"},
+		AllowedMethods: []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"},
+		// Authorization varsayılan listede yok, olmazsa token'lı PATCH/DELETE preflight'ta takılır
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "If-None-Match", "If-Modified-Since", "Last-Event-ID"},
+		ExposedHeaders: []string{"ETag", "Last-Modified", "Location"},
+		MaxAge:         300,
 	}))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(120 * time.Second))
+
+	r.Route("/feeds", func(feeds chi.Router) {
+		feeds.Use(middleware.Timeout(120 * time.Second))
+
+		feeds.Get("/latest.atom", app.LatestAtomFeed)
+		feeds.Get("/latest.rss", app.LatestRSSFeed)
+		feeds.Get("/project/{slug}.atom", app.ProjectAtomFeed)
+	})
+
+	r.Route("/opds", func(opds chi.Router) {
+		opds.Use(middleware.Timeout(120 * time.Second))
+
+		opds.Get("/", app.OPDSRoot)
+		opds.Get("/latest", app.OPDSLatest)
+		opds.Get("/featured", app.OPDSFeatured)
+		opds.Get("/tags", app.OPDSTags)
+		opds.Get("/tags/{tag}", app.OPDSTag)
+		opds.Get("/authors", app.OPDSAuthors)
+		opds.Get("/authors/{author}", app.OPDSAuthor)
+		opds.Get("/search", app.OPDSSearch)
+		opds.Get("/opensearch.xml", app.OPDSOpenSearch)
+	})
 
 	r.Route("/api", func(api chi.Router) {
-		api.Route("/user", func(user chi.Router) {
-			user.Post("/login", app.LoginHandler)
-			user.Post("/register", app.RegisterHandler)
-		})
-		api.Route("/project", func(project chi.Router) {
-			project.Get("/", app.ProjectList)
-			project.Get("/{slug}", app.ProjectDetail)
-			project.Get("/featured", app.FeaturedProjectList)
-			project.Get("/latest", app.LatestProjectList)
-			project.Get("/{slug}/chapters", app.ChapterList)
-
-			project.Group(func(projectAuth chi.Router) {
-				projectAuth.Use(jwtauth.Verifier(tokenAuth))
-				projectAuth.Use(jwtauth.Authenticator(tokenAuth))
-
-				projectAuth.Post("/", app.ProjectAdd)
-				projectAuth.Post("/{slug}/chapters", app.ChapterAdd)
+		// Token ya da API anahtarı zorunlu değil, varsa handler'lar kullanıcıyı tanıyabilsin diye doğrulanır
+		api.Use(jwtauth.Verifier(tokenAuth))
+		api.Use(app.APIKeyVerifier)
+		api.Use(app.rejectRevokedTokens)
+
+		// Event stream uzun süre açık kalır, timeout'lu grubun dışında tutulur
+		api.With(jwtauth.Authenticator(tokenAuth)).Get("/user/me/events", app.EventStream)
+
+		api.Group(func(api chi.Router) {
+			api.Use(middleware.Timeout(120 * time.Second))
+
+			api.Get("/search", app.Search)
+			api.Route("/auth", func(auth chi.Router) {
+				auth.Get("/providers", app.ProviderList)
+				auth.Post("/oidc/{provider}/start", app.OIDCStart)
+				auth.Post("/oidc/{provider}/callback", app.OIDCCallback)
+				auth.With(jwtauth.Authenticator(tokenAuth), RequireSession).Post("/oidc/{provider}/link", app.OIDCLinkStart)
+			})
+			api.Route("/user", func(user chi.Router) {
+				user.Post("/login", app.LoginHandler)
+				user.Post("/login/2fa", app.TwoFactorLogin)
+				user.Post("/register", app.RegisterHandler)
+				user.Post("/refresh", app.RefreshHandler)
+				user.Post("/verify-email", app.VerifyEmail)
+				user.Post("/password/forgot", app.PasswordForgot)
+				user.Post("/password/reset", app.PasswordReset)
+				user.With(jwtauth.Authenticator(tokenAuth), RequireSession).Post("/logout", app.LogoutHandler)
+				user.With(jwtauth.Authenticator(tokenAuth), RequireSession).Post("/logout-all", app.LogoutAllHandler)
+
+				user.Route("/me", func(me chi.Router) {
+					me.Use(jwtauth.Authenticator(tokenAuth))
+
+					me.Group(func(account chi.Router) {
+						account.Use(RequireSession)
+
+						account.Post("/verify-email", app.VerifyEmailResend)
+						account.Get("/2fa", app.TwoFactorStatus)
+						account.Post("/2fa/setup", app.TwoFactorSetup)
+						account.Post("/2fa/enable", app.TwoFactorEnable)
+						account.Post("/2fa/recovery-codes", app.RecoveryCodesRegenerate)
+						account.Delete("/2fa", app.TwoFactorDisable)
+						account.Get("/api-keys", app.APIKeyList)
+						account.Post("/api-keys", app.APIKeyAdd)
+						account.Delete("/api-keys/{id}", app.APIKeyRevoke)
+						account.Get("/identities", app.IdentityList)
+						account.Delete("/identities/{id}", app.IdentityUnlink)
+					})
+
+					me.Get("/roles", app.MyRoles)
+					me.Get("/invitations", app.MyInvitations)
+					me.With(RequireSession).Post("/invitations/{id}/accept", app.InvitationAccept)
+					me.With(RequireSession).Post("/invitations/{id}/decline", app.InvitationDecline)
+
+					me.Get("/progress", app.ProgressList)
+					me.Put("/progress", app.ProgressUpdate)
+					me.Get("/progress/history", app.ReadingHistory)
+					me.Delete("/progress/history", app.ReadingHistoryClear)
+					me.Get("/progress/{slug}", app.ProjectProgress)
+					me.Delete("/progress/{slug}", app.ProjectProgressClear)
+
+					me.Get("/library", app.LibraryList)
+					me.Get("/library/{slug}", app.LibraryEntry)
+					me.Put("/library/{slug}", app.LibraryFollow)
+					me.Delete("/library/{slug}", app.LibraryUnfollow)
+					me.Get("/shelves", app.ShelfList)
+					me.Post("/shelves", app.ShelfAdd)
+					me.Patch("/shelves/{id}", app.ShelfUpdate)
+					me.Delete("/shelves/{id}", app.ShelfDelete)
+
+					me.Get("/notifications", app.NotificationList)
+					me.Get("/notifications/unread-count", app.NotificationUnreadCount)
+					me.Post("/notifications/read-all", app.NotificationReadAll)
+					me.Post("/notifications/{id}/read", app.NotificationRead)
+				})
+			})
+			api.Route("/project", func(project chi.Router) {
+				project.Get("/", app.ProjectList)
+				project.Get("/featured", app.FeaturedProjectList)
+				project.Get("/latest", app.LatestProjectList)
+				project.Group(func(projectAuth chi.Router) {
+					projectAuth.Use(jwtauth.Authenticator(tokenAuth))
+					projectAuth.Use(app.RequirePermission(database.PermissionProjectCreate))
+
+					projectAuth.Post("/", app.ProjectAdd)
+					projectAuth.Post("/import", app.ProjectImport)
+				})
+
+				project.Group(func(project chi.Router) {
+					project.Use(app.RedirectOldSlug(app.currentProjectSlug))
+
+					project.Get("/{slug}", app.ProjectDetail)
+					project.Get("/{slug}/chapters", app.ChapterList)
+					project.Get("/{slug}/volumes", app.VolumeList)
+					project.Get("/{slug}/comments", app.ProjectCommentList)
+					project.Get("/{slug}/reviews", app.ReviewList)
+					project.Get("/{slug}/export.epub", app.ProjectExport)
+					project.Get("/{slug}/team", app.TeamList)
+
+					project.Group(func(projectAuth chi.Router) {
+						projectAuth.Use(jwtauth.Authenticator(tokenAuth))
+
+						projectAuth.With(app.RequireProjectPermission(database.PermissionProjectEdit, app.projectBySlug)).Patch("/{slug}", app.ProjectUpdate)
+						projectAuth.With(app.RequireProjectPermission(database.PermissionProjectDelete, app.projectBySlug)).Delete("/{slug}", app.ProjectDelete)
+						projectAuth.With(app.RequireProjectPermission(database.PermissionProjectEdit, app.projectBySlug)).Put("/{slug}/status", app.ProjectStatusUpdate)
+						projectAuth.With(app.RequireProjectPermission(database.PermissionChapterCreate, app.projectBySlug)).Post("/{slug}/chapters", app.ChapterAdd)
+						projectAuth.With(app.RequireProjectPermission(database.PermissionProjectEdit, app.projectBySlug)).Post("/{slug}/chapters/reorder", app.ChapterReorder)
+						projectAuth.With(app.RequireProjectPermission(database.PermissionProjectEdit, app.projectBySlug)).Post("/{slug}/volumes", app.VolumeAdd)
+						projectAuth.Post("/{slug}/comments", app.ProjectCommentAdd)
+						projectAuth.Get("/{slug}/review", app.UserReview)
+						projectAuth.Put("/{slug}/review", app.ReviewSave)
+						projectAuth.Delete("/{slug}/team/{username}", app.TeamMemberRemove)
+
+						projectAuth.Group(func(team chi.Router) {
+							team.Use(app.RequireProjectPermission(database.PermissionTeamManage, app.projectBySlug))
+
+							team.Patch("/{slug}/team/{username}", app.TeamMemberUpdate)
+							team.Get("/{slug}/invitations", app.InvitationList)
+							team.Post("/{slug}/invitations", app.InvitationAdd)
+							team.Delete("/{slug}/invitations/{id}", app.InvitationCancel)
+						})
+					})
+				})
+			})
+			api.Route("/chapter", func(chapter chi.Router) {
+				chapter.Group(func(chapter chi.Router) {
+					chapter.Use(app.RedirectOldSlug(app.currentChapterSlug))
+
+					chapter.Get("/{slug}", app.Chapter)
+					chapter.Get("/{slug}/comments", app.ChapterCommentList)
+
+					chapter.Group(func(chapterAuth chi.Router) {
+						chapterAuth.Use(jwtauth.Authenticator(tokenAuth))
+
+						chapterAuth.Post("/{slug}/comments", app.ChapterCommentAdd)
+						chapterAuth.With(app.RequireProjectPermission(database.PermissionChapterDelete, app.projectByChapter)).Delete("/{slug}", app.ChapterDelete)
+
+						chapterAuth.Group(func(chapterEdit chi.Router) {
+							chapterEdit.Use(app.RequireProjectPermission(database.PermissionChapterEdit, app.projectByChapter))
+
+							chapterEdit.Patch("/{slug}", app.ChapterUpdate)
+							chapterEdit.Post("/{slug}/move", app.ChapterMove)
+							chapterEdit.Get("/{slug}/revisions", app.ChapterRevisionList)
+							chapterEdit.Get("/{slug}/revisions/diff", app.ChapterRevisionDiff)
+							chapterEdit.Get("/{slug}/revisions/{number}", app.ChapterRevision)
+							chapterEdit.Post("/{slug}/revisions/{number}/restore", app.ChapterRevisionRestore)
+						})
+					})
+				})
+			})
+			api.Route("/volume", func(volume chi.Router) {
+				volume.Use(jwtauth.Authenticator(tokenAuth))
+
+				// Resolver {id}'yi okuyabilsin diye route eşleştikten sonra çalışmalı
+				volume.Group(func(volume chi.Router) {
+					volume.Use(app.RequireProjectPermission(database.PermissionProjectEdit, app.projectByVolume))
+
+					volume.Patch("/{id}", app.VolumeUpdate)
+					volume.Delete("/{id}", app.VolumeDelete)
+				})
+			})
+			api.Route("/review", func(review chi.Router) {
+				review.Use(jwtauth.Authenticator(tokenAuth))
+
+				review.With(RequireKeyScope(database.PermissionReviewModerate)).Delete("/{id}", app.ReviewDelete)
+				review.Post("/{id}/vote", app.ReviewVote)
+				review.Delete("/{id}/vote", app.ReviewUnvote)
+			})
+			api.Route("/comment", func(comment chi.Router) {
+				comment.Use(jwtauth.Authenticator(tokenAuth))
+
+				comment.Patch("/{id}", app.CommentUpdate)
+				comment.With(RequireKeyScope(database.PermissionCommentModerate)).Delete("/{id}", app.CommentDelete)
+			})
+			api.Route("/admin", func(admin chi.Router) {
+				admin.Use(jwtauth.Authenticator(tokenAuth))
+				admin.Use(app.RequirePermission(database.PermissionUserManage))
+
+				admin.Get("/roles", app.RoleList)
+				admin.Get("/users/{username}/roles", app.UserRoles)
+				admin.Put("/users/{username}/roles", app.UserRolesUpdate)
+				admin.Delete("/users/{username}", app.UserDelete)
+				admin.Delete("/users/{username}/2fa", app.UserTwoFactorReset)
+				admin.Delete("/users/{username}/lockout", app.UserUnlock)
+
+				admin.Get("/lockouts", app.LockoutList)
+				admin.Delete("/lockouts/ip/{ip}", app.IPUnlock)
+				admin.Get("/audit", app.AuditList)
+
+				admin.Get("/security", app.SecurityPolicyGet)
+				admin.Put("/security", app.SecurityPolicyUpdate)
+
+				admin.Get("/trash/{kind}", app.TrashList)
+				admin.Post("/trash/{kind}/{id}/restore", app.TrashRestore)
+				admin.Delete("/trash/{kind}/{id}", app.TrashPurge)
 			})
-		})
-		api.Route("/chapter", func(chapter chi.Router) {
-			chapter.Get("/{slug}", app.Chapter)
 		})
 	})
 
+	keyWrites, err := keyWriteRoutes(r)
+	if err != nil {
+		return err
+	}
+
 	app.Router = r
 	app.Addr = addr
+	app.SiteURL = siteURL
 	app.Server = http.Server{
 		Addr:    addr,
 		Handler: r,
 	}
 	app.Secret = secret
+	app.TrashRetentionDays = trashRetentionDays
+	app.TrustedProxies = trustedProxies
+	app.Mailer = mail
+	app.OIDC = providers
 	app.AuthToken = tokenAuth
+	app.keyWrites = keyWrites
 
 	log.Printf("App Inited\n Addr: %s\n App Mode: %s", app.Addr, app.AppMode)
 
 	return nil
 }
 
+// TRUSTED_PROXIES virgülle ayrılmış adres ya da CIDR listesi, örneğin "127.0.0.1,10.0.0.0/8".
+// Boşsa uygulamanın doğrudan internete açık olduğu varsayılır.
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", entry)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// MAIL_DRIVER smtp, file ya da log olabilir. Varsayılan log, e-postalar sadece log'a yazılır.
+func newMailer(siteURL string) (mailer.Mailer, error) {
+	from := strings.TrimSpace(os.Getenv("MAIL_FROM"))
+	if from == "" {
+		host := "localhost"
+		if u, err := url.Parse(siteURL); err == nil && u.Hostname() != "" {
+			host = u.Hostname()
+		}
+		from = "BatNovels <noreply@" + host + ">"
+	}
+	switch driver := strings.TrimSpace(os.Getenv("MAIL_DRIVER")); driver {
+	case "smtp":
+		port := 587
+		if p := strings.TrimSpace(os.Getenv("SMTP_PORT")); p != "" {
+			var err error
+			port, err = strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q", p)
+			}
+		}
+		host := strings.TrimSpace(os.Getenv("SMTP_HOST"))
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST is required when MAIL_DRIVER is smtp")
+		}
+		return mailer.SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		dir := strings.TrimSpace(os.Getenv("MAIL_DIR"))
+		if dir == "" {
+			dir = "mail"
+		}
+		return mailer.FileMailer{Dir: dir, From: from}, nil
+	case "", "log":
+		return mailer.LogMailer{From: from}, nil
+	default:
+		return nil, fmt.Errorf("invalid MAIL_DRIVER %q", driver)
+	}
+}
+
 func (app *App) Run() {
+	// Açık event stream'ler kapanmazsa Shutdown onları bekler
+	app.Server.RegisterOnShutdown(app.Hub.Close)
+	app.startScheduler()
+	app.startNotifier()
 	log.Printf("[info] App starting on %s", app.Addr)
 	app.Server.ListenAndServe()
 }
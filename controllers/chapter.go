@@ -14,14 +14,54 @@ import (
 )
 
 type ChapterRequestBody struct {
-	ID        string    `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Title     string    `json:"title"`
-	Content   string    `json:"content"`
-	ProjectID string    `json:"project_id"`
-	Slug      string    `json:"slug"`
-    TimeAgo   string    `json:"time_ago"`
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	ProjectID string     `json:"project_id"`
+	Slug      string     `json:"slug"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	VolumeID  *string    `json:"volume_id"`
+	Number    float64    `json:"number"`
+	TimeAgo   string     `json:"time_ago"`
+}
+
+// PATCH için, sadece gönderilen alanlar değişir. Sıra, cilt ve numara /move ile değişir.
+type ChapterUpdateRequestBody struct {
+	Title     *string    `json:"title"`
+	Content   *string    `json:"content"`
+	Status    *string    `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	Note      string     `json:"note"` // revizyon notu
+}
+
+// Okuyucu ekranı için bölümün ait olduğu projenin özeti
+type ChapterProjectInfo struct {
+	ID     string `json:"id"`
+	Slug   string `json:"slug"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Status string `json:"status"`
+	Image  string `json:"image"`
+}
+
+func projectInfo(project database.Project) ChapterProjectInfo {
+	return ChapterProjectInfo{
+		ID:     project.ID,
+		Slug:   project.Slug,
+		Title:  project.Title,
+		Author: project.Author,
+		Status: project.Status,
+		Image:  project.Image,
+	}
+}
+
+type ChapterDetailResponse struct {
+	ChapterRequestBody
+	Project    ChapterProjectInfo         `json:"project"`
+	Navigation database.ChapterNavigation `json:"navigation"`
 }
 
 func timeAgo(t time.Time) string {
@@ -49,7 +89,11 @@ func (app *App) ChapterList(w http.ResponseWriter, r *http.Request) {
 	}
 	var chapters []database.Chapter
 	var err error
-	chapters, err = app.Database.Chapters.ListBySlug(context.Background(), project_slug, 100, 0, "created_at asc")
+	if app.canEditChapters(r, project_slug) {
+		chapters, err = app.Database.Chapters.ListBySlug(context.Background(), project_slug, 100, 0, database.ChapterReadingOrder)
+	} else {
+		chapters, err = app.Database.Chapters.ListPublishedBySlug(context.Background(), project_slug, 100, 0, database.ChapterReadingOrder)
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			sendResponse(w, http.StatusNotFound, nil)
@@ -69,7 +113,11 @@ func (app *App) ChapterList(w http.ResponseWriter, r *http.Request) {
 			Content:   chapter.Content[:61] + "...",
 			ProjectID: chapter.ProjectID,
 			Slug:      chapter.Slug,
-            TimeAgo: timeAgo(chapter.CreatedAt),
+			Status:    chapter.Status,
+			PublishAt: chapter.PublishAt,
+			VolumeID:  chapter.VolumeID,
+			Number:    chapter.Number,
+			TimeAgo:   timeAgo(chapter.ReleaseTime()),
 		}
 		requestBodies = append(requestBodies, requestBody)
 	}
@@ -94,7 +142,57 @@ func (app *App) Chapter(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	sendResponse(w, http.StatusOK, chapter)
+	if !app.canView(r, chapter) {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	if user, ok := optionalUser(app.Database.Users, r.Context()); ok {
+		if err := app.Database.Library.MarkOpened(context.Background(), user.ID, chapter); err != nil {
+			log.Println(err)
+		}
+	}
+	navigation, err := app.Database.Chapters.Navigation(context.Background(), chapter)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, ChapterDetailResponse{
+		ChapterRequestBody: ChapterRequestBody{
+			ID:        chapter.ID,
+			CreatedAt: chapter.CreatedAt,
+			UpdatedAt: chapter.UpdatedAt,
+			Title:     chapter.Title,
+			Content:   chapter.Content,
+			ProjectID: chapter.ProjectID,
+			Slug:      chapter.Slug,
+			Status:    chapter.Status,
+			PublishAt: chapter.PublishAt,
+			VolumeID:  chapter.VolumeID,
+			Number:    chapter.Number,
+			TimeAgo:   timeAgo(chapter.ReleaseTime()),
+		},
+		Project:    projectInfo(chapter.Project),
+		Navigation: navigation,
+	})
+}
+
+// Taslak ve planlanmış bölümler proje ekibi dışındakiler için yokmuş gibi davranır
+func (app *App) canView(r *http.Request, chapter database.Chapter) bool {
+	if chapter.IsPublic() {
+		return true
+	}
+	user, ok := optionalUser(app.Database.Users, r.Context())
+	return ok && app.canInProject(r, user, chapter.ProjectID, database.PermissionChapterEdit)
+}
+
+func (app *App) canEditChapters(r *http.Request, project_slug string) bool {
+	user, ok := optionalUser(app.Database.Users, r.Context())
+	if !ok {
+		return false
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), project_slug)
+	return err == nil && app.canInProject(r, user, project.ID, database.PermissionChapterEdit)
 }
 
 func (app *App) ChapterAdd(w http.ResponseWriter, r *http.Request) {
@@ -104,10 +202,6 @@ func (app *App) ChapterAdd(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	if !user.IsStaff {
-		sendResponse(w, http.StatusUnauthorized, nil)
-		return
-	}
 	project_slug := chi.URLParam(r, "slug")
 	if project_slug == "" {
 		sendResponse(w, http.StatusBadRequest, nil)
@@ -126,20 +220,128 @@ func (app *App) ChapterAdd(w http.ResponseWriter, r *http.Request) {
 	}
 	project, err := app.Database.Projects.FindBySlug(context.Background(), project_slug)
 	if err != nil {
-		sendResponse(w, http.StatusInternalServerError, nil)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
 		log.Println(err)
 		return
 	}
+	// Taslak dışındaki her durum okuyuculara açılmak demek
+	if body.Status != database.ChapterDraft && !app.canInProject(r, user, project.ID, database.PermissionChapterPublish) {
+		sendForbidden(w, database.PermissionChapterPublish)
+		return
+	}
+	if body.VolumeID != nil {
+		volume, err := app.Database.Volumes.Find(context.Background(), *body.VolumeID)
+		if err != nil || volume.ProjectID != project.ID {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "volume not found in project"})
+			return
+		}
+	}
 	chapter := database.Chapter{
 		Title:     body.Title,
 		Content:   body.Content,
+		Status:    body.Status,
+		PublishAt: body.PublishAt,
+		VolumeID:  body.VolumeID,
+		Number:    body.Number,
 		ProjectID: project.ID,
 	}
+	chapter.ScheduleIfFuture(time.Now())
 	chapter, err = app.Database.Chapters.Add(context.Background(), chapter)
 	if err != nil {
+		if errors.Is(err, database.ErrorInvalidChapter) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
 		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		log.Println(err)
 		return
 	}
+	_, err = app.Database.Revisions.Record(context.Background(), chapter, &user.ID, "Initial version")
+	if err != nil {
+		log.Println(err)
+	}
+	if chapter.Status == database.ChapterPublished {
+		app.notifyNewChapter(chapter, project, user.ID)
+	}
+	sendResponse(w, http.StatusOK, chapter)
+}
+
+// Başlık ya da içerik değiştiyse revizyon eklenir, başlıkla birlikte slug da değişir.
+// Durumu ya da yayın zamanını değiştirmek chapter.publish ister.
+func (app *App) ChapterUpdate(w http.ResponseWriter, r *http.Request) {
+	user, chapter, ok := app.editableChapter(w, r)
+	if !ok {
+		return
+	}
+	body, err := getRequestBody[ChapterUpdateRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	previous := chapter.Status
+	if (body.Status != nil && *body.Status != chapter.Status) || body.PublishAt != nil {
+		if !app.canInProject(r, user, chapter.ProjectID, database.PermissionChapterPublish) {
+			sendForbidden(w, database.PermissionChapterPublish)
+			return
+		}
+	}
+	if body.Title != nil {
+		chapter.Title = *body.Title
+	}
+	if body.Content != nil {
+		chapter.Content = *body.Content
+	}
+	if body.Status != nil {
+		chapter.Status = *body.Status
+	}
+	if body.PublishAt != nil {
+		chapter.PublishAt = body.PublishAt
+	}
+	if chapter.Status == database.ChapterPublished && chapter.PublishAt == nil {
+		now := time.Now()
+		chapter.PublishAt = &now
+	}
+	chapter.ScheduleIfFuture(time.Now())
+	note := body.Note
+	if note == "" {
+		note = "Edited"
+	}
+	chapter, err = app.Database.Chapters.Revise(context.Background(), chapter, &user.ID, note)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidChapter) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if chapter.Status == database.ChapterPublished && previous != database.ChapterPublished {
+		app.notifyNewChapter(chapter, chapter.Project, user.ID)
+	}
 	sendResponse(w, http.StatusOK, chapter)
 }
+
+func (app *App) ChapterDelete(w http.ResponseWriter, r *http.Request) {
+	_, chapter, ok := app.editableChapter(w, r)
+	if !ok {
+		return
+	}
+	if err := app.Database.Chapters.Delete(context.Background(), chapter); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type CommentRequestBody struct {
+	ID        string                `json:"id"`
+	CreatedAt time.Time             `json:"created_at"`
+	EditedAt  *time.Time            `json:"edited_at"`
+	Content   string                `json:"content"`
+	ParentID  *string               `json:"parent_id"`
+	Username  string                `json:"username"`
+	Name      string                `json:"name"`
+	IsDeleted bool                  `json:"is_deleted"`
+	TimeAgo   string                `json:"time_ago"`
+	Replies   []*CommentRequestBody `json:"replies"`
+}
+
+type CommentListResponse struct {
+	Count    int64                 `json:"count"`
+	Comments []*CommentRequestBody `json:"comments"`
+}
+
+func newCommentRequestBody(comment database.Comment) *CommentRequestBody {
+	return &CommentRequestBody{
+		ID:        comment.ID,
+		CreatedAt: comment.CreatedAt,
+		EditedAt:  comment.EditedAt,
+		Content:   comment.Content,
+		ParentID:  comment.ParentID,
+		Username:  comment.User.Username,
+		Name:      comment.User.Name,
+		IsDeleted: comment.IsDeleted,
+		TimeAgo:   timeAgo(comment.CreatedAt),
+		Replies:   []*CommentRequestBody{},
+	}
+}
+
+// Düz yorum listesini parent_id'lere göre ağaca çevirir
+func commentTree(comments []database.Comment) []*CommentRequestBody {
+	nodes := make(map[string]*CommentRequestBody, len(comments))
+	roots := []*CommentRequestBody{}
+	for _, comment := range comments {
+		nodes[comment.ID] = newCommentRequestBody(comment)
+	}
+	for _, comment := range comments {
+		node := nodes[comment.ID]
+		if comment.ParentID == nil {
+			roots = append(roots, node)
+			continue
+		}
+		if parent, ok := nodes[*comment.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+	return roots
+}
+
+func (app *App) ChapterCommentList(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	chapter, err := app.Database.Chapters.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if !app.canView(r, chapter) {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	limit, offset := paginationParams(r, 50)
+	comments, err := app.Database.Comments.ListByChapter(context.Background(), chapter.ID, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	count, err := app.Database.Comments.CountByChapter(context.Background(), chapter.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, CommentListResponse{Count: count, Comments: commentTree(comments)})
+}
+
+func (app *App) ProjectCommentList(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	limit, offset := paginationParams(r, 50)
+	comments, err := app.Database.Comments.ListByProject(context.Background(), project.ID, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	count, err := app.Database.Comments.CountByProject(context.Background(), project.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, CommentListResponse{Count: count, Comments: commentTree(comments)})
+}
+
+func (app *App) ChapterCommentAdd(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	chapter, err := app.Database.Chapters.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if !app.canView(r, chapter) {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	app.addComment(w, r, database.Comment{
+		ProjectID: &chapter.ProjectID,
+		ChapterID: &chapter.ID,
+	})
+}
+
+func (app *App) ProjectCommentAdd(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	app.addComment(w, r, database.Comment{
+		ProjectID: &project.ID,
+	})
+}
+
+func (app *App) addComment(w http.ResponseWriter, r *http.Request, comment database.Comment) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[CommentRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	var parent database.Comment
+	if body.ParentID != nil {
+		parent, err = app.Database.Comments.Find(context.Background(), *body.ParentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				sendResponse(w, http.StatusBadRequest, map[string]string{"error": "parent comment not found"})
+			} else {
+				sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			log.Println(err)
+			return
+		}
+		if !sameTarget(parent, comment) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "parent comment belongs to another thread"})
+			return
+		}
+	}
+	comment.Content = body.Content
+	comment.ParentID = body.ParentID
+	comment.UserID = user.ID
+	comment, err = app.Database.Comments.Add(context.Background(), comment)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidComment) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	comment.User = user
+	if comment.ParentID != nil {
+		app.notifyCommentReply(comment, parent, user)
+	}
+	sendResponse(w, http.StatusOK, newCommentRequestBody(comment))
+}
+
+func (app *App) CommentUpdate(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	comment, err := app.Database.Comments.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if comment.UserID != user.ID {
+		sendResponse(w, http.StatusForbidden, map[string]string{"error": "only the author can edit a comment"})
+		return
+	}
+	body, err := getRequestBody[CommentRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	comment.Content = body.Content
+	comment, err = app.Database.Comments.Update(context.Background(), comment)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidComment) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newCommentRequestBody(comment))
+}
+
+func (app *App) CommentDelete(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	comment, err := app.Database.Comments.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if comment.UserID != user.ID && !app.can(r, user, database.PermissionCommentModerate) {
+		sendForbidden(w, database.PermissionCommentModerate)
+		return
+	}
+	err = app.Database.Comments.Delete(context.Background(), comment)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func sameTarget(parent, comment database.Comment) bool {
+	if comment.ChapterID != nil {
+		return parent.ChapterID != nil && *parent.ChapterID == *comment.ChapterID
+	}
+	return parent.ChapterID == nil && parent.ProjectID != nil && *parent.ProjectID == *comment.ProjectID
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/events"
+)
+
+// Proxy'ler boşta kalan bağlantıyı kapatmasın diye gönderilen yorum satırı aralığı
+const heartbeatInterval = 15 * time.Second
+
+// Kullanıcı başına Last-Event-ID ile tekrar gönderilebilecek olay sayısı
+const eventHistorySize = 100
+
+// Bu süreden eski olaylar geçmişten silinir, yeniden bağlanan istemci listeleri baştan çeker
+const eventHistoryTTL = time.Hour
+
+// Bağlantı koparsa istemcinin kaç ms sonra yeniden bağlanacağı
+const eventRetry = 5000
+
+// Geçmişte olmayan olaylar kaçırıldıysa gönderilir, istemci listeleri baştan çekmeli
+const eventResync = "resync"
+
+// Kullanıcının açık event stream'lerine JSON olarak gönderir
+func (app *App) publishEvent(user_id string, kind string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("[events]", err)
+		return
+	}
+	app.Hub.Publish(user_id, kind, data)
+}
+
+// Server-Sent Events. Tarayıcıdaki EventSource header gönderemediği için token jwt cookie'sinden de okunur.
+func (app *App) EventStream(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	rc := http.NewResponseController(w)
+	sub, missed, complete := app.Hub.Subscribe(user.ID, r.Header.Get("Last-Event-ID"))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+	if !complete {
+		writeEvent(w, events.Event{Type: eventResync, Data: []byte("{}")})
+	}
+	for _, event := range missed {
+		writeEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		log.Println("[events]", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			// Hub kapandı ya da bağlantı yetişemedi, istemci yeniden bağlanacak
+			if !ok {
+				return
+			}
+			writeEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) {
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\n", event.Type)
+	for _, line := range strings.Split(string(event.Data), "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+func (app *App) sweepEvents() {
+	if swept := app.Hub.Sweep(time.Now().Add(-eventHistoryTTL)); swept > 0 {
+		log.Printf("[scheduler] Swept %d live events", swept)
+	}
+}
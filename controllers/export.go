@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/epub"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Kapak resmi için indirilecek en büyük boyut
+const maxCoverSize = 5 << 20
+
+// Kapak adresini yazar girdiği için sunucunun iç ağına istek atılmasın diye her bağlantıda, yönlendirmeler dahil,
+// çözülen adres kontrol edilir. Proxy kullanılmaz, yoksa kontrol proxy'nin adresine yapılmış olur.
+var coverClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return errors.New("unsupported redirect to " + req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// IANA special-purpose kayıtlarındaki bloklar: yerel, ayrılmış, dokümantasyon ve
+// başka bir adrese tünellenen aralıklar internette bir sunucuya karşılık gelmez
+var specialPurposeNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.31.196.0/24"),
+	netip.MustParsePrefix("192.52.193.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("192.175.48.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("::ffff:0:0/96"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("3fff::/20"),
+	netip.MustParsePrefix("5f00::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+func dialPublicOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return errors.New("cover host " + host + " is not a public address")
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	// IPv4-mapped IPv6 adresleri IPv4 kurallarıyla denetlenir
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range specialPurposeNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func (app *App) ProjectExport(w http.ResponseWriter, r *http.Request) {
+	project_slug := chi.URLParam(r, "slug")
+	if project_slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), project_slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	chapters, err := app.Database.Chapters.ListPublishedBySlug(context.Background(), project_slug, -1, 0, database.ChapterReadingOrder)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	book := projectBook(project, chapters)
+	if cover, err := fetchCover(project.Image); err != nil {
+		log.Println("Could not fetch cover for", project.Slug, err)
+	} else {
+		book.Cover = cover
+	}
+	w.Header().Set("Content-Type", "application/epub+zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+project.Slug+`.epub"`)
+	if err := book.Write(w); err != nil {
+		log.Println(err)
+	}
+}
+
+func projectBook(project database.Project, chapters []database.Chapter) epub.Book {
+	book := epub.Book{
+		Identifier:  "urn:uuid:" + project.ID,
+		Title:       project.Title,
+		Author:      project.Author,
+		Description: project.Synopsis,
+		Modified:    project.UpdatedAt,
+	}
+	for _, tag := range strings.Split(project.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			book.Subjects = append(book.Subjects, tag)
+		}
+	}
+	for _, chapter := range chapters {
+		book.Chapters = append(book.Chapters, epub.Chapter{
+			Title:   chapter.Title,
+			Content: chapter.Content,
+		})
+		if chapter.UpdatedAt.After(book.Modified) {
+			book.Modified = chapter.UpdatedAt
+		}
+	}
+	return book
+}
+
+// Project.Image bir URL, sadece http(s) ve EPUB'ın desteklediği formatlar alınır
+func fetchCover(address string) (*epub.Image, error) {
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, nil
+	}
+	resp, err := coverClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("cover request failed with " + resp.Status)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, errors.New("cover is not an image: " + resp.Header.Get("Content-Type"))
+	}
+	if resp.ContentLength > maxCoverSize {
+		return nil, errors.New("cover image is too large")
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCoverSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCoverSize {
+		return nil, errors.New("cover image is too large")
+	}
+	mediaType := http.DetectContentType(data)
+	if !epub.IsSupportedImage(mediaType) {
+		mediaType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	}
+	if !epub.IsSupportedImage(mediaType) {
+		return nil, errors.New("unsupported cover type " + mediaType)
+	}
+	return &epub.Image{Data: data, MediaType: mediaType}, nil
+}
@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/feed"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Feed'lerde kaç bölüm gösterileceği
+const feedSize = 50
+
+// Girdi özetinin en fazla karakter sayısı
+const feedExcerptLength = 280
+
+func (app *App) LatestAtomFeed(w http.ResponseWriter, r *http.Request) {
+	app.latestFeed(w, r, "application/atom+xml; charset=utf-8", feed.Feed.WriteAtom)
+}
+
+func (app *App) LatestRSSFeed(w http.ResponseWriter, r *http.Request) {
+	app.latestFeed(w, r, "application/rss+xml; charset=utf-8", feed.Feed.WriteRSS)
+}
+
+func (app *App) latestFeed(w http.ResponseWriter, r *http.Request, contentType string, write func(feed.Feed, io.Writer) error) {
+	chapters, err := app.Database.Chapters.ListLatestPublished(context.Background(), feedSize, 0)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	f := feed.Feed{
+		ID:       "tag:" + app.siteHost() + ",2024:latest",
+		Title:    "BatNovels - Latest Releases",
+		Subtitle: "Newest chapters from every project",
+		Link:     app.SiteURL,
+		SelfLink: app.requestURL(r),
+	}
+	for _, chapter := range chapters {
+		f.Entries = append(f.Entries, app.chapterEntry(chapter, chapter.Project))
+	}
+	f.Updated = feedUpdated(f.Entries)
+	serveFeed(w, r, contentType, f.Updated, func(w io.Writer) error { return write(f, w) })
+}
+
+func (app *App) ProjectAtomFeed(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	project, err := app.Database.Projects.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	chapters, err := app.Database.Chapters.ListPublishedBySlug(context.Background(), project.Slug, feedSize, 0, "sort_key desc")
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	f := feed.Feed{
+		ID:       "urn:uuid:" + project.ID,
+		Title:    project.Title,
+		Subtitle: project.Synopsis,
+		Link:     app.SiteURL + "/project/" + project.Slug,
+		SelfLink: app.requestURL(r),
+		Author:   project.Author,
+	}
+	for _, chapter := range chapters {
+		f.Entries = append(f.Entries, app.chapterEntry(chapter, project))
+	}
+	f.Updated = feedUpdated(f.Entries)
+	if f.Updated.IsZero() {
+		f.Updated = project.UpdatedAt
+	}
+	serveFeed(w, r, "application/atom+xml; charset=utf-8", f.Updated, f.WriteAtom)
+}
+
+func (app *App) chapterEntry(chapter database.Chapter, project database.Project) feed.Entry {
+	updated := chapter.UpdatedAt
+	if chapter.ReleaseTime().After(updated) {
+		updated = chapter.ReleaseTime()
+	}
+	return feed.Entry{
+		ID:        "urn:uuid:" + chapter.ID,
+		Title:     project.Title + " - " + chapter.Title,
+		Link:      app.SiteURL + "/chapter/" + chapter.Slug,
+		Author:    project.Author,
+		Summary:   excerpt(chapter.Content, feedExcerptLength),
+		Published: chapter.ReleaseTime(),
+		Updated:   updated,
+	}
+}
+
+// Feed'i önce belleğe yazar, ETag içerikten hesaplanır. If-None-Match ya da
+// If-Modified-Since tutuyorsa 304 döner. Boş feed'in güncellenme zamanı yoktur, sadece ETag'e bakılır.
+func serveFeed(w http.ResponseWriter, r *http.Request, contentType string, updated time.Time, write func(io.Writer) error) {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sum := sha1.Sum(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:10]) + `"`
+	modified := updated.UTC().Truncate(time.Second)
+
+	w.Header().Set("ETag", etag)
+	if !updated.IsZero() {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// If-None-Match varsa If-Modified-Since'a bakılmaz (RFC 9110)
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if modified.IsZero() {
+		return false
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !modified.After(since)
+	}
+	return false
+}
+
+func feedUpdated(entries []feed.Entry) time.Time {
+	var updated time.Time
+	for _, entry := range entries {
+		if entry.Updated.After(updated) {
+			updated = entry.Updated
+		}
+	}
+	return updated
+}
+
+// Boşlukları sadeleştirip kelime ortasından bölmeden kısaltır
+func excerpt(content string, max int) string {
+	text := strings.Join(strings.Fields(content), " ")
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := string(runes[:max])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// Host ve X-Forwarded-Proto istemciden gelir, linkler yapılandırılmış site adresiyle kurulur
+func (app *App) requestURL(r *http.Request) string {
+	return app.SiteURL + r.URL.RequestURI()
+}
+
+func (app *App) siteHost() string {
+	host := strings.TrimPrefix(strings.TrimPrefix(app.SiteURL, "https://"), "http://")
+	if i := strings.IndexAny(host, ":/"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
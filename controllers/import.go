@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/importer"
+)
+
+// İçe aktarılacak dosya için en büyük boyut
+const maxImportSize = 32 << 20
+
+type ImportProjectReport struct {
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Slug   string `json:"slug,omitempty"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportChapterReport struct {
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	Length int    `json:"length"`
+	Slug   string `json:"slug,omitempty"`
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun   bool                  `json:"dry_run"`
+	Valid    bool                  `json:"valid"`
+	Created  bool                  `json:"created"`
+	Project  ImportProjectReport   `json:"project"`
+	Chapters []ImportChapterReport `json:"chapters"`
+}
+
+// EPUB ya da markdown zip'inden proje ve bölümlerini oluşturur.
+// Form alanları (title, synopsis, author, status, tags, image) dosyadaki değerlerin üstüne yazar.
+func (app *App) ProjectImport(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "missing file: " + err.Error()})
+		log.Println(err)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	draft, err := importer.Parse(data)
+	if err != nil {
+		sendResponse(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	overrideFormValue(r, "title", &draft.Project.Title)
+	overrideFormValue(r, "synopsis", &draft.Project.Synopsis)
+	overrideFormValue(r, "author", &draft.Project.Author)
+	overrideFormValue(r, "status", &draft.Project.Status)
+	overrideFormValue(r, "tags", &draft.Project.Tags)
+	overrideFormValue(r, "image", &draft.Project.Image)
+	if draft.Project.Status == "" {
+		draft.Project.Status = "ongoing"
+	}
+
+	report := ImportReport{
+		DryRun: isTruthy(r.FormValue("dry_run")),
+		Valid:  true,
+		Project: ImportProjectReport{
+			Title:  draft.Project.Title,
+			Author: draft.Project.Author,
+			Valid:  draft.Project.IsValid(),
+		},
+	}
+	if !report.Project.Valid {
+		report.Valid = false
+		report.Project.Error = database.ErrorInvalidProject.Error()
+	}
+	for i, chapter := range draft.Chapters {
+		chapterReport := ImportChapterReport{
+			Index:  i + 1,
+			Title:  chapter.Title,
+			Length: len(chapter.Content),
+			Valid:  chapter.IsValid(),
+		}
+		if !chapterReport.Valid {
+			report.Valid = false
+			chapterReport.Error = database.ErrorInvalidChapter.Error()
+		}
+		report.Chapters = append(report.Chapters, chapterReport)
+	}
+	if !report.Valid {
+		sendResponse(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if report.DryRun {
+		sendResponse(w, http.StatusOK, report)
+		return
+	}
+
+	project, chapters, err := app.Database.Projects.AddWithChapters(context.Background(), draft.Project, draft.Chapters, user.ID)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidProject) || errors.Is(err, database.ErrorInvalidChapter) {
+			sendResponse(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	report.Created = true
+	report.Project.Slug = project.Slug
+	for i, chapter := range chapters {
+		report.Chapters[i].Slug = chapter.Slug
+	}
+	sendResponse(w, http.StatusOK, report)
+}
+
+func overrideFormValue(r *http.Request, key string, value *string) {
+	if formValue := strings.TrimSpace(r.FormValue(key)); formValue != "" {
+		*value = formValue
+	}
+}
+
+func isTruthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type LibraryRequestBody struct {
+	Status  string    `json:"status"`
+	Shelves *[]string `json:"shelves"` // özel raf ID'leri, verilmezse değişmez
+}
+
+type LibraryEntryResponse struct {
+	Project       ChapterProjectInfo `json:"project"`
+	Status        string             `json:"status"`
+	Shelves       []database.Shelf   `json:"shelves"`
+	Unread        int64              `json:"unread"`
+	LastChapterID *string            `json:"last_chapter_id"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+}
+
+type ShelfRequestBody struct {
+	Name string `json:"name"`
+}
+
+type ShelfListResponse struct {
+	Default []string         `json:"default"`
+	Custom  []database.Shelf `json:"custom"`
+}
+
+func newLibraryEntryResponse(entry database.LibraryEntry) LibraryEntryResponse {
+	shelves := entry.Shelves
+	if shelves == nil {
+		shelves = []database.Shelf{}
+	}
+	return LibraryEntryResponse{
+		Project:       projectInfo(entry.Project),
+		Status:        entry.Status,
+		Shelves:       shelves,
+		Unread:        entry.Unread,
+		LastChapterID: entry.LastChapterID,
+		UpdatedAt:     entry.UpdatedAt,
+	}
+}
+
+// ?status=reading ile hazır rafa, ?shelf=<id> ile özel rafa göre filtrelenir
+func (app *App) LibraryList(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !database.IsDefaultShelf(status) {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "unknown status"})
+		return
+	}
+	limit, offset := paginationParams(r, 50)
+	entries, err := app.Database.Library.List(context.Background(), user.ID, status, r.URL.Query().Get("shelf"), limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	responses := []LibraryEntryResponse{}
+	for _, entry := range entries {
+		if entry.Project.ID == "" {
+			continue
+		}
+		responses = append(responses, newLibraryEntryResponse(entry))
+	}
+	sendResponse(w, http.StatusOK, responses)
+}
+
+func (app *App) LibraryEntry(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	entry, err := app.Database.Library.Find(context.Background(), user.ID, project.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newLibraryEntryResponse(entry))
+}
+
+// Projeyi takip eder ya da rafını değiştirir
+func (app *App) LibraryFollow(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[LibraryRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	entry, err := app.Database.Library.Follow(context.Background(), database.LibraryEntry{
+		UserID:    user.ID,
+		ProjectID: project.ID,
+		Status:    body.Status,
+	})
+	if err == nil && body.Shelves != nil {
+		entry, err = app.Database.Library.SetShelves(context.Background(), entry, *body.Shelves)
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidLibraryEntry) || errors.Is(err, database.ErrorInvalidShelf) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newLibraryEntryResponse(entry))
+}
+
+func (app *App) LibraryUnfollow(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	err = app.Database.Library.Unfollow(context.Background(), user.ID, project.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) ShelfList(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	shelves, err := app.Database.Shelves.List(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, ShelfListResponse{Default: database.DefaultShelves, Custom: shelves})
+}
+
+func (app *App) ShelfAdd(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[ShelfRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if app.shelfNameTaken(user.ID, body.Name, "") {
+		sendResponse(w, http.StatusConflict, map[string]string{"error": "shelf already exists"})
+		return
+	}
+	shelf, err := app.Database.Shelves.Add(context.Background(), database.Shelf{UserID: user.ID, Name: body.Name})
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidShelf) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, shelf)
+}
+
+func (app *App) ShelfUpdate(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	shelf, ok := app.userShelf(w, chi.URLParam(r, "id"), user)
+	if !ok {
+		return
+	}
+	body, err := getRequestBody[ShelfRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if app.shelfNameTaken(user.ID, body.Name, shelf.ID) {
+		sendResponse(w, http.StatusConflict, map[string]string{"error": "shelf already exists"})
+		return
+	}
+	shelf.Name = body.Name
+	shelf, err = app.Database.Shelves.Update(context.Background(), shelf)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidShelf) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, shelf)
+}
+
+func (app *App) ShelfDelete(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	shelf, ok := app.userShelf(w, chi.URLParam(r, "id"), user)
+	if !ok {
+		return
+	}
+	if err := app.Database.Shelves.Delete(context.Background(), shelf); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Başkasının rafı da yokmuş gibi 404 döner
+func (app *App) userShelf(w http.ResponseWriter, id string, user database.User) (database.Shelf, bool) {
+	shelf, err := app.Database.Shelves.Find(context.Background(), id)
+	if err != nil || shelf.UserID != user.ID {
+		if err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+		}
+		return shelf, false
+	}
+	return shelf, true
+}
+
+func (app *App) shelfNameTaken(user_id string, name string, exclude_id string) bool {
+	shelves, err := app.Database.Shelves.List(context.Background(), user_id)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	for _, shelf := range shelves {
+		if shelf.ID != exclude_id && strings.EqualFold(shelf.Name, strings.TrimSpace(name)) {
+			return true
+		}
+	}
+	return false
+}
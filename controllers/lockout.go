@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	// Bir hesaba 5 yanlış denemeden sonra 30 saniyeden başlayıp en fazla 1 saat kilit
+	accountLockout = database.LockoutPolicy{
+		FreeAttempts: 5,
+		BaseLockout:  30 * time.Second,
+		MaxLockout:   time.Hour,
+		Window:       24 * time.Hour,
+	}
+	// Aynı adresten farklı hesaplar denenirse, paylaşılan ağlar için sınır daha yüksek
+	ipLockout = database.LockoutPolicy{
+		FreeAttempts: 20,
+		BaseLockout:  30 * time.Second,
+		MaxLockout:   time.Hour,
+		Window:       24 * time.Hour,
+	}
+)
+
+const (
+	auditRetention      = 90 * 24 * time.Hour
+	invalidCredentials  = "invalid username or password"
+	tooManyLoginAttempt = "too many failed login attempts, try again later"
+)
+
+type LockoutResponse struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	RetryAfter  int       `json:"retry_after"`
+}
+
+// Kayıtlarda ve kilit anahtarında kullanıcı adının büyük/küçük harf farkı önemsenmez
+func loginName(username string) string {
+	return truncate(strings.ToLower(strings.TrimSpace(username)), 128)
+}
+
+func accountLockKey(username string) string {
+	return "user:" + loginName(username)
+}
+
+func ipLockKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Kilitliyken şifre hiç kontrol edilmez
+func (app *App) loginLocked(w http.ResponseWriter, r *http.Request, username string) bool {
+	until, err := app.Database.LoginLocks.LockedUntil(context.Background(), time.Now(), accountLockKey(username), ipLockKey(app.clientIP(r)))
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return true
+	}
+	if until.IsZero() {
+		return false
+	}
+	app.audit(r, database.AuditEvent{Action: database.AuditLoginBlocked, Subject: loginName(username)})
+	sendLoginLocked(w, until)
+	return true
+}
+
+// Yanlış şifreyi hesap ve adres için sayar. Cevap kullanıcı adı var olsa da olmasa da aynıdır;
+// deneme kilidi başlattıysa Retry-After da eklenir.
+func (app *App) loginFailed(w http.ResponseWriter, r *http.Request, username string, user_id string) {
+	if until := app.countLoginFailure(r, database.AuditLoginFailed, username, user_id); !until.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter(until)))
+	}
+	sendResponse(w, http.StatusUnauthorized, map[string]string{"error": invalidCredentials})
+}
+
+// Yanlış şifre ve yanlış iki adımlı doğrulama kodu aynı sayaçlara yazılır. Kilit başladıysa bitişini döner.
+func (app *App) countLoginFailure(r *http.Request, action string, username string, user_id string) time.Time {
+	ctx := context.Background()
+	now := time.Now()
+	ip := app.clientIP(r)
+	app.audit(r, database.AuditEvent{Action: action, UserID: user_id, Subject: loginName(username)})
+	var until time.Time
+	for key, policy := range map[string]database.LockoutPolicy{accountLockKey(username): accountLockout, ipLockKey(ip): ipLockout} {
+		lock, err := app.Database.LoginLocks.Fail(ctx, key, policy, now)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if lock.LockedUntil == nil || !lock.LockedUntil.After(now) {
+			continue
+		}
+		app.audit(r, database.AuditEvent{Action: database.AuditLoginLocked, UserID: user_id, Subject: key,
+			Detail: "locked until " + lock.LockedUntil.UTC().Format(time.RFC3339) + " after " + strconv.Itoa(lock.Failures) + " failures"})
+		if lock.LockedUntil.After(until) {
+			until = *lock.LockedUntil
+		}
+	}
+	return until
+}
+
+// Giriş iki adımlı doğrulamayla birlikte tamamlanınca ve şifre sıfırlanınca hesabın sayacı sıfırlanır. Şifreyi bilen biri
+// kod denemeleri arasında sayacı sıfırlayamasın diye şifre adımında sıfırlanmaz. Adresinki hiç sıfırlanmaz, kendi hesabıyla
+// giriş yapan biri başka hesapları denemeye devam edemesin.
+func (app *App) resetLoginLock(username string) {
+	if _, err := app.Database.LoginLocks.Reset(context.Background(), accountLockKey(username)); err != nil {
+		log.Println(err)
+	}
+}
+
+func sendLoginLocked(w http.ResponseWriter, until time.Time) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter(until)))
+	sendResponse(w, http.StatusTooManyRequests, map[string]string{"error": tooManyLoginAttempt})
+}
+
+func retryAfter(until time.Time) int {
+	return int(math.Max(1, math.Ceil(time.Until(until).Seconds())))
+}
+
+// Kayıt yazılamazsa istek yine de devam eder
+func (app *App) audit(r *http.Request, event database.AuditEvent) {
+	event.IP = app.clientIP(r)
+	event.UserAgent = truncate(r.UserAgent(), 256)
+	if err := app.Database.Audit.Add(context.Background(), event); err != nil {
+		log.Println("[audit]", err)
+	}
+}
+
+func (app *App) LockoutList(w http.ResponseWriter, r *http.Request) {
+	locks, err := app.Database.LoginLocks.ListLocked(context.Background(), time.Now())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	response := make([]LockoutResponse, 0, len(locks))
+	for _, lock := range locks {
+		response = append(response, LockoutResponse{
+			Key:         lock.Key,
+			Failures:    lock.Failures,
+			LockedUntil: *lock.LockedUntil,
+			RetryAfter:  retryAfter(*lock.LockedUntil),
+		})
+	}
+	sendResponse(w, http.StatusOK, response)
+}
+
+// Hesabın kilidini açar ve sayacını sıfırlar
+func (app *App) UserUnlock(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+	app.unlock(w, r, accountLockKey(user.Username), user.ID)
+}
+
+// Paylaşılan bir ağdan gelen kullanıcılar kilitlendiyse
+func (app *App) IPUnlock(w http.ResponseWriter, r *http.Request) {
+	app.unlock(w, r, ipLockKey(chi.URLParam(r, "ip")), "")
+}
+
+func (app *App) unlock(w http.ResponseWriter, r *http.Request, key string, user_id string) {
+	admin, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	removed, err := app.Database.LoginLocks.Reset(context.Background(), key)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if removed == 0 {
+		sendResponse(w, http.StatusNotFound, map[string]string{"error": "no failed login attempts recorded"})
+		return
+	}
+	app.audit(r, database.AuditEvent{Action: database.AuditLoginUnlock, UserID: user_id, ActorID: admin.ID, Subject: key})
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// ?action=login.failed&username=...&ip=... ile filtrelenebilir
+func (app *App) AuditList(w http.ResponseWriter, r *http.Request) {
+	limit, offset := paginationParams(r, 50)
+	query := r.URL.Query()
+	filter := database.AuditFilter{
+		Action: query.Get("action"),
+		IP:     query.Get("ip"),
+	}
+	if username := query.Get("username"); username != "" {
+		filter.Subjects = []string{loginName(username), accountLockKey(username)}
+		user, err := app.Database.Users.FindByUsername(context.Background(), username)
+		if err == nil {
+			filter.UserID = user.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+	}
+	events, err := app.Database.Audit.List(context.Background(), filter, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if events == nil {
+		events = []database.AuditEvent{}
+	}
+	sendResponse(w, http.StatusOK, events)
+}
+
+func (app *App) purgeLoginLocks(ctx context.Context) {
+	purged, err := app.Database.LoginLocks.Purge(ctx, time.Now().Add(-accountLockout.Window))
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("[scheduler] Purged %d login attempt counters", purged)
+	}
+}
+
+func (app *App) purgeAuditEvents(ctx context.Context) {
+	purged, err := app.Database.Audit.Purge(ctx, time.Now().Add(-auditRetention))
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("[scheduler] Purged %d audit events", purged)
+	}
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type NotificationResponse struct {
+	ID          string    `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Kind        string    `json:"kind"`
+	Message     string    `json:"message"`
+	Read        bool      `json:"read"`
+	ProjectSlug string    `json:"project_slug,omitempty"`
+	ChapterSlug string    `json:"chapter_slug,omitempty"`
+	CommentID   *string   `json:"comment_id,omitempty"`
+	TimeAgo     string    `json:"time_ago"`
+}
+
+func newNotificationResponse(notification database.Notification) NotificationResponse {
+	response := NotificationResponse{
+		ID:        notification.ID,
+		CreatedAt: notification.CreatedAt,
+		Kind:      notification.Kind,
+		Message:   notification.Message,
+		Read:      notification.ReadAt != nil,
+		CommentID: notification.CommentID,
+		TimeAgo:   timeAgo(notification.CreatedAt),
+	}
+	if notification.Project != nil {
+		response.ProjectSlug = notification.Project.Slug
+	}
+	if notification.Chapter != nil {
+		response.ChapterSlug = notification.Chapter.Slug
+	}
+	return response
+}
+
+// ?unread=1 ile sadece okunmamışlar
+func (app *App) NotificationList(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	limit, offset := paginationParams(r, 50)
+	unread := isTruthy(r.URL.Query().Get("unread"))
+	notifications, err := app.Database.Notifications.List(context.Background(), user.ID, unread, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	responses := []NotificationResponse{}
+	for _, notification := range notifications {
+		responses = append(responses, newNotificationResponse(notification))
+	}
+	sendResponse(w, http.StatusOK, responses)
+}
+
+func (app *App) NotificationUnreadCount(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	count, err := app.Database.Notifications.CountUnread(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, map[string]int64{"count": count})
+}
+
+func (app *App) NotificationRead(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	err = app.Database.Notifications.MarkRead(context.Background(), user.ID, chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) NotificationReadAll(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Notifications.MarkAllRead(context.Background(), user.ID); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
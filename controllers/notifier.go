@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+)
+
+const notificationQueueSize = 256
+
+// Kuyruk doluysa istek bu kadar bekler, yer açılmazsa bildirim isteğin içinde dağıtılır
+const notificationEnqueueTimeout = 2 * time.Second
+
+// Tek bir olayın bütün alıcılara dağıtılması için verilen süre
+const notificationTimeout = 30 * time.Second
+
+type notificationEvent struct {
+	notification database.Notification
+	// Alıcılar worker içinde hesaplanır, takipçi listesi büyük olabilir
+	recipients func(ctx context.Context) ([]string, error)
+	// Olayı tetikleyen kullanıcı kendine bildirim almaz
+	actorID string
+}
+
+// Bildirimleri arka planda dağıtır. App.Shutdown server kapandıktan sonra durdurur,
+// worker kuyrukta kalanları dağıtıp biter.
+func (app *App) startNotifier() {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.stopNotifier = cancel
+	app.notifierDone = make(chan struct{})
+	go func() {
+		defer close(app.notifierDone)
+		for {
+			select {
+			case event := <-app.notifications:
+				app.deliver(event)
+			case <-ctx.Done():
+				// Kilit alındıktan sonra kuyruğa yeni olay girmez, kalanlar son kez boşaltılır
+				app.notifierMu.Lock()
+				app.notifierStopped = true
+				app.notifierMu.Unlock()
+				for {
+					select {
+					case event := <-app.notifications:
+						app.deliver(event)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (app *App) enqueueNotification(event notificationEvent) {
+	if !app.queueNotification(event) {
+		app.deliver(event)
+	}
+}
+
+// Olayı kuyruğa koyar, worker durmuşsa ya da kuyruk dolu kalırsa false döner
+func (app *App) queueNotification(event notificationEvent) bool {
+	app.notifierMu.RLock()
+	defer app.notifierMu.RUnlock()
+	if app.notifierStopped {
+		return false
+	}
+	select {
+	case app.notifications <- event:
+		return true
+	default:
+	}
+	timer := time.NewTimer(notificationEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case app.notifications <- event:
+		return true
+	case <-timer.C:
+		log.Printf("[notifier] Queue full, delivering %s notification inline", event.notification.Kind)
+		return false
+	}
+}
+
+// Server'ı kapatır, istekler bitince worker'ı durdurur ve kuyrukta kalan bildirimlerin
+// dağıtılmasını ctx bitene kadar bekler
+func (app *App) Shutdown(ctx context.Context) error {
+	err := app.Server.Shutdown(ctx)
+	if app.notifierDone == nil {
+		return err
+	}
+	app.stopNotifier()
+	select {
+	case <-app.notifierDone:
+	case <-ctx.Done():
+		log.Println("[notifier] Shutdown timed out before the queue was drained")
+	}
+	return err
+}
+
+func (app *App) deliver(event notificationEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+	user_ids, err := event.recipients(ctx)
+	if err != nil {
+		log.Println("[notifier]", err)
+		return
+	}
+	recipients := make([]string, 0, len(user_ids))
+	for _, user_id := range user_ids {
+		if user_id != "" && user_id != event.actorID {
+			recipients = append(recipients, user_id)
+		}
+	}
+	notifications, err := app.Database.Notifications.FanOut(ctx, event.notification, recipients)
+	if err != nil {
+		log.Println("[notifier]", err)
+		return
+	}
+	if len(notifications) == 0 {
+		return
+	}
+	app.linkNotification(ctx, &event.notification)
+	for _, notification := range notifications {
+		notification.Project = event.notification.Project
+		notification.Chapter = event.notification.Chapter
+		app.publishEvent(notification.UserID, notification.Kind, newNotificationResponse(notification))
+	}
+}
+
+// Event stream'e gidecek slug'lar için proje ve bölümü yükler
+func (app *App) linkNotification(ctx context.Context, notification *database.Notification) {
+	if notification.Project == nil && notification.ProjectID != nil {
+		if project, err := app.Database.Projects.Find(ctx, *notification.ProjectID); err == nil {
+			notification.Project = &project
+		}
+	}
+	if notification.Chapter == nil && notification.ChapterID != nil {
+		if chapter, err := app.Database.Chapters.Find(ctx, *notification.ChapterID); err == nil {
+			notification.Chapter = &chapter
+		}
+	}
+}
+
+func (app *App) followersOf(project_id string) func(ctx context.Context) ([]string, error) {
+	return func(ctx context.Context) ([]string, error) {
+		return app.Database.Library.Followers(ctx, project_id)
+	}
+}
+
+func (app *App) notifyNewChapter(chapter database.Chapter, project database.Project, actor_id string) {
+	app.enqueueNotification(notificationEvent{
+		notification: database.Notification{
+			Kind:      database.NotificationNewChapter,
+			Message:   truncate(fmt.Sprintf("New chapter in %s: %s", project.Title, chapter.Title), 512),
+			ProjectID: &chapter.ProjectID,
+			Project:   &project,
+			ChapterID: &chapter.ID,
+			Chapter:   &chapter,
+		},
+		recipients: app.followersOf(project.ID),
+		actorID:    actor_id,
+	})
+}
+
+func (app *App) notifyCommentReply(reply database.Comment, parent database.Comment, author database.User) {
+	name := author.Name
+	if name == "" {
+		name = author.Username
+	}
+	app.enqueueNotification(notificationEvent{
+		notification: database.Notification{
+			Kind:      database.NotificationCommentReply,
+			Message:   truncate(fmt.Sprintf("%s replied to your comment", name), 512),
+			ProjectID: reply.ProjectID,
+			ChapterID: reply.ChapterID,
+			CommentID: &reply.ID,
+		},
+		recipients: func(ctx context.Context) ([]string, error) {
+			return []string{parent.UserID}, nil
+		},
+		actorID: author.ID,
+	})
+}
+
+func (app *App) notifyProjectStatus(project database.Project, actor_id string) {
+	app.enqueueNotification(notificationEvent{
+		notification: database.Notification{
+			Kind:      database.NotificationProjectStatus,
+			Message:   truncate(fmt.Sprintf("%s is now %s", project.Title, project.Status), 512),
+			ProjectID: &project.ID,
+			Project:   &project,
+		},
+		recipients: app.followersOf(project.ID),
+		actorID:    actor_id,
+	})
+}
+
+func (app *App) notifyTeamInvite(invitation database.ProjectInvitation, project database.Project, inviter database.User) {
+	name := inviter.Name
+	if name == "" {
+		name = inviter.Username
+	}
+	app.enqueueNotification(notificationEvent{
+		notification: database.Notification{
+			Kind:      database.NotificationTeamInvite,
+			Message:   truncate(fmt.Sprintf("%s invited you to join %s as %s", name, project.Title, invitation.Role), 512),
+			ProjectID: &project.ID,
+			Project:   &project,
+		},
+		recipients: func(ctx context.Context) ([]string, error) {
+			return []string{invitation.UserID}, nil
+		},
+		actorID: inviter.ID,
+	})
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-3]) + "..."
+}
@@ -0,0 +1,404 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/oidc"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Kullanıcının sağlayıcıda girişi tamamlaması için verilen süre
+const authStateTTL = 10 * time.Minute
+
+var (
+	providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+	usernameUnsafe      = regexp.MustCompile(`[^a-z0-9_.-]+`)
+)
+
+type OIDCStartResponse struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+type OIDCCallbackRequestBody struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type ProviderResponse struct {
+	Name string `json:"name"`
+}
+
+// OIDC_PROVIDERS=google,corp ve her biri için OIDC_GOOGLE_ISSUER, OIDC_GOOGLE_CLIENT_ID, OIDC_GOOGLE_CLIENT_SECRET.
+// OIDC_GOOGLE_REDIRECT_URL verilmezse frontend'in SITE_URL/auth/google/callback adresi kullanılır.
+func newOIDCProviders(siteURL string) (map[string]*oidc.Client, error) {
+	providers := make(map[string]*oidc.Client)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q", name)
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := oidc.Config{
+			Name:         name,
+			Issuer:       strings.TrimSpace(os.Getenv(prefix + "ISSUER")),
+			ClientID:     strings.TrimSpace(os.Getenv(prefix + "CLIENT_ID")),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  strings.TrimSpace(os.Getenv(prefix + "REDIRECT_URL")),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+		if config.RedirectURL == "" {
+			config.RedirectURL = siteURL + "/auth/" + name + "/callback"
+		}
+		providers[name] = oidc.NewClient(config)
+	}
+	return providers, nil
+}
+
+func (app *App) ProviderList(w http.ResponseWriter, r *http.Request) {
+	providers := make([]ProviderResponse, 0, len(app.OIDC))
+	for name := range app.OIDC {
+		providers = append(providers, ProviderResponse{Name: name})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	sendResponse(w, http.StatusOK, providers)
+}
+
+// Sağlayıcının giriş sayfasının adresini döner. Frontend state'i saklayıp callback'te karşılaştırmalı.
+func (app *App) OIDCStart(w http.ResponseWriter, r *http.Request) {
+	app.startAuthFlow(w, r, "")
+}
+
+// Giriş yapmış kullanıcının hesabına harici hesap bağlamak için. Callback aynı kullanıcıyla çağrılmalı.
+func (app *App) OIDCLinkStart(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	app.startAuthFlow(w, r, user.ID)
+}
+
+func (app *App) startAuthFlow(w http.ResponseWriter, r *http.Request, user_id string) {
+	client, ok := app.OIDC[chi.URLParam(r, "provider")]
+	if !ok {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	var values [3]string
+	for i := range values {
+		value, err := oidc.RandomString()
+		if err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+	url, err := client.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		sendResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	err = app.Database.Identities.CreateState(context.Background(), database.AuthState{
+		StateHash: authentication.HashToken(state),
+		Provider:  client.Name,
+		Nonce:     nonce,
+		Verifier:  verifier,
+		UserID:    user_id,
+		ExpiresAt: time.Now().Add(authStateTTL),
+	})
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, OIDCStartResponse{URL: url, State: state})
+}
+
+// Sağlayıcının frontend'e döndürdüğü code ve state ile çağrılır. Girişte LoginHandler gibi token
+// (ya da iki adımlı doğrulama açıksa challenge) döner, hesap bağlamada bağlanan hesabı döner.
+func (app *App) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.OIDC[chi.URLParam(r, "provider")]
+	if !ok {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	body, err := getRequestBody[OIDCCallbackRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if body.Code == "" || body.State == "" {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "code and state are required"})
+		return
+	}
+	state, err := app.Database.Identities.TakeState(context.Background(), client.Name, authentication.HashToken(body.State))
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidToken) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid or expired state"})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if state.UserID != "" {
+		current, ok := optionalUser(app.Database.Users, r.Context())
+		if !ok || current.ID != state.UserID {
+			sendResponse(w, http.StatusForbidden, map[string]string{"error": "account linking must be finished by the same user"})
+			return
+		}
+	}
+	raw, err := client.Exchange(r.Context(), body.Code, state.Verifier)
+	if err != nil {
+		sendResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	claims, err := client.Verify(r.Context(), raw, state.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrorInvalidToken) {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if state.UserID != "" {
+		identity, err := app.linkIdentity(state.UserID, client.Name, claims)
+		if err != nil {
+			sendIdentityError(w, err)
+			return
+		}
+		sendResponse(w, http.StatusOK, identity)
+		return
+	}
+	user, err := app.identityUser(client.Name, claims)
+	if err != nil {
+		sendIdentityError(w, err)
+		return
+	}
+	app.finishLogin(w, r, user)
+}
+
+func (app *App) IdentityList(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	identities, err := app.Database.Identities.List(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if identities == nil {
+		identities = []database.Identity{}
+	}
+	sendResponse(w, http.StatusOK, identities)
+}
+
+// Sağlayıcıyla açılan hesaplar da rastgele bir şifreyle oluşturulur, bağlantı kalkınca şifre sıfırlanarak girilebilir
+func (app *App) IdentityUnlink(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Identities.Unlink(context.Background(), user.ID, chi.URLParam(r, "id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) linkIdentity(user_id string, provider string, claims oidc.Claims) (database.Identity, error) {
+	return app.Database.Identities.Link(context.Background(), database.Identity{
+		UserID:   user_id,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+}
+
+// Bağlı hesap varsa sahibi, yoksa doğrulanmış e-postası aynı olan kullanıcı, o da yoksa yeni kullanıcı.
+// Yerelde doğrulanmamış bir adres otomatik bağlanmaz, aksi halde o adresle önceden kayıt açan biri hesabı ele geçirebilir.
+func (app *App) identityUser(provider string, claims oidc.Claims) (database.User, error) {
+	identity, err := app.Database.Identities.Find(context.Background(), provider, claims.Subject)
+	if err == nil {
+		return app.Database.Users.Find(context.Background(), identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return database.User{}, err
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return database.User{}, errUnverifiedProviderEmail
+	}
+	user, err := app.Database.Users.FindByEmail(context.Background(), claims.Email)
+	switch {
+	case err == nil && user.EmailVerifiedAt == nil:
+		return user, errEmailNeedsLinking
+	case err == nil:
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = app.createIdentityUser(claims)
+		if err != nil {
+			return user, err
+		}
+	default:
+		return user, err
+	}
+	_, err = app.linkIdentity(user.ID, provider, claims)
+	return user, err
+}
+
+var (
+	errUnverifiedProviderEmail = errors.New("provider did not return a verified email address")
+	errEmailNeedsLinking       = errors.New("an account with this email already exists, log in and link the provider from your account settings")
+)
+
+func (app *App) createIdentityUser(claims oidc.Claims) (database.User, error) {
+	password, err := oidc.RandomString()
+	if err != nil {
+		return database.User{}, err
+	}
+	name := strings.TrimSpace(claims.Name)
+	if len(name) < 3 || len(name) > 128 {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	if len(name) < 3 {
+		name = "Reader"
+	}
+	username, err := app.availableUsername(claims)
+	if err != nil {
+		return database.User{}, err
+	}
+	now := time.Now()
+	err = app.Database.Users.Add(context.Background(), database.User{
+		Username:        username,
+		Email:           claims.Email,
+		Name:            truncate(name, 128),
+		Password:        password,
+		EmailVerifiedAt: &now,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return app.Database.Users.FindByUsername(context.Background(), username)
+}
+
+// preferred_username ya da e-postanın @ öncesi, alınmışsa sonuna sayı eklenir
+func (app *App) availableUsername(claims oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = strings.Trim(usernameUnsafe.ReplaceAllString(strings.ToLower(base), "-"), "-.")
+	base = truncate(base, 32)
+	for len(base) < 4 {
+		base += "0"
+	}
+	for i := 0; i < 100; i++ {
+		username := base
+		if i > 0 {
+			username = fmt.Sprintf("%s%d", base, i+1)
+		}
+		_, err := app.Database.Users.FindByUsername(context.Background(), username)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return username, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("could not find a free username")
+}
+
+// LoginHandler'daki gibi, iki adımlı doğrulama açıksa sağlayıcıyla girişte de kod istenir
+func (app *App) finishLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	enabled, err := app.Database.TwoFactor.IsEnabled(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if enabled {
+		challenge, err := app.twoFactorChallenge(user)
+		if err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
+			log.Println(err)
+			return
+		}
+		sendResponse(w, http.StatusOK, challenge)
+		return
+	}
+	response, err := app.startSession(r, user)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
+		log.Println(err)
+		return
+	}
+	app.resetLoginLock(user.Username)
+	sendResponse(w, http.StatusOK, response)
+}
+
+func sendIdentityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrorIdentityLinked), errors.Is(err, errEmailNeedsLinking):
+		sendResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, errUnverifiedProviderEmail):
+		sendResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		sendResponse(w, http.StatusUnauthorized, map[string]string{"error": "linked account no longer exists"})
+	default:
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	log.Println(err)
+}
+
+func (app *App) purgeAuthStates(ctx context.Context) {
+	purged, err := app.Database.Identities.PurgeStates(ctx, time.Now())
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("[scheduler] Purged %d expired login states", purged)
+	}
+}
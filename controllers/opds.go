@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/feed"
+	"github.com/go-chi/chi/v5"
+)
+
+// OPDS acquisition feed'lerinde sayfa başına proje
+const opdsPageSize = 25
+
+// Arama index'inden çekilen en fazla sonuç, projeler bunun içinden süzülür
+const opdsSearchLimit = 100
+
+func (app *App) OPDSRoot(w http.ResponseWriter, r *http.Request) {
+	base := app.SiteURL
+	catalog := app.opdsCatalog(r, "root", "BatNovels", feed.NavigationType)
+	catalog.Updated = time.Now()
+	catalog.Entries = []feed.CatalogEntry{
+		app.opdsNavigationEntry("latest", "Latest", "Projects with new chapters", base+"/opds/latest", feed.AcquisitionType, feed.RelNew),
+		app.opdsNavigationEntry("featured", "Featured", "Most read projects", base+"/opds/featured", feed.AcquisitionType, feed.RelPopular),
+		app.opdsNavigationEntry("tags", "By tag", "Browse projects by tag", base+"/opds/tags", feed.NavigationType, feed.RelSubsection),
+		app.opdsNavigationEntry("authors", "By author", "Browse projects by author", base+"/opds/authors", feed.NavigationType, feed.RelSubsection),
+	}
+	for i := range catalog.Entries {
+		catalog.Entries[i].Updated = catalog.Updated
+	}
+	serveCatalog(w, r, catalog)
+}
+
+func (app *App) OPDSLatest(w http.ResponseWriter, r *http.Request) {
+	limit, offset := paginationParams(r, opdsPageSize)
+	projects, err := app.Database.Projects.ListLatest(context.Background(), limit+1, offset, false)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	app.serveAcquisition(w, r, app.opdsCatalog(r, "latest", "Latest", feed.AcquisitionType), projects, limit, offset)
+}
+
+func (app *App) OPDSFeatured(w http.ResponseWriter, r *http.Request) {
+	limit, offset := paginationParams(r, opdsPageSize)
+	projects, err := app.Database.Projects.List(context.Background(), limit+1, offset, "views desc, created_at desc")
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	app.serveAcquisition(w, r, app.opdsCatalog(r, "featured", "Featured", feed.AcquisitionType), projects, limit, offset)
+}
+
+func (app *App) OPDSTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := app.Database.Projects.Tags(context.Background())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	base := app.SiteURL
+	catalog := app.opdsCatalog(r, "tags", "By tag", feed.NavigationType)
+	catalog.Updated = time.Now()
+	for _, tag := range tags {
+		entry := app.opdsNavigationEntry("tag:"+tag.Tag, tag.Tag, projectCount(tag.Count),
+			base+"/opds/tags/"+url.PathEscape(tag.Tag), feed.AcquisitionType, feed.RelSubsection)
+		entry.Updated = catalog.Updated
+		catalog.Entries = append(catalog.Entries, entry)
+	}
+	serveCatalog(w, r, catalog)
+}
+
+func (app *App) OPDSTag(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	limit, offset := paginationParams(r, opdsPageSize)
+	projects, err := app.Database.Projects.ListByTag(context.Background(), tag, limit+1, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if len(projects) == 0 && offset == 0 {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	app.serveAcquisition(w, r, app.opdsCatalog(r, "tag:"+strings.ToLower(tag), "Tag: "+tag, feed.AcquisitionType), projects, limit, offset)
+}
+
+func (app *App) OPDSAuthors(w http.ResponseWriter, r *http.Request) {
+	authors, err := app.Database.Projects.Authors(context.Background())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	base := app.SiteURL
+	catalog := app.opdsCatalog(r, "authors", "By author", feed.NavigationType)
+	catalog.Updated = time.Now()
+	for _, author := range authors {
+		entry := app.opdsNavigationEntry("author:"+author.Author, author.Author, projectCount(author.Count),
+			base+"/opds/authors/"+url.PathEscape(author.Author), feed.AcquisitionType, feed.RelSubsection)
+		entry.Updated = catalog.Updated
+		catalog.Entries = append(catalog.Entries, entry)
+	}
+	serveCatalog(w, r, catalog)
+}
+
+func (app *App) OPDSAuthor(w http.ResponseWriter, r *http.Request) {
+	author := chi.URLParam(r, "author")
+	limit, offset := paginationParams(r, opdsPageSize)
+	projects, err := app.Database.Projects.ListByAuthor(context.Background(), author, limit+1, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if len(projects) == 0 && offset == 0 {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	app.serveAcquisition(w, r, app.opdsCatalog(r, "author:"+author, "Author: "+author, feed.AcquisitionType), projects, limit, offset)
+}
+
+// Arama index'i bölümleri de döndürdüğü için sadece projeler alınır, sayfalama süzülmüş liste üzerinde yapılır
+func (app *App) OPDSSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	limit, offset := paginationParams(r, opdsPageSize)
+	results, err := app.Database.Search.Search(context.Background(), query, opdsSearchLimit, 0)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	var projects []database.Project
+	skipped := 0
+	for _, result := range results {
+		if result.Kind != "project" {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		if len(projects) > limit {
+			break
+		}
+		project, err := app.Database.Projects.Find(context.Background(), result.ID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		projects = append(projects, project)
+	}
+	app.serveAcquisition(w, r, app.opdsCatalog(r, "search", "Search: "+query, feed.AcquisitionType), projects, limit, offset)
+}
+
+func (app *App) OPDSOpenSearch(w http.ResponseWriter, r *http.Request) {
+	description := feed.OpenSearchDescription{
+		ShortName:   "BatNovels",
+		Description: "Search projects on BatNovels",
+		Template:    app.SiteURL + "/opds/search?q={searchTerms}",
+	}
+	w.Header().Set("Content-Type", feed.OpenSearchType+"; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if err := description.Write(w); err != nil {
+		log.Println(err)
+	}
+}
+
+// Her katalogda olması gereken self, start ve search linkleri
+func (app *App) opdsCatalog(r *http.Request, id string, title string, kind string) feed.Catalog {
+	base := app.SiteURL
+	return feed.Catalog{
+		ID:     "tag:" + app.siteHost() + ",2024:opds:" + id,
+		Title:  title,
+		Author: "BatNovels",
+		Links: []feed.Link{
+			{Rel: "self", Type: kind, Href: app.requestURL(r)},
+			{Rel: feed.RelStart, Type: feed.NavigationType, Href: base + "/opds", Title: "BatNovels"},
+			{Rel: feed.RelSearch, Type: feed.OpenSearchType, Href: base + "/opds/opensearch.xml"},
+		},
+	}
+}
+
+func (app *App) opdsNavigationEntry(id string, title string, content string, href string, kind string, rel string) feed.CatalogEntry {
+	return feed.CatalogEntry{
+		ID:      "tag:" + app.siteHost() + ",2024:opds:" + id,
+		Title:   title,
+		Content: content,
+		Links:   []feed.Link{{Rel: rel, Type: kind, Href: href}},
+	}
+}
+
+// limit+1 proje çekilir, fazlası varsa next linki eklenir
+func (app *App) serveAcquisition(w http.ResponseWriter, r *http.Request, catalog feed.Catalog, projects []database.Project, limit int, offset int) {
+	if len(projects) > limit {
+		projects = projects[:limit]
+		catalog.Links = append(catalog.Links, feed.Link{Rel: "next", Type: feed.AcquisitionType, Href: app.pageURL(r, limit, offset+limit)})
+	}
+	if offset > 0 {
+		catalog.Links = append(catalog.Links, feed.Link{Rel: "previous", Type: feed.AcquisitionType, Href: app.pageURL(r, limit, max(offset-limit, 0))})
+	}
+	base := app.SiteURL
+	for _, project := range projects {
+		catalog.Entries = append(catalog.Entries, app.projectEntry(base, project))
+		if project.UpdatedAt.After(catalog.Updated) {
+			catalog.Updated = project.UpdatedAt
+		}
+	}
+	if catalog.Updated.IsZero() {
+		catalog.Updated = time.Now()
+	}
+	serveCatalog(w, r, catalog)
+}
+
+func (app *App) projectEntry(base string, project database.Project) feed.CatalogEntry {
+	entry := feed.CatalogEntry{
+		ID:        "urn:uuid:" + project.ID,
+		Title:     project.Title,
+		Author:    project.Author,
+		Summary:   project.Synopsis,
+		Published: project.CreatedAt,
+		Updated:   project.UpdatedAt,
+		Links: []feed.Link{
+			{Rel: feed.RelAcquisition, Type: "application/epub+zip", Href: base + "/api/project/" + url.PathEscape(project.Slug) + "/export.epub"},
+			{Rel: "alternate", Type: "text/html", Href: app.SiteURL + "/project/" + project.Slug},
+		},
+	}
+	for _, tag := range strings.Split(project.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			entry.Categories = append(entry.Categories, tag)
+		}
+	}
+	if strings.HasPrefix(project.Image, "http://") || strings.HasPrefix(project.Image, "https://") {
+		kind := mime.TypeByExtension(path.Ext(strings.SplitN(project.Image, "?", 2)[0]))
+		entry.Links = append(entry.Links,
+			feed.Link{Rel: feed.RelImage, Type: kind, Href: project.Image},
+			feed.Link{Rel: feed.RelThumbnail, Type: kind, Href: project.Image},
+		)
+	}
+	return entry
+}
+
+func serveCatalog(w http.ResponseWriter, r *http.Request, catalog feed.Catalog) {
+	kind := feed.NavigationType
+	if len(catalog.Links) > 0 {
+		kind = catalog.Links[0].Type
+	}
+	serveFeed(w, r, kind+"; charset=utf-8", catalog.Updated, catalog.WriteOPDS)
+}
+
+func (app *App) pageURL(r *http.Request, limit int, offset int) string {
+	query := r.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	return app.SiteURL + r.URL.Path + "?" + query.Encode()
+}
+
+func projectCount(count int64) string {
+	if count == 1 {
+		return "1 project"
+	}
+	return fmt.Sprintf("%d projects", count)
+}
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type ProgressRequestBody struct {
+	Chapter  string  `json:"chapter"` // bölüm slug'ı
+	Position float64 `json:"position"`
+}
+
+type ProgressResponse struct {
+	Project   ChapterProjectInfo   `json:"project"`
+	Chapter   database.ChapterLink `json:"chapter"`
+	Number    float64              `json:"number"`
+	Position  float64              `json:"position"`
+	UpdatedAt time.Time            `json:"updated_at"`
+	TimeAgo   string               `json:"time_ago"`
+}
+
+func newProgressResponse(progress database.ReadingProgress) ProgressResponse {
+	return ProgressResponse{
+		Project: projectInfo(progress.Project),
+		Chapter: database.ChapterLink{
+			Slug:  progress.Chapter.Slug,
+			Title: progress.Chapter.Title,
+		},
+		Number:    progress.Chapter.Number,
+		Position:  progress.Position,
+		UpdatedAt: progress.UpdatedAt,
+		TimeAgo:   timeAgo(progress.UpdatedAt),
+	}
+}
+
+// Silinmiş ya da gizlenmiş bölümlere ait kayıtlar listelerde gösterilmez
+func progressResponses(progresses []database.ReadingProgress) []ProgressResponse {
+	responses := []ProgressResponse{}
+	for _, progress := range progresses {
+		if progress.Chapter.ID == "" || progress.Project.ID == "" || !progress.Chapter.IsPublic() {
+			continue
+		}
+		responses = append(responses, newProgressResponse(progress))
+	}
+	return responses
+}
+
+// Her projede kalınan yer, "okumaya devam et" listesi için
+func (app *App) ProgressList(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	limit, _ := paginationParams(r, 20)
+	progresses, err := app.Database.Progress.ContinueReading(context.Background(), user.ID, limit)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, progressResponses(progresses))
+}
+
+// Okuyucu sayfası scroll ettikçe (debounce ile) çağrılır
+func (app *App) ProgressUpdate(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[ProgressRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	chapter, err := app.Database.Chapters.FindBySlug(context.Background(), body.Chapter)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if !app.canView(r, chapter) {
+		sendResponse(w, http.StatusNotFound, nil)
+		return
+	}
+	progress, err := app.Database.Progress.Save(context.Background(), database.ReadingProgress{
+		UserID:    user.ID,
+		ChapterID: chapter.ID,
+		ProjectID: chapter.ProjectID,
+		Position:  body.Position,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidProgress) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	progress.Chapter = chapter
+	progress.Project = chapter.Project
+	sendResponse(w, http.StatusOK, newProgressResponse(progress))
+}
+
+func (app *App) ProjectProgress(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	progress, err := app.Database.Progress.Find(context.Background(), user.ID, project.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newProgressResponse(progress))
+}
+
+func (app *App) ProjectProgressClear(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Progress.ClearProject(context.Background(), user.ID, project.ID); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) ReadingHistory(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	limit, offset := paginationParams(r, 50)
+	progresses, err := app.Database.Progress.History(context.Background(), user.ID, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, progressResponses(progresses))
+}
+
+func (app *App) ReadingHistoryClear(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Progress.Clear(context.Background(), user.ID); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
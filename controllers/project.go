@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/batt0s/batnovels/database"
@@ -25,6 +26,16 @@ type ProjectRequestBody struct {
 	Image     string    `json:"image"`
 }
 
+// PATCH için, sadece gönderilen alanlar değişir
+type ProjectUpdateRequestBody struct {
+	Title    *string `json:"title"`
+	Synopsis *string `json:"synopsis"`
+	Author   *string `json:"author"`
+	Status   *string `json:"status"`
+	Tags     *string `json:"tags"`
+	Image    *string `json:"image"`
+}
+
 func (app *App) ProjectList(w http.ResponseWriter, r *http.Request) {
 	var projects []database.Project
 	var err error
@@ -41,10 +52,15 @@ func (app *App) ProjectList(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, http.StatusOK, projects)
 }
 
+// ?sort=rating ile izlenmeye göre değil Bayes ağırlıklı puana göre sıralar
 func (app *App) FeaturedProjectList(w http.ResponseWriter, r *http.Request) {
 	var projects []database.Project
 	var err error
-	projects, err = app.Database.Projects.List(context.Background(), 100, 0, "views desc, created_at desc")
+	if r.URL.Query().Get("sort") == "rating" {
+		projects, err = app.Database.Projects.ListByRating(context.Background(), 100, 0)
+	} else {
+		projects, err = app.Database.Projects.List(context.Background(), 100, 0, "views desc, created_at desc")
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			sendResponse(w, http.StatusNotFound, nil)
@@ -58,21 +74,14 @@ func (app *App) FeaturedProjectList(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *App) LatestProjectList(w http.ResponseWriter, r *http.Request) {
-	var projects []database.Project
-	results := app.Database.DB.Table("projects").
-		Select("projects.*, MAX(chapters.created_at) as last_chapter_created_at").
-		Joins("JOIN chapters ON chapters.project_id = projects.id").
-		Where("chapters.deleted_at IS NULL").
-		Group("projects.id").
-		Order("last_chapter_created_at DESC").
-		Find(&projects)
-	if results.Error != nil {
-		if errors.Is(results.Error, gorm.ErrRecordNotFound) {
+	projects, err := app.Database.Projects.ListLatest(context.Background(), -1, 0, app.optionalCan(r, database.PermissionChapterEdit))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
 			sendResponse(w, http.StatusNotFound, nil)
 		} else {
-			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": results.Error.Error()})
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
-		log.Println(results.Error)
+		log.Println(err)
 		return
 	}
 	sendResponse(w, http.StatusOK, projects)
@@ -106,10 +115,6 @@ func (app *App) ProjectAdd(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	if !user.IsStaff {
-		sendResponse(w, http.StatusUnauthorized, nil)
-		return
-	}
 	body, err := getRequestBody[ProjectRequestBody](w, r)
 	if err != nil {
 		var mr *malformedRequest
@@ -129,7 +134,8 @@ func (app *App) ProjectAdd(w http.ResponseWriter, r *http.Request) {
 		Tags:     body.Tags,
 		Image:    body.Image,
 	}
-	project, err = app.Database.Projects.Add(context.Background(), project)
+	// Projeyi açan kullanıcı sahibi olur
+	project, err = app.Database.Projects.Add(context.Background(), project, user.ID)
 	if err != nil {
 		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		log.Println(err)
@@ -137,3 +143,141 @@ func (app *App) ProjectAdd(w http.ResponseWriter, r *http.Request) {
 	}
 	sendResponse(w, http.StatusOK, project)
 }
+
+// Projenin durumunu (ongoing, completed, hiatus ...) değiştirir ve takipçilere bildirir
+func (app *App) ProjectStatusUpdate(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[ProjectRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	status := strings.TrimSpace(body.Status)
+	if status == "" || len(status) > 64 {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+		return
+	}
+	if status == project.Status {
+		sendResponse(w, http.StatusOK, project)
+		return
+	}
+	project.Status = status
+	project, err = app.Database.Projects.Update(context.Background(), project)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	app.notifyProjectStatus(project, user.ID)
+	sendResponse(w, http.StatusOK, project)
+}
+
+// Başlık değişirse slug da değişir, eski slug yeni adrese yönlendirilir.
+// Durum değiştiyse takipçilere ProjectStatusUpdate gibi bildirilir.
+func (app *App) ProjectUpdate(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[ProjectUpdateRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	previous := project.Status
+	if body.Title != nil {
+		project.Title = strings.TrimSpace(*body.Title)
+	}
+	if body.Synopsis != nil {
+		project.Synopsis = strings.TrimSpace(*body.Synopsis)
+	}
+	if body.Author != nil {
+		project.Author = strings.TrimSpace(*body.Author)
+	}
+	if body.Status != nil {
+		project.Status = strings.TrimSpace(*body.Status)
+		if project.Status == "" || len(project.Status) > 64 {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid status"})
+			return
+		}
+	}
+	if body.Tags != nil {
+		project.Tags = *body.Tags
+	}
+	if body.Image != nil {
+		project.Image = *body.Image
+	}
+	project, err = app.Database.Projects.Update(context.Background(), project)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidProject) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if project.Status != previous {
+		app.notifyProjectStatus(project, user.ID)
+	}
+	sendResponse(w, http.StatusOK, project)
+}
+
+// Proje bölümleriyle birlikte silinir
+func (app *App) ProjectDelete(w http.ResponseWriter, r *http.Request) {
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Projects.Delete(context.Background(), project); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Eski slug'ın şimdiki karşılığını bulur
+type slugResolver func(ctx context.Context, slug string) (string, error)
+
+// Başlığı değiştiği için slug'ı değişen proje ve bölümlerin eski adreslerini yenisine yönlendirir.
+// Route eşleştikten sonra çalışması gerektiği için Group ya da With ile kullanılır.
+// GET ve HEAD için 301, diğer metotlarda gövde kaybolmasın diye 308 döner.
+func (app *App) RedirectOldSlug(resolve slugResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			slug := chi.URLParam(r, "slug")
+			current, err := resolve(r.Context(), slug)
+			if err != nil {
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					log.Println(err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			segments := strings.Split(r.URL.Path, "/")
+			for i, segment := range segments {
+				if segment == slug {
+					segments[i] = current
+					break
+				}
+			}
+			location := &url.URL{Path: strings.Join(segments, "/"), RawQuery: r.URL.RawQuery}
+			status := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				status = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, location.String(), status)
+		})
+	}
+}
+
+func (app *App) currentProjectSlug(ctx context.Context, slug string) (string, error) {
+	project, err := app.Database.Projects.FindByOldSlug(ctx, slug)
+	return project.Slug, err
+}
+
+func (app *App) currentChapterSlug(ctx context.Context, slug string) (string, error) {
+	chapter, err := app.Database.Chapters.FindByOldSlug(ctx, slug)
+	return chapter.Slug, err
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -17,6 +18,24 @@ func sendResponse(w http.ResponseWriter, statusCode int, payload interface{}) {
 	w.Write(response)
 }
 
+// Kaynak var ve kullanıcı görebiliyor ama izni yoksa. Göremediği kaynaklar için 404 dönülür.
+func sendForbidden(w http.ResponseWriter, permission string) {
+	sendResponse(w, http.StatusForbidden, map[string]string{"error": "missing permission " + permission})
+}
+
+// ?limit= ve ?offset= query parametrelerini okur
+func paginationParams(r *http.Request, defaultLimit int) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = defaultLimit
+	}
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
 // The source that helped me to write this helper: https://www.alexedwards.net/blog/how-to-properly-parse-a-json-request-body
 
 type malformedRequest struct {
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type ReviewRequestBody struct {
+	ID             string    `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Score          int       `json:"score"`
+	Content        string    `json:"content"`
+	Username       string    `json:"username"`
+	Name           string    `json:"name"`
+	HelpfulCount   int64     `json:"helpful_count"`
+	UnhelpfulCount int64     `json:"unhelpful_count"`
+	TimeAgo        string    `json:"time_ago"`
+}
+
+type ReviewListResponse struct {
+	Count         int64                `json:"count"`
+	RatingAverage float64              `json:"rating_average"`
+	Reviews       []*ReviewRequestBody `json:"reviews"`
+}
+
+type ReviewVoteRequestBody struct {
+	Helpful bool `json:"helpful"`
+}
+
+func newReviewRequestBody(review database.Review) *ReviewRequestBody {
+	return &ReviewRequestBody{
+		ID:             review.ID,
+		CreatedAt:      review.CreatedAt,
+		UpdatedAt:      review.UpdatedAt,
+		Score:          review.Score,
+		Content:        review.Content,
+		Username:       review.User.Username,
+		Name:           review.User.Name,
+		HelpfulCount:   review.HelpfulCount,
+		UnhelpfulCount: review.UnhelpfulCount,
+		TimeAgo:        timeAgo(review.CreatedAt),
+	}
+}
+
+// ?sort=helpful ile en faydalı bulunanlar, varsayılan olarak en yeniler başta
+func (app *App) ReviewList(w http.ResponseWriter, r *http.Request) {
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	orderby := database.ReviewOrderRecent
+	if r.URL.Query().Get("sort") == "helpful" {
+		orderby = database.ReviewOrderHelpful
+	}
+	limit, offset := paginationParams(r, 20)
+	reviews, err := app.Database.Reviews.List(context.Background(), project.ID, limit, offset, orderby)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	response := ReviewListResponse{
+		Count:         project.RatingCount,
+		RatingAverage: project.RatingAverage,
+		Reviews:       []*ReviewRequestBody{},
+	}
+	for _, review := range reviews {
+		response.Reviews = append(response.Reviews, newReviewRequestBody(review))
+	}
+	sendResponse(w, http.StatusOK, response)
+}
+
+// Kullanıcının bu projeye yazdığı review
+func (app *App) UserReview(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	review, err := app.Database.Reviews.FindByUser(context.Background(), user.ID, project.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newReviewRequestBody(review))
+}
+
+// Review yoksa ekler, varsa puanını ve metnini günceller
+func (app *App) ReviewSave(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[ReviewRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	review, err := app.Database.Reviews.Save(context.Background(), database.Review{
+		UserID:    user.ID,
+		ProjectID: project.ID,
+		Score:     body.Score,
+		Content:   body.Content,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidReview) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newReviewRequestBody(review))
+}
+
+func (app *App) ReviewDelete(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	review, err := app.Database.Reviews.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if review.UserID != user.ID && !app.can(r, user, database.PermissionReviewModerate) {
+		sendForbidden(w, database.PermissionReviewModerate)
+		return
+	}
+	if err := app.Database.Reviews.Delete(context.Background(), review); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) ReviewVote(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	review, err := app.Database.Reviews.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[ReviewVoteRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	review, err = app.Database.Reviews.Vote(context.Background(), review, user.ID, body.Helpful)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidVote) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newReviewRequestBody(review))
+}
+
+func (app *App) ReviewUnvote(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	review, err := app.Database.Reviews.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	review, err = app.Database.Reviews.Unvote(context.Background(), review, user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newReviewRequestBody(review))
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/diff"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type RevisionRequestBody struct {
+	Number    int       `json:"number"`
+	CreatedAt time.Time `json:"created_at"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content,omitempty"`
+	Editor    string    `json:"editor"`
+	Note      string    `json:"note"`
+	TimeAgo   string    `json:"time_ago"`
+}
+
+type RevisionDiffResponse struct {
+	From      int    `json:"from"`
+	To        int    `json:"to"`
+	TitleFrom string `json:"title_from"`
+	TitleTo   string `json:"title_to"`
+	Diff      string `json:"diff"`
+}
+
+type RevisionRestoreRequestBody struct {
+	Note string `json:"note"`
+}
+
+func newRevisionRequestBody(revision database.ChapterRevision, withContent bool) RevisionRequestBody {
+	body := RevisionRequestBody{
+		Number:    revision.Number,
+		CreatedAt: revision.CreatedAt,
+		Title:     revision.Title,
+		Editor:    revision.Editor.Username,
+		Note:      revision.Note,
+		TimeAgo:   timeAgo(revision.CreatedAt),
+	}
+	if withContent {
+		body.Content = revision.Content
+	}
+	return body
+}
+
+// Revizyonlar yayınlanmamış düzenlemeleri de içerdiği için route'lar chapter.edit izni ister
+func (app *App) editableChapter(w http.ResponseWriter, r *http.Request) (database.User, database.Chapter, bool) {
+	var chapter database.Chapter
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return user, chapter, false
+	}
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return user, chapter, false
+	}
+	chapter, err = app.Database.Chapters.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return user, chapter, false
+	}
+	return user, chapter, true
+}
+
+func (app *App) ChapterRevisionList(w http.ResponseWriter, r *http.Request) {
+	_, chapter, ok := app.editableChapter(w, r)
+	if !ok {
+		return
+	}
+	limit, offset := paginationParams(r, 50)
+	revisions, err := app.Database.Revisions.List(context.Background(), chapter.ID, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	requestBodies := []RevisionRequestBody{}
+	for _, revision := range revisions {
+		requestBodies = append(requestBodies, newRevisionRequestBody(revision, false))
+	}
+	sendResponse(w, http.StatusOK, requestBodies)
+}
+
+func (app *App) ChapterRevision(w http.ResponseWriter, r *http.Request) {
+	_, chapter, ok := app.editableChapter(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := app.findRevision(w, chapter, chi.URLParam(r, "number"))
+	if !ok {
+		return
+	}
+	sendResponse(w, http.StatusOK, newRevisionRequestBody(revision, true))
+}
+
+// ?from= ve ?to= revizyon numaraları, verilmezse son iki revizyon karşılaştırılır
+func (app *App) ChapterRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	_, chapter, ok := app.editableChapter(w, r)
+	if !ok {
+		return
+	}
+	toParam := r.URL.Query().Get("to")
+	if toParam == "" {
+		latest, err := app.Database.Revisions.List(context.Background(), chapter.ID, 1, 0)
+		if err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+		if len(latest) == 0 {
+			sendResponse(w, http.StatusNotFound, nil)
+			return
+		}
+		toParam = strconv.Itoa(latest[0].Number)
+	}
+	to, ok := app.findRevision(w, chapter, toParam)
+	if !ok {
+		return
+	}
+	fromParam := r.URL.Query().Get("from")
+	if fromParam == "" {
+		fromParam = strconv.Itoa(to.Number - 1)
+	}
+	from, ok := app.findRevision(w, chapter, fromParam)
+	if !ok {
+		return
+	}
+	sendResponse(w, http.StatusOK, RevisionDiffResponse{
+		From:      from.Number,
+		To:        to.Number,
+		TitleFrom: from.Title,
+		TitleTo:   to.Title,
+		Diff: diff.Unified(
+			fmt.Sprintf("%s@%d", chapter.Slug, from.Number),
+			fmt.Sprintf("%s@%d", chapter.Slug, to.Number),
+			from.Content, to.Content, 3),
+	})
+}
+
+// Eski revizyonu geri yükler, geçmiş silinmez, yeni bir revizyon olarak eklenir
+func (app *App) ChapterRevisionRestore(w http.ResponseWriter, r *http.Request) {
+	user, chapter, ok := app.editableChapter(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := app.findRevision(w, chapter, chi.URLParam(r, "number"))
+	if !ok {
+		return
+	}
+	note := fmt.Sprintf("Restored revision %d", revision.Number)
+	if r.ContentLength > 0 {
+		body, err := getRequestBody[RevisionRestoreRequestBody](w, r)
+		if err != nil {
+			var mr *malformedRequest
+			if errors.As(err, &mr) {
+				http.Error(w, mr.msg, mr.status)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			log.Println(err)
+			return
+		}
+		if body.Note != "" {
+			note = body.Note
+		}
+	}
+	chapter.Title = revision.Title
+	chapter.Content = revision.Content
+	chapter, err := app.Database.Chapters.Revise(context.Background(), chapter, &user.ID, note)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, chapter)
+}
+
+func (app *App) findRevision(w http.ResponseWriter, chapter database.Chapter, param string) (database.ChapterRevision, bool) {
+	number, err := strconv.Atoi(param)
+	if err != nil {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "invalid revision number"})
+		return database.ChapterRevision{}, false
+	}
+	revision, err := app.Database.Revisions.FindByNumber(context.Background(), chapter.ID, number)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return revision, false
+	}
+	return revision, true
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+type UserRolesResponse struct {
+	Username    string   `json:"username"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	// Politika gereği staff rolleri iki adımlı doğrulama açılana kadar geçersiz
+	TwoFactorRequired bool `json:"two_factor_required"`
+}
+
+type UserRolesRequestBody struct {
+	Roles []string `json:"roles"`
+}
+
+// Authenticator'dan sonra kullanılır. İzni olmayan kullanıcıya 403 döner.
+func (app *App) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return scopedHandler(func(w http.ResponseWriter, r *http.Request) {
+			user, err := userContextBody(app.Database.Users, r.Context())
+			if err != nil {
+				sendResponse(w, http.StatusUnauthorized, nil)
+				log.Println(err)
+				return
+			}
+			allowed, err := app.Database.Roles.HasPermission(r.Context(), user.ID, permission)
+			if err != nil {
+				sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				log.Println(err)
+				return
+			}
+			if !allowed || !keyAllows(r, permission) {
+				app.sendPermissionDenied(w, r, user, permission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Handler içindeki kontroller için, hata olursa izin yok sayılır
+func (app *App) can(r *http.Request, user database.User, permission string) bool {
+	if !keyAllows(r, permission) {
+		return false
+	}
+	allowed, err := app.Database.Roles.HasPermission(context.Background(), user.ID, permission)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return allowed
+}
+
+// İzin API anahtarının kapsamı ya da iki adımlı doğrulama politikası yüzünden düştüyse kullanıcıya sebebi söylenir
+func (app *App) sendPermissionDenied(w http.ResponseWriter, r *http.Request, user database.User, permission string) {
+	if !keyAllows(r, permission) {
+		sendResponse(w, http.StatusForbidden, map[string]string{"error": "api key is missing scope " + permission})
+		return
+	}
+	pending, err := app.Database.Roles.TwoFactorPending(context.Background(), user.ID)
+	if err != nil {
+		log.Println(err)
+	}
+	if pending {
+		sendResponse(w, http.StatusForbidden, map[string]string{"error": "two-factor authentication is required for staff accounts"})
+		return
+	}
+	sendForbidden(w, permission)
+}
+
+// Giriş yapmamış kullanıcı için false
+func (app *App) optionalCan(r *http.Request, permission string) bool {
+	user, ok := optionalUser(app.Database.Users, r.Context())
+	return ok && app.can(r, user, permission)
+}
+
+func (app *App) RoleList(w http.ResponseWriter, r *http.Request) {
+	roles := make([]RoleResponse, 0, len(database.RolePermissions))
+	for name := range database.RolePermissions {
+		roles = append(roles, RoleResponse{Name: name, Permissions: database.Permissions([]string{name})})
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	sendResponse(w, http.StatusOK, roles)
+}
+
+func (app *App) MyRoles(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	app.sendUserRoles(w, user)
+}
+
+func (app *App) UserRoles(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+	app.sendUserRoles(w, user)
+}
+
+func (app *App) UserRolesUpdate(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+	body, err := getRequestBody[UserRolesRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	roles, err := app.Database.Roles.SetRoles(context.Background(), user.ID, body.Roles)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidRole) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else if errors.Is(err, database.ErrorLastAdmin) {
+			sendResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	app.sendRolesResponse(w, user, roles)
+}
+
+func (app *App) sendUserRoles(w http.ResponseWriter, user database.User) {
+	roles, err := app.Database.Roles.Roles(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	app.sendRolesResponse(w, user, roles)
+}
+
+// İki adımlı doğrulama bekleniyorsa izinler staff rolleri olmadan hesaplanır
+func (app *App) sendRolesResponse(w http.ResponseWriter, user database.User, roles []string) {
+	pending, err := app.Database.Roles.TwoFactorPending(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	granted := roles
+	if pending {
+		granted = []string{}
+		for _, role := range roles {
+			if !database.IsStaffRole(role) {
+				granted = append(granted, role)
+			}
+		}
+	}
+	sendResponse(w, http.StatusOK, UserRolesResponse{
+		Username:          user.Username,
+		Roles:             roles,
+		Permissions:       database.Permissions(granted),
+		TwoFactorRequired: pending,
+	})
+}
+
+func (app *App) userFromURL(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, err := app.Database.Users.FindByUsername(context.Background(), chi.URLParam(r, "username"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return user, false
+	}
+	return user, true
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Planlanmış bölümlerin ne sıklıkla kontrol edileceği
+const schedulerInterval = 30 * time.Second
+
+// Arka planda yayın zamanı gelen bölümleri yayınlar, süresi dolan oturumları, e-posta token'larını, giriş state'lerini, eski giriş sayaçlarını,
+// çöp kutusu ve denetim kayıtlarını, eski canlı bildirim geçmişini siler. Server.Shutdown çağrılınca durur.
+func (app *App) startScheduler() {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.Server.RegisterOnShutdown(cancel)
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		purge := time.NewTicker(sessionPurgeInterval)
+		defer purge.Stop()
+		trash := time.NewTicker(trashPurgeInterval)
+		defer trash.Stop()
+		app.purgeSessions(ctx)
+		app.purgeUserTokens(ctx)
+		app.purgeAuthStates(ctx)
+		app.purgeLoginLocks(ctx)
+		app.purgeTrash(ctx)
+		app.purgeAuditEvents(ctx)
+		for {
+			app.publishDueChapters(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-purge.C:
+				app.purgeSessions(ctx)
+				app.purgeUserTokens(ctx)
+				app.purgeAuthStates(ctx)
+				app.purgeLoginLocks(ctx)
+				app.sweepEvents()
+			case <-trash.C:
+				app.purgeTrash(ctx)
+				app.purgeAuditEvents(ctx)
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (app *App) publishDueChapters(ctx context.Context) {
+	chapters, err := app.Database.Chapters.PublishDue(ctx, time.Now())
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	for _, chapter := range chapters {
+		log.Printf("[scheduler] Published %s (%s)", chapter.Slug, chapter.Project.Slug)
+		app.notifyNewChapter(chapter, chapter.Project, "")
+	}
+}
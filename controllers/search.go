@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func (app *App) Search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "missing search query"})
+		return
+	}
+	limit, offset := paginationParams(r, 20)
+	results, err := app.Database.Search.Search(context.Background(), query, limit, offset)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, results)
+}
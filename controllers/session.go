@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+	// Süresi dolan oturumların ne sıklıkla silineceği
+	sessionPurgeInterval = time.Hour
+)
+
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type RefreshRequestBody struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Yeni bir oturum açıp ilk token çiftini döner
+func (app *App) startSession(r *http.Request, user database.User) (TokenResponse, error) {
+	refreshToken, hash, err := authentication.NewRefreshToken()
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	now := time.Now()
+	session, err := app.Database.Sessions.Create(context.Background(), database.Session{
+		UserID:          user.ID,
+		TokenHash:       hash,
+		AccessJTI:       uuid.New().String(),
+		AccessExpiresAt: now.Add(accessTokenTTL),
+		ExpiresAt:       now.Add(refreshTokenTTL),
+		UserAgent:       truncate(r.UserAgent(), 256),
+		IP:              app.clientIP(r),
+	})
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return app.tokenResponse(user, session, refreshToken)
+}
+
+func (app *App) tokenResponse(user database.User, session database.Session, refreshToken string) (TokenResponse, error) {
+	claims := map[string]interface{}{
+		"authorized": true,
+		"user":       user.Username,
+		"sid":        session.ID,
+		"jti":        session.AccessJTI,
+		"iat":        time.Now().Unix(),
+		"exp":        session.AccessExpiresAt.Unix(),
+	}
+	_, tokenString, err := app.AuthToken.Encode(claims)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+	return TokenResponse{
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		ExpiresAt:    session.AccessExpiresAt,
+	}, nil
+}
+
+// Refresh token her kullanımda değişir, eskisi tekrar gelirse oturum kapatılır
+func (app *App) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := getRequestBody[RefreshRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if body.RefreshToken == "" {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "refresh_token is required"})
+		return
+	}
+	refreshToken, hash, err := authentication.NewRefreshToken()
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
+		log.Println(err)
+		return
+	}
+	now := time.Now()
+	session, err := app.Database.Sessions.Rotate(context.Background(), authentication.HashToken(body.RefreshToken), database.Session{
+		TokenHash:       hash,
+		AccessJTI:       uuid.New().String(),
+		AccessExpiresAt: now.Add(accessTokenTTL),
+		ExpiresAt:       now.Add(refreshTokenTTL),
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidSession) || errors.Is(err, database.ErrorSessionReused) {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	user, err := app.Database.Users.Find(context.Background(), session.UserID)
+	if err != nil {
+		sendResponse(w, http.StatusUnauthorized, map[string]string{"error": database.ErrorInvalidSession.Error()})
+		log.Println(err)
+		return
+	}
+	response, err := app.tokenResponse(user, session, refreshToken)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, response)
+}
+
+// Sadece bu cihazdaki oturumu kapatır
+func (app *App) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusUnauthorized, nil)
+		log.Println(err)
+		return
+	}
+	token, claims, _ := jwtauth.FromContext(r.Context())
+	if sid, ok := claims["sid"].(string); ok {
+		if err := app.Database.Sessions.Revoke(context.Background(), user.ID, sid); err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+	}
+	// Oturum yenilendiyse elindeki token artık oturumun son token'ı olmayabilir
+	if err := app.Database.Sessions.RevokeToken(context.Background(), token.JwtID(), token.Expiration()); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Kullanıcının bütün cihazlardaki oturumlarını kapatır
+func (app *App) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusUnauthorized, nil)
+		log.Println(err)
+		return
+	}
+	token, _, _ := jwtauth.FromContext(r.Context())
+	if err := app.Database.Sessions.RevokeAll(context.Background(), user.ID); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Sessions.RevokeToken(context.Background(), token.JwtID(), token.Expiration()); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Verifier'dan sonra çalışır. jti'si olmayan (eski 30 günlük) ya da iptal edilmiş token'lar reddedilir,
+// token hiç yoksa ya da geçersizse karar Authenticator'a bırakılır.
+func (app *App) rejectRevokedTokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err != nil || token == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// API anahtarı APIKeyVerifier'da kontrol edildi
+		if _, ok := apiKeyFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		jti := token.JwtID()
+		if jti == "" {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": "token has been revoked"})
+			return
+		}
+		revoked, err := app.Database.Sessions.IsRevoked(r.Context(), jti)
+		if err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+		if revoked {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": "token has been revoked"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *App) purgeSessions(ctx context.Context) {
+	purged, err := app.Database.Sessions.Purge(ctx, time.Now())
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("[scheduler] Purged %d expired sessions and revoked tokens", purged)
+	}
+}
+
+// İstek güvenilen bir proxy'den geldiyse X-Forwarded-For sağdan sola okunur, güvenilmeyen ilk adres istemcidir.
+// Proxy tanımlı değilse başlığı istemci kendisi yazabileceği için hiç okunmaz.
+func (app *App) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return truncate(r.RemoteAddr, 64)
+	}
+	if !app.trustedProxy(host) {
+		return host
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		host = addr.Unmap().String()
+		if !app.trustedProxy(host) {
+			break
+		}
+	}
+	return host
+}
+
+func (app *App) trustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range app.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type TeamMemberResponse struct {
+	Username       string    `json:"username"`
+	Name           string    `json:"name"`
+	ProfilePicture string    `json:"profile_picture"`
+	Role           string    `json:"role"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+type InvitationResponse struct {
+	ID        string             `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	Project   ChapterProjectInfo `json:"project"`
+	Username  string             `json:"username"`
+	InvitedBy string             `json:"invited_by"`
+	Role      string             `json:"role"`
+	Status    string             `json:"status"`
+}
+
+type InvitationRequestBody struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type TeamRoleRequestBody struct {
+	Role string `json:"role"`
+}
+
+func newTeamMemberResponse(member database.ProjectMember) TeamMemberResponse {
+	return TeamMemberResponse{
+		Username:       member.User.Username,
+		Name:           member.User.Name,
+		ProfilePicture: member.User.ProfilePicture,
+		Role:           member.Role,
+		JoinedAt:       member.CreatedAt,
+	}
+}
+
+func newInvitationResponse(invitation database.ProjectInvitation) InvitationResponse {
+	return InvitationResponse{
+		ID:        invitation.ID,
+		CreatedAt: invitation.CreatedAt,
+		Project:   projectInfo(invitation.Project),
+		Username:  invitation.User.Username,
+		InvitedBy: invitation.InvitedBy.Username,
+		Role:      invitation.Role,
+		Status:    invitation.Status,
+	}
+}
+
+// İstekteki kaynağın ait olduğu projenin id'si
+type projectResolver func(r *http.Request) (string, error)
+
+// Authenticator'dan sonra kullanılır. Kullanıcının site rolü ya da projedeki ekip rolü izni vermeli.
+func (app *App) RequireProjectPermission(permission string, resolve projectResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return scopedHandler(func(w http.ResponseWriter, r *http.Request) {
+			user, err := userContextBody(app.Database.Users, r.Context())
+			if err != nil {
+				sendResponse(w, http.StatusUnauthorized, nil)
+				log.Println(err)
+				return
+			}
+			project_id, err := resolve(r)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					sendResponse(w, http.StatusNotFound, nil)
+				} else {
+					sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				}
+				log.Println(err)
+				return
+			}
+			allowed, err := app.Database.Teams.HasPermission(r.Context(), project_id, user.ID, permission)
+			if err != nil {
+				sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				log.Println(err)
+				return
+			}
+			if !allowed || !keyAllows(r, permission) {
+				app.sendPermissionDenied(w, r, user, permission)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (app *App) projectBySlug(r *http.Request) (string, error) {
+	project, err := app.Database.Projects.FindBySlug(r.Context(), chi.URLParam(r, "slug"))
+	return project.ID, err
+}
+
+// Göremediği taslak bölüm kullanıcı için yoktur, 403 yerine 404 döner
+func (app *App) projectByChapter(r *http.Request) (string, error) {
+	chapter, err := app.Database.Chapters.FindBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err == nil && !app.canView(r, chapter) {
+		err = gorm.ErrRecordNotFound
+	}
+	return chapter.ProjectID, err
+}
+
+func (app *App) projectByVolume(r *http.Request) (string, error) {
+	volume, err := app.Database.Volumes.Find(r.Context(), chi.URLParam(r, "id"))
+	return volume.ProjectID, err
+}
+
+// Handler içindeki kontroller için, hata olursa izin yok sayılır
+func (app *App) canInProject(r *http.Request, user database.User, project_id string, permission string) bool {
+	if !keyAllows(r, permission) {
+		return false
+	}
+	allowed, err := app.Database.Teams.HasPermission(context.Background(), project_id, user.ID, permission)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return allowed
+}
+
+func (app *App) TeamList(w http.ResponseWriter, r *http.Request) {
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	members, err := app.Database.Teams.Members(context.Background(), project.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	responses := []TeamMemberResponse{}
+	for _, member := range members {
+		responses = append(responses, newTeamMemberResponse(member))
+	}
+	sendResponse(w, http.StatusOK, responses)
+}
+
+func (app *App) TeamMemberUpdate(w http.ResponseWriter, r *http.Request) {
+	project, member, ok := app.teamMember(w, r)
+	if !ok {
+		return
+	}
+	body, err := getRequestBody[TeamRoleRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	member, err = app.Database.Teams.SetRole(context.Background(), project.ID, member.UserID, body.Role)
+	if err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newTeamMemberResponse(member))
+}
+
+// team.manage izni olanlar herkesi, diğer üyeler sadece kendilerini çıkarabilir
+func (app *App) TeamMemberRemove(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	project, member, ok := app.teamMember(w, r)
+	if !ok {
+		return
+	}
+	if member.UserID != user.ID && !app.canInProject(r, user, project.ID, database.PermissionTeamManage) {
+		sendForbidden(w, database.PermissionTeamManage)
+		return
+	}
+	if err := app.Database.Teams.RemoveMember(context.Background(), project.ID, member.UserID); err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) InvitationList(w http.ResponseWriter, r *http.Request) {
+	project_id, err := app.projectBySlug(r)
+	if err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	invitations, err := app.Database.Teams.Invitations(context.Background(), project_id)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendInvitations(w, invitations)
+}
+
+func (app *App) InvitationAdd(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[InvitationRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	invitee, err := app.Database.Users.FindByUsername(context.Background(), body.Username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "user " + body.Username + " not found"})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	invitation, err := app.Database.Teams.Invite(context.Background(), database.ProjectInvitation{
+		ProjectID:   project.ID,
+		UserID:      invitee.ID,
+		InvitedByID: user.ID,
+		Role:        body.Role,
+	})
+	if err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	app.notifyTeamInvite(invitation, project, user)
+	sendResponse(w, http.StatusOK, newInvitationResponse(invitation))
+}
+
+func (app *App) InvitationCancel(w http.ResponseWriter, r *http.Request) {
+	project_id, err := app.projectBySlug(r)
+	if err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	if err := app.Database.Teams.CancelInvitation(context.Background(), project_id, chi.URLParam(r, "id")); err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) MyInvitations(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	invitations, err := app.Database.Teams.UserInvitations(context.Background(), user.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendInvitations(w, invitations)
+}
+
+func (app *App) InvitationAccept(w http.ResponseWriter, r *http.Request) {
+	app.respondInvitation(w, r, true)
+}
+
+func (app *App) InvitationDecline(w http.ResponseWriter, r *http.Request) {
+	app.respondInvitation(w, r, false)
+}
+
+func (app *App) respondInvitation(w http.ResponseWriter, r *http.Request, accept bool) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	invitation, err := app.Database.Teams.Respond(context.Background(), user.ID, chi.URLParam(r, "id"), accept)
+	if err != nil {
+		sendTeamError(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, newInvitationResponse(invitation))
+}
+
+// URL'deki {slug} projesinde {username} üyesini bulur
+func (app *App) teamMember(w http.ResponseWriter, r *http.Request) (database.Project, database.ProjectMember, bool) {
+	var member database.ProjectMember
+	project, err := app.Database.Projects.FindBySlug(context.Background(), chi.URLParam(r, "slug"))
+	if err != nil {
+		sendTeamError(w, err)
+		return project, member, false
+	}
+	user, err := app.Database.Users.FindByUsername(context.Background(), chi.URLParam(r, "username"))
+	if err != nil {
+		sendTeamError(w, err)
+		return project, member, false
+	}
+	member, err = app.Database.Teams.Member(context.Background(), project.ID, user.ID)
+	if err != nil {
+		sendTeamError(w, err)
+		return project, member, false
+	}
+	return project, member, true
+}
+
+func sendInvitations(w http.ResponseWriter, invitations []database.ProjectInvitation) {
+	responses := []InvitationResponse{}
+	for _, invitation := range invitations {
+		responses = append(responses, newInvitationResponse(invitation))
+	}
+	sendResponse(w, http.StatusOK, responses)
+}
+
+func sendTeamError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		sendResponse(w, http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrorInvalidMember), errors.Is(err, database.ErrorInvalidInvitation):
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, database.ErrorAlreadyMember), errors.Is(err, database.ErrorLastOwner):
+		sendResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	log.Println(err)
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// Çöp kutusunun ne sıklıkla boşaltılacağı, süresi TrashRetentionDays ile ayarlanır
+const trashPurgeInterval = 24 * time.Hour
+
+func (app *App) TrashList(w http.ResponseWriter, r *http.Request) {
+	limit, offset := paginationParams(r, 50)
+	items, err := app.Database.Trash.List(context.Background(), chi.URLParam(r, "kind"), limit, offset)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidTrashKind) {
+			sendResponse(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if items == nil {
+		items = []database.TrashItem{}
+	}
+	sendResponse(w, http.StatusOK, items)
+}
+
+// Proje geri yüklenince onunla birlikte silinen bölümler de geri gelir
+func (app *App) TrashRestore(w http.ResponseWriter, r *http.Request) {
+	err := app.Database.Trash.Restore(context.Background(), chi.URLParam(r, "kind"), chi.URLParam(r, "id"))
+	if err != nil {
+		sendTrashError(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Kalıcı olarak siler, geri alınamaz
+func (app *App) TrashPurge(w http.ResponseWriter, r *http.Request) {
+	err := app.Database.Trash.Purge(context.Background(), chi.URLParam(r, "kind"), chi.URLParam(r, "id"))
+	if err != nil {
+		sendTrashError(w, err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Kullanıcıyı çöp kutusuna taşır, oturumları kapatılır
+func (app *App) UserDelete(w http.ResponseWriter, r *http.Request) {
+	admin, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	user, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == admin.ID {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "can not delete yourself"})
+		return
+	}
+	if err := app.Database.Users.Delete(context.Background(), user); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Sessions.RevokeAll(context.Background(), user.ID); err != nil {
+		log.Println(err)
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func sendTrashError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, database.ErrorInvalidTrashKind):
+		sendResponse(w, http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrorParentDeleted):
+		sendResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	log.Println(err)
+}
+
+func (app *App) purgeTrash(ctx context.Context) {
+	if app.TrashRetentionDays <= 0 {
+		return
+	}
+	before := time.Now().AddDate(0, 0, -app.TrashRetentionDays)
+	purged, err := app.Database.Trash.PurgeOlderThan(ctx, before)
+	if err != nil {
+		log.Println("[scheduler]", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("[scheduler] Purged %d items deleted more than %d days ago", purged, app.TrashRetentionDays)
+	}
+}
@@ -0,0 +1,459 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+	"github.com/batt0s/batnovels/database"
+	"gorm.io/gorm"
+)
+
+const (
+	// Şifre doğrulandıktan sonra kodun girilmesi için verilen süre
+	twoFactorChallengeTTL = 5 * time.Minute
+	// Bu kadar yanlış koddan sonra giriş baştan yapılmalı
+	twoFactorMaxAttempts = 5
+	recoveryCodeCount    = 10
+	totpIssuer           = "BatNovels"
+)
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+type TwoFactorLoginRequestBody struct {
+	ChallengeToken string `json:"challenge_token"`
+	// 6 haneli TOTP kodu ya da kurtarma kodu
+	Code string `json:"code"`
+}
+
+type TwoFactorRequestBody struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool  `json:"enabled"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+	// Politika bu hesap için iki adımlı doğrulamayı zorunlu kılıyor
+	Required bool `json:"required"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	// QR kod olarak gösterilecek otpauth:// adresi
+	URI string `json:"uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type SecurityPolicy struct {
+	RequireStaffTwoFactor bool `json:"require_staff_2fa"`
+}
+
+// Şifresi doğru olan kullanıcının iki adımlı doğrulaması açıksa oturum yerine challenge token verilir
+func (app *App) twoFactorChallenge(user database.User) (TwoFactorChallengeResponse, error) {
+	token, id, err := authentication.NewSignedToken([]byte(app.Secret), database.TokenTwoFactor, user.Password)
+	if err != nil {
+		return TwoFactorChallengeResponse{}, err
+	}
+	record, err := app.Database.Tokens.Create(context.Background(), database.UserToken{
+		ID:        id,
+		UserID:    user.ID,
+		Purpose:   database.TokenTwoFactor,
+		ExpiresAt: time.Now().Add(twoFactorChallengeTTL),
+	})
+	if err != nil {
+		return TwoFactorChallengeResponse{}, err
+	}
+	return TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         record.ExpiresAt,
+	}, nil
+}
+
+// Girişin ikinci adımı, kod doğruysa oturum açılır
+func (app *App) TwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	body, err := getRequestBody[TwoFactorLoginRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	record, user, err := app.checkToken(database.TokenTwoFactor, body.ChallengeToken, func(u database.User) string { return u.Password })
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidToken) {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	// Her girişte yeni challenge alınabildiği için yanlış kodlar şifreyle aynı kilide sayılır
+	if app.loginLocked(w, r, user.Username) {
+		return
+	}
+	if err := app.checkSecondFactor(user, body.Code); err != nil {
+		if errors.Is(err, database.ErrorInvalidCode) {
+			attempts, err := app.Database.Tokens.Fail(context.Background(), record)
+			if err != nil {
+				log.Println(err)
+			}
+			if attempts >= twoFactorMaxAttempts {
+				if err := app.Database.Tokens.Use(context.Background(), record); err != nil && !errors.Is(err, database.ErrorInvalidToken) {
+					log.Println(err)
+				}
+			}
+			if until := app.countLoginFailure(r, database.AuditTwoFactorFailed, user.Username, user.ID); !until.IsZero() {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter(until)))
+			}
+		}
+		sendTwoFactorError(w, err, http.StatusUnauthorized)
+		return
+	}
+	if err := app.Database.Tokens.Use(context.Background(), record); err != nil {
+		if errors.Is(err, database.ErrorInvalidToken) {
+			sendResponse(w, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	response, err := app.startSession(r, user)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
+		log.Println(err)
+		return
+	}
+	app.resetLoginLock(user.Username)
+	sendResponse(w, http.StatusOK, response)
+}
+
+func (app *App) TwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	var status TwoFactorStatusResponse
+	status.Enabled, err = app.Database.TwoFactor.IsEnabled(context.Background(), user.ID)
+	if err == nil && status.Enabled {
+		status.RecoveryCodesLeft, err = app.Database.TwoFactor.RecoveryCodesLeft(context.Background(), user.ID)
+	}
+	if err == nil {
+		status.Required, err = app.twoFactorRequired(user)
+	}
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, status)
+}
+
+// Yeni anahtar üretir. İlk kod TwoFactorEnable ile doğrulanana kadar girişte istenmez.
+func (app *App) TwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	user, body, ok := app.twoFactorRequest(w, r)
+	if !ok {
+		return
+	}
+	if err := authentication.CheckPassword(user, body.Password); err != nil {
+		sendResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	secret, err := authentication.NewTOTPSecret()
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.TwoFactor.Setup(context.Background(), user.ID, secret); err != nil {
+		sendTwoFactorError(w, err, http.StatusForbidden)
+		return
+	}
+	sendResponse(w, http.StatusOK, TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    authentication.TOTPURI(totpIssuer, user.Username, secret),
+	})
+}
+
+// Uygulamanın ürettiği ilk kodla kurulumu tamamlar. Kurtarma kodları sadece bu yanıtta görünür.
+func (app *App) TwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	user, body, ok := app.twoFactorRequest(w, r)
+	if !ok {
+		return
+	}
+	two_factor, err := app.Database.TwoFactor.Find(context.Background(), user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = database.ErrorTwoFactorDisabled
+		}
+		sendTwoFactorError(w, err, http.StatusForbidden)
+		return
+	}
+	if two_factor.EnabledAt != nil {
+		sendTwoFactorError(w, database.ErrorTwoFactorEnabled, http.StatusForbidden)
+		return
+	}
+	step, valid := authentication.CheckTOTP(two_factor.Secret, body.Code, time.Now())
+	if !valid {
+		sendTwoFactorError(w, database.ErrorInvalidCode, http.StatusForbidden)
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.TwoFactor.Enable(context.Background(), user.ID, step, hashes); err != nil {
+		sendTwoFactorError(w, err, http.StatusForbidden)
+		return
+	}
+	sendResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Eski kurtarma kodları geçersizleşir, yenileri için şifre ve geçerli bir kod gerekir
+func (app *App) RecoveryCodesRegenerate(w http.ResponseWriter, r *http.Request) {
+	user, body, ok := app.twoFactorRequest(w, r)
+	if !ok || !app.confirmSecondFactor(w, r, user, body) {
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.TwoFactor.ReplaceRecoveryCodes(context.Background(), user.ID, hashes); err != nil {
+		sendTwoFactorError(w, err, http.StatusForbidden)
+		return
+	}
+	sendResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Şifre ve geçerli bir kod ister. Politika açıksa staff yetkileri tekrar açılana kadar kullanılamaz.
+func (app *App) TwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	user, body, ok := app.twoFactorRequest(w, r)
+	if !ok || !app.confirmSecondFactor(w, r, user, body) {
+		return
+	}
+	if err := app.Database.TwoFactor.Disable(context.Background(), user.ID); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Oturumu ele geçiren biri kodu buradan deneyerek bulamasın diye yanlış şifre ve kodlar girişteki kilide sayılır
+func (app *App) confirmSecondFactor(w http.ResponseWriter, r *http.Request, user database.User, body *TwoFactorRequestBody) bool {
+	if app.loginLocked(w, r, user.Username) {
+		return false
+	}
+	if err := authentication.CheckPassword(user, body.Password); err != nil {
+		if until := app.countLoginFailure(r, database.AuditLoginFailed, user.Username, user.ID); !until.IsZero() {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter(until)))
+		}
+		sendResponse(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return false
+	}
+	if err := app.checkSecondFactor(user, body.Code); err != nil {
+		if errors.Is(err, database.ErrorInvalidCode) {
+			if until := app.countLoginFailure(r, database.AuditTwoFactorFailed, user.Username, user.ID); !until.IsZero() {
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter(until)))
+			}
+		}
+		sendTwoFactorError(w, err, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// Telefonunu ve kurtarma kodlarını kaybeden kullanıcı için, oturumları ve API anahtarları da kapatılır
+func (app *App) UserTwoFactorReset(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.userFromURL(w, r)
+	if !ok {
+		return
+	}
+	if err := app.Database.TwoFactor.Disable(context.Background(), user.ID); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Sessions.RevokeAll(context.Background(), user.ID); err != nil {
+		log.Println(err)
+	}
+	if err := app.Database.APIKeys.RevokeAll(context.Background(), user.ID); err != nil {
+		log.Println(err)
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) SecurityPolicyGet(w http.ResponseWriter, r *http.Request) {
+	policy, err := app.securityPolicy()
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, policy)
+}
+
+// Politikayı açan admin kendi yetkisini kaybetmesin diye önce kendi hesabında açmış olmalı
+func (app *App) SecurityPolicyUpdate(w http.ResponseWriter, r *http.Request) {
+	admin, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[SecurityPolicy](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if body.RequireStaffTwoFactor {
+		enabled, err := app.Database.TwoFactor.IsEnabled(context.Background(), admin.ID)
+		if err != nil {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			log.Println(err)
+			return
+		}
+		if !enabled {
+			sendResponse(w, http.StatusConflict, map[string]string{"error": "enable two-factor authentication on your own account first"})
+			return
+		}
+	}
+	value := "false"
+	if body.RequireStaffTwoFactor {
+		value = "true"
+	}
+	if err := app.Database.Settings.Set(context.Background(), database.SettingRequireStaffTwoFactor, value); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, body)
+}
+
+func (app *App) securityPolicy() (SecurityPolicy, error) {
+	value, err := app.Database.Settings.Get(context.Background(), database.SettingRequireStaffTwoFactor)
+	return SecurityPolicy{RequireStaffTwoFactor: value == "true"}, err
+}
+
+// Kullanıcının staff rolü varsa ve politika açıksa true
+func (app *App) twoFactorRequired(user database.User) (bool, error) {
+	policy, err := app.securityPolicy()
+	if err != nil || !policy.RequireStaffTwoFactor {
+		return false, err
+	}
+	roles, err := app.Database.Roles.Roles(context.Background(), user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if database.IsStaffRole(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 6 haneli kod TOTP, diğerleri kurtarma kodu olarak denenir. İkisi de bir kez kullanılabilir.
+func (app *App) checkSecondFactor(user database.User, code string) error {
+	two_factor, err := app.Database.TwoFactor.Find(context.Background(), user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return database.ErrorTwoFactorDisabled
+		}
+		return err
+	}
+	if two_factor.EnabledAt == nil {
+		return database.ErrorTwoFactorDisabled
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if code == "" {
+		return database.ErrorInvalidCode
+	}
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		step, valid := authentication.CheckTOTP(two_factor.Secret, code, time.Now())
+		if !valid {
+			return database.ErrorInvalidCode
+		}
+		return app.Database.TwoFactor.UseStep(context.Background(), user.ID, step)
+	}
+	hash := authentication.HashToken(authentication.NormalizeRecoveryCode(code))
+	return app.Database.TwoFactor.UseRecoveryCode(context.Background(), user.ID, hash)
+}
+
+func (app *App) twoFactorRequest(w http.ResponseWriter, r *http.Request) (database.User, *TwoFactorRequestBody, bool) {
+	user, err := userContextBody(app.Database.Users, r.Context())
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, nil)
+		log.Println(err)
+		return user, nil, false
+	}
+	body, err := getRequestBody[TwoFactorRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return user, body, false
+	}
+	return user, body, true
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := authentication.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = authentication.HashToken(authentication.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// Yanlış kod, girişte 401, giriş yapmış kullanıcının işlemlerinde 403 döner
+func sendTwoFactorError(w http.ResponseWriter, err error, invalid_status int) {
+	switch {
+	case errors.Is(err, database.ErrorInvalidCode):
+		sendResponse(w, invalid_status, map[string]string{"error": err.Error()})
+	case errors.Is(err, database.ErrorTwoFactorEnabled), errors.Is(err, database.ErrorTwoFactorDisabled):
+		sendResponse(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	log.Println(err)
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"log"
 	"net/http"
-	"time"
 
 	"github.com/batt0s/batnovels/authentication"
 	"github.com/batt0s/batnovels/database"
@@ -45,6 +44,22 @@ func userContextBody(users database.UserRepo, ctx context.Context) (database.Use
 	return user, nil
 }
 
+// Giriş yapmamış kullanıcılar için de çalışan route'larda token varsa kullanıcıyı döner
+func optionalUser(users database.UserRepo, ctx context.Context) (database.User, bool) {
+	token, claims, err := jwtauth.FromContext(ctx)
+	if err != nil || token == nil {
+		return database.User{}, false
+	}
+	if _, ok := claims["user"].(string); !ok {
+		return database.User{}, false
+	}
+	user, err := userContextBody(users, ctx)
+	if err != nil {
+		return user, false
+	}
+	return user, true
+}
+
 func (app *App) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	body, err := getRequestBody[RegisterRequestBody](w, r)
 	if err != nil {
@@ -57,6 +72,10 @@ func (app *App) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
+	if !validPassword(body.Password) {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": "password must be between 6 and 72 characters"})
+		return
+	}
 	user := database.User{
 		Username: body.Username,
 		Email:    body.Email,
@@ -69,6 +88,13 @@ func (app *App) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
+	// Kayıt tamamlandı, e-posta gönderilemezse kullanıcı tekrar isteyebilir
+	if user, err = app.Database.Users.FindByUsername(context.Background(), user.Username); err == nil {
+		err = app.sendVerification(user)
+	}
+	if err != nil {
+		log.Println(err)
+	}
 	sendResponse(w, http.StatusOK, nil)
 }
 
@@ -84,22 +110,18 @@ func (app *App) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 		return
 	}
-	user, err := authentication.Authenticate(body.Username, body.Password, app.Database.Users)
-	if err != nil {
-		log.Println(err)
-		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if app.loginLocked(w, r, body.Username) {
 		return
 	}
-	claims := map[string]interface{}{
-		"authorized": true,
-		"user":       user.Username,
-		"exp":        time.Now().Add(30 * 24 * time.Hour).Unix(),
+	user, err := authentication.Authenticate(body.Username, body.Password, app.Database.Users)
+	if errors.Is(err, authentication.ErrorInvalidCredentials) {
+		app.loginFailed(w, r, body.Username, user.ID)
+		return
 	}
-	_, tokenString, err := app.AuthToken.Encode(claims)
 	if err != nil {
-		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": "token generation error"})
 		log.Println(err)
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	sendResponse(w, http.StatusOK, map[string]string{"token": tokenString})
+	app.finishLogin(w, r, user)
 }
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+type VolumeRequestBody struct {
+	Number float64 `json:"number"`
+	Title  string  `json:"title"`
+}
+
+type ChapterReorderRequestBody struct {
+	Chapters []string `json:"chapters"` // bölüm slug'ları, yeni sırasıyla
+}
+
+type ChapterMoveRequestBody struct {
+	After    *string  `json:"after"` // arkasına taşınacak bölümün slug'ı, "" ise en başa
+	VolumeID *string  `json:"volume_id"`
+	Number   *float64 `json:"number"`
+}
+
+func (app *App) VolumeList(w http.ResponseWriter, r *http.Request) {
+	project_slug := chi.URLParam(r, "slug")
+	if project_slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), project_slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	volumes, err := app.Database.Volumes.List(context.Background(), project.ID)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, volumes)
+}
+
+func (app *App) VolumeAdd(w http.ResponseWriter, r *http.Request) {
+	project_slug := chi.URLParam(r, "slug")
+	if project_slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	body, err := getRequestBody[VolumeRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), project_slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	volume, err := app.Database.Volumes.Add(context.Background(), database.Volume{
+		ProjectID: project.ID,
+		Number:    body.Number,
+		Title:     body.Title,
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidVolume) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, volume)
+}
+
+func (app *App) VolumeUpdate(w http.ResponseWriter, r *http.Request) {
+	volume, err := app.Database.Volumes.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	body, err := getRequestBody[VolumeRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	volume.Number = body.Number
+	volume.Title = body.Title
+	volume, err = app.Database.Volumes.Update(context.Background(), volume)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidVolume) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, volume)
+}
+
+func (app *App) VolumeDelete(w http.ResponseWriter, r *http.Request) {
+	volume, err := app.Database.Volumes.Find(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	if err := app.Database.Volumes.Delete(context.Background(), volume); err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+// Bütün bölümleri verilen sıraya dizer, liste projedeki her bölümü tam bir kez içermeli
+func (app *App) ChapterReorder(w http.ResponseWriter, r *http.Request) {
+	project_slug := chi.URLParam(r, "slug")
+	if project_slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	body, err := getRequestBody[ChapterReorderRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	project, err := app.Database.Projects.FindBySlug(context.Background(), project_slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	chapters, err := app.Database.Chapters.ListBySlug(context.Background(), project.Slug, -1, 0, database.ChapterReadingOrder)
+	if err != nil {
+		sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		log.Println(err)
+		return
+	}
+	ids := make(map[string]string, len(chapters))
+	for _, chapter := range chapters {
+		ids[chapter.Slug] = chapter.ID
+	}
+	order := make([]string, 0, len(body.Chapters))
+	for _, slug := range body.Chapters {
+		id, ok := ids[slug]
+		if !ok {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "chapter " + slug + " not found in project"})
+			return
+		}
+		order = append(order, id)
+	}
+	err = app.Database.Chapters.Reorder(context.Background(), project.ID, order)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidOrder) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, nil)
+}
+
+func (app *App) ChapterMove(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if slug == "" {
+		sendResponse(w, http.StatusBadRequest, nil)
+		return
+	}
+	body, err := getRequestBody[ChapterMoveRequestBody](w, r)
+	if err != nil {
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			http.Error(w, mr.msg, mr.status)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		log.Println(err)
+		return
+	}
+	if body.After == nil {
+		sendResponse(w, http.StatusBadRequest, map[string]string{"error": `"after" is required, use "" to move to the beginning`})
+		return
+	}
+	chapter, err := app.Database.Chapters.FindBySlug(context.Background(), slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			sendResponse(w, http.StatusNotFound, nil)
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	after_id := ""
+	if *body.After != "" {
+		after, err := app.Database.Chapters.FindBySlug(context.Background(), *body.After)
+		if err != nil || after.ProjectID != chapter.ProjectID {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": "chapter " + *body.After + " not found in project"})
+			return
+		}
+		after_id = after.ID
+	}
+	if body.VolumeID != nil {
+		if *body.VolumeID == "" {
+			chapter.VolumeID = nil
+		} else {
+			volume, err := app.Database.Volumes.Find(context.Background(), *body.VolumeID)
+			if err != nil || volume.ProjectID != chapter.ProjectID {
+				sendResponse(w, http.StatusBadRequest, map[string]string{"error": "volume not found in project"})
+				return
+			}
+			chapter.VolumeID = &volume.ID
+		}
+	}
+	if body.Number != nil {
+		chapter.Number = *body.Number
+	}
+	chapter, err = app.Database.Chapters.Move(context.Background(), chapter, after_id)
+	if err != nil {
+		if errors.Is(err, database.ErrorInvalidOrder) {
+			sendResponse(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			sendResponse(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		log.Println(err)
+		return
+	}
+	sendResponse(w, http.StatusOK, chapter)
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// API anahtarlarına verilebilecek izinler. Kullanıcı ve ekip yönetimi sadece oturumla yapılabilir.
+var APIKeyScopes = []string{
+	PermissionProjectCreate, PermissionProjectEdit,
+	PermissionChapterCreate, PermissionChapterEdit, PermissionChapterPublish, PermissionChapterDelete,
+	PermissionCommentModerate, PermissionReviewModerate,
+}
+
+// Script ve botlar için kişisel anahtar. Anahtarın kendisi değil sadece özeti tutulur,
+// kullanıcı hangisi olduğunu Prefix'ten tanır. İzinler hem kullanıcının rollerinden hem Scopes'tan geçmelidir.
+type APIKey struct {
+	ID         string     `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     string     `gorm:"not null;index;" json:"-"`
+	Name       string     `gorm:"not null;size:64;" json:"name"`
+	Prefix     string     `gorm:"not null;size:16;" json:"prefix"`
+	KeyHash    string     `gorm:"not null;size:64;uniqueIndex;" json:"-"`
+	Scopes     string     `gorm:"not null;size:512;" json:"-"` // virgülle ayrılmış izinler
+	ExpiresAt  *time.Time `gorm:"index;" json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+type APIKeyRepo interface {
+	Create(ctx context.Context, key APIKey) (APIKey, error)
+	List(ctx context.Context, user_id string) ([]APIKey, error)
+	FindByHash(ctx context.Context, key_hash string) (APIKey, error)
+	Touch(ctx context.Context, key APIKey, now time.Time) error
+	Revoke(ctx context.Context, user_id string, id string) error
+	RevokeAll(ctx context.Context, user_id string) error
+}
+
+type SqlAPIKeyRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlAPIKeyRepo(db *gorm.DB) *SqlAPIKeyRepo {
+	return &SqlAPIKeyRepo{
+		db: db,
+	}
+}
+
+func (repo SqlAPIKeyRepo) Create(ctx context.Context, key APIKey) (APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return key, ErrorOperationCanceled
+	default:
+		if !key.IsValid() {
+			return key, ErrorInvalidAPIKey
+		}
+		key.ID = uuid.New().String()
+		result := repo.db.Create(&key)
+		return key, result.Error
+	}
+}
+
+// İptal edilmemiş anahtarlar, süresi dolanlar da listelenir
+func (repo SqlAPIKeyRepo) List(ctx context.Context, user_id string) ([]APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return []APIKey{}, ErrorOperationCanceled
+	default:
+		var keys []APIKey
+		result := repo.db.Where("user_id = ? AND revoked_at IS NULL", user_id).Order("created_at desc").Find(&keys)
+		return keys, result.Error
+	}
+}
+
+// İptal edilmiş ya da süresi dolmuş anahtarlar için gorm.ErrRecordNotFound döner
+func (repo SqlAPIKeyRepo) FindByHash(ctx context.Context, key_hash string) (APIKey, error) {
+	select {
+	case <-ctx.Done():
+		return APIKey{}, ErrorOperationCanceled
+	default:
+		var key APIKey
+		result := repo.db.Where("key_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", key_hash, time.Now()).
+			First(&key)
+		return key, result.Error
+	}
+}
+
+// Her istekte yazmamak için son kullanım en fazla dakikada bir güncellenir
+func (repo SqlAPIKeyRepo) Touch(ctx context.Context, key APIKey, now time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < time.Minute {
+			return nil
+		}
+		result := repo.db.Model(&APIKey{}).Where("id = ?", key.ID).Update("last_used_at", now)
+		return result.Error
+	}
+}
+
+func (repo SqlAPIKeyRepo) Revoke(ctx context.Context, user_id string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&APIKey{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user_id).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+}
+
+// Şifre ya da iki adımlı doğrulama sıfırlanınca, hesap ele geçirildiyse açılan anahtarlar da kapanır
+func (repo SqlAPIKeyRepo) RevokeAll(ctx context.Context, user_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", user_id).
+			Update("revoked_at", time.Now())
+		return result.Error
+	}
+}
+
+func (k APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+func (k APIKey) HasScope(permission string) bool {
+	for _, scope := range k.ScopeList() {
+		if scope == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (k APIKey) IsValid() bool {
+	if k.UserID == "" || k.KeyHash == "" || k.Prefix == "" {
+		return false
+	}
+	if name := strings.TrimSpace(k.Name); name == "" || len(k.Name) > 64 {
+		return false
+	}
+	for _, scope := range k.ScopeList() {
+		if !IsAPIKeyScope(scope) {
+			return false
+		}
+	}
+	return k.ExpiresAt == nil || k.ExpiresAt.After(time.Now())
+}
+
+func IsAPIKeyScope(scope string) bool {
+	for _, allowed := range APIKeyScopes {
+		if scope == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditLoginFailed     = "login.failed"
+	AuditTwoFactorFailed = "login.2fa_failed"
+	AuditLoginBlocked    = "login.blocked"
+	AuditLoginLocked     = "login.locked"
+	AuditLoginUnlock     = "login.unlock"
+)
+
+// Güvenlikle ilgili olayların kaydı. Kullanıcı silinse de kayıtlar kalır.
+// UserID olayın ilgili olduğu hesap, ActorID işlemi yapan admin; giriş denemelerinde boştur.
+type AuditEvent struct {
+	ID        string    `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time `gorm:"index;" json:"created_at"`
+	Action    string    `gorm:"not null;size:32;index;" json:"action"`
+	UserID    string    `gorm:"size:64;index;" json:"user_id,omitempty"`
+	ActorID   string    `gorm:"size:64;" json:"actor_id,omitempty"`
+	// Denenen kullanıcı adı ya da kilit anahtarı
+	Subject   string `gorm:"size:160;index;" json:"subject"`
+	IP        string `gorm:"size:64;index;" json:"ip"`
+	UserAgent string `gorm:"size:256;" json:"user_agent"`
+	Detail    string `gorm:"size:256;" json:"detail,omitempty"`
+}
+
+// Boş alanlar filtrelenmez. UserID ve Subjects birlikte verilirse herhangi birine uyan olaylar döner,
+// böylece var olmayan kullanıcı adıyla yapılan denemeler de bulunur.
+type AuditFilter struct {
+	Action   string
+	UserID   string
+	Subjects []string
+	IP       string
+}
+
+type AuditRepo interface {
+	Add(ctx context.Context, event AuditEvent) error
+	List(ctx context.Context, filter AuditFilter, limit int, offset int) ([]AuditEvent, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SqlAuditRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlAuditRepo(db *gorm.DB) *SqlAuditRepo {
+	return &SqlAuditRepo{
+		db: db,
+	}
+}
+
+func (repo SqlAuditRepo) Add(ctx context.Context, event AuditEvent) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		if event.Action == "" {
+			return ErrorOperationFailed
+		}
+		event.ID = uuid.NewString()
+		return repo.db.Create(&event).Error
+	}
+}
+
+// En yeni olay ilk sırada
+func (repo SqlAuditRepo) List(ctx context.Context, filter AuditFilter, limit int, offset int) ([]AuditEvent, error) {
+	select {
+	case <-ctx.Done():
+		return []AuditEvent{}, ErrorOperationCanceled
+	default:
+		query := repo.db.Model(&AuditEvent{})
+		if filter.Action != "" {
+			query = query.Where("action = ?", filter.Action)
+		}
+		if filter.UserID != "" && len(filter.Subjects) > 0 {
+			query = query.Where("(user_id = ? OR subject IN ?)", filter.UserID, filter.Subjects)
+		} else if filter.UserID != "" {
+			query = query.Where("user_id = ?", filter.UserID)
+		} else if len(filter.Subjects) > 0 {
+			query = query.Where("subject IN ?", filter.Subjects)
+		}
+		if filter.IP != "" {
+			query = query.Where("ip = ?", filter.IP)
+		}
+		var events []AuditEvent
+		result := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&events)
+		return events, result.Error
+	}
+}
+
+func (repo SqlAuditRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		result := repo.db.Where("created_at < ?", before).Delete(&AuditEvent{})
+		return result.RowsAffected, result.Error
+	}
+}
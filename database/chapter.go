@@ -2,10 +2,19 @@ package database
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ChapterDraft     = "draft"
+	ChapterScheduled = "scheduled" // PublishAt gelince scheduler yayınlar
+	ChapterPublished = "published"
+	ChapterUnlisted  = "unlisted" // linki olan okuyabilir ama listelerde çıkmaz
 )
 
 type Chapter struct {
@@ -16,18 +25,49 @@ type Chapter struct {
 	Title     string         `gorm:"not null;size:128;" json:"title"`
 	Content   string         `gorm:"type:text;" json:"content"`
 	Slug      string         `gorm:"not null;unique;size:128;;" json:"slug"`
+	Status    string         `gorm:"not null;size:16;default:published;index;" json:"status"`
+	PublishAt *time.Time     `gorm:"index" json:"publish_at"`
+	VolumeID  *string        `gorm:"index" json:"volume_id"`
+	Number    float64        `json:"number"` // 12.5 gibi yan hikayeler için ondalıklı
+	SortKey   float64        `gorm:"index" json:"sort_key"`
 	ProjectID string         `json:"project_id"`
 	Project   Project        `gorm:"foreignKey:ProjectID"`
 }
 
+// Bütün bölüm listeleri bu sırayı kullanır
+const ChapterReadingOrder = "sort_key asc"
+
+// İki sort_key arası bundan küçülürse projenin bölümleri yeniden numaralanır
+const minSortKeyGap = 1e-6
+
 type ChapterRepo interface {
 	Find(ctx context.Context, id string) (Chapter, error)
 	FindBySlug(ctx context.Context, slug string) (Chapter, error)
+	FindByOldSlug(ctx context.Context, slug string) (Chapter, error)
 	Add(ctx context.Context, chapter Chapter) (Chapter, error)
 	Update(ctx context.Context, chapter Chapter) (Chapter, error)
+	Revise(ctx context.Context, chapter Chapter, editor_id *string, note string) (Chapter, error)
 	Delete(ctx context.Context, chapter Chapter) error
 	List(ctx context.Context, project_id string, limit, offset int, orderby string) ([]Chapter, error)
 	ListBySlug(ctx context.Context, project_slug string, limit, offset int, orderby string) ([]Chapter, error)
+	ListPublishedBySlug(ctx context.Context, project_slug string, limit, offset int, orderby string) ([]Chapter, error)
+	ListLatestPublished(ctx context.Context, limit, offset int) ([]Chapter, error)
+	PublishDue(ctx context.Context, now time.Time) ([]Chapter, error)
+	Move(ctx context.Context, chapter Chapter, after_id string) (Chapter, error)
+	Reorder(ctx context.Context, project_id string, chapter_ids []string) error
+	Navigation(ctx context.Context, chapter Chapter) (ChapterNavigation, error)
+}
+
+type ChapterLink struct {
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+type ChapterNavigation struct {
+	Position int          `json:"position"`
+	Total    int          `json:"total"`
+	Previous *ChapterLink `json:"previous"`
+	Next     *ChapterLink `json:"next"`
 }
 
 type SqlChapterRepo struct {
@@ -62,28 +102,99 @@ func (repo SqlChapterRepo) FindBySlug(ctx context.Context, slug string) (Chapter
 	}
 }
 
+// Başlığı değişmiş bölümü eski slug'ından bulur
+func (repo SqlChapterRepo) FindByOldSlug(ctx context.Context, slug string) (Chapter, error) {
+	select {
+	case <-ctx.Done():
+		return Chapter{}, ErrorOperationCanceled
+	default:
+		var chapter Chapter
+		result := repo.db.Preload("Project").
+			Joins("JOIN slug_redirects ON slug_redirects.target_id = chapters.id AND slug_redirects.kind = ?", "chapters").
+			First(&chapter, "slug_redirects.slug = ?", slug)
+		return chapter, result.Error
+	}
+}
+
 func (repo SqlChapterRepo) Add(ctx context.Context, chapter Chapter) (Chapter, error) {
 	select {
 	case <-ctx.Done():
 		return chapter, ErrorOperationCanceled
 	default:
+		if chapter.Status == "" {
+			chapter.Status = ChapterPublished
+		}
+		if chapter.Status == ChapterPublished && chapter.PublishAt == nil {
+			now := time.Now()
+			chapter.PublishAt = &now
+		}
 		if !chapter.IsValid() {
-			return chapter, ErrorInvalidProject
+			return chapter, ErrorInvalidChapter
+		}
+		if chapter.SortKey == 0 {
+			if err := repo.place(&chapter); err != nil {
+				return chapter, err
+			}
 		}
 		chapter.ID = uuid.New().String()
-		chapter.Slug = Slugify(chapter.Title)
-		result := repo.db.Create(&chapter)
-		return chapter, result.Error
+		err := withUniqueSlug(repo.db, func(tx *gorm.DB) error {
+			slug, err := uniqueSlug(tx, "chapters", chapter.Title, "")
+			if err != nil {
+				return err
+			}
+			chapter.Slug = slug
+			return tx.Create(&chapter).Error
+		})
+		return chapter, err
 	}
 }
 
+// Düzenleyen ve not olmadan Revise ile aynıdır, içerik revizyonsuz değişmez
 func (repo SqlChapterRepo) Update(ctx context.Context, chapter Chapter) (Chapter, error) {
+	return repo.Revise(ctx, chapter, nil, "")
+}
+
+// Bölümü kaydeder, başlık ya da içerik değiştiyse yeni bir revizyon da ekler.
+// Revizyonu olmayan eski bölümler için önce mevcut hali ilk revizyon olarak saklanır.
+// Başlık değiştiyse slug yeniden üretilir, eski slug yönlendirme olarak kalır.
+func (repo SqlChapterRepo) Revise(ctx context.Context, chapter Chapter, editor_id *string, note string) (Chapter, error) {
 	select {
 	case <-ctx.Done():
 		return chapter, ErrorOperationCanceled
 	default:
-		result := repo.db.Save(&chapter)
-		return chapter, result.Error
+		if !chapter.IsValid() {
+			return chapter, ErrorInvalidChapter
+		}
+		err := withUniqueSlug(repo.db, func(tx *gorm.DB) error {
+			var current Chapter
+			if err := tx.First(&current, "id = ?", chapter.ID).Error; err != nil {
+				return err
+			}
+			slug, err := renameSlug(tx, "chapters", chapter.ID, current.Slug, current.Title, chapter.Title)
+			if err != nil {
+				return err
+			}
+			chapter.Slug = slug
+			if err := tx.Omit(clause.Associations).Save(&chapter).Error; err != nil {
+				return err
+			}
+			if current.Title == chapter.Title && current.Content == chapter.Content {
+				return nil
+			}
+			revisions := SqlChapterRevisionRepo{db: tx}
+			var count int64
+			if err := tx.Model(&ChapterRevision{}).Where("chapter_id = ?", chapter.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				if _, err := revisions.Record(ctx, current, nil, "Initial version"); err != nil {
+					return err
+				}
+			}
+			_, err = revisions.Record(ctx, chapter, editor_id, note)
+			return err
+		})
+		return chapter, err
 	}
 }
 
@@ -124,6 +235,309 @@ func (repo SqlChapterRepo) ListBySlug(ctx context.Context, project_slug string,
 	}
 }
 
+// Okuyucuya açık listelerde sadece yayınlanmış ve yayın zamanı gelmiş bölümler gösterilir.
+// publish_at ileride olan bir bölüm de henüz yayınlanmamış sayılır, eski bölümlerde publish_at boştur.
+// Ham sorgularda chapters tablosu için kullanılır, parametreleri ChapterPublished ve şu anki zaman.
+const releasedChapter = "(chapters.status = ? AND (chapters.publish_at IS NULL OR chapters.publish_at <= ?))"
+
+func releasedChapters(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(releasedChapter, ChapterPublished, now)
+	}
+}
+
+// Sadece yayınlanmış bölümler, okuyucuya açık listelerde kullanılır
+func (repo SqlChapterRepo) ListPublishedBySlug(ctx context.Context, project_slug string, limit, offset int, orderby string) ([]Chapter, error) {
+	select {
+	case <-ctx.Done():
+		return []Chapter{}, ErrorOperationCanceled
+	default:
+		var chapters []Chapter
+		result := repo.db.Joins("JOIN projects ON projects.id = chapters.project_id").
+			Where("projects.slug = ?", project_slug).
+			Scopes(releasedChapters(time.Now())).
+			Limit(limit).
+			Offset(offset).
+			Order("chapters." + orderby).
+			Find(&chapters)
+		return chapters, result.Error
+	}
+}
+
+// Bütün projelerden en son yayınlanan bölümler, projeleriyle birlikte
+func (repo SqlChapterRepo) ListLatestPublished(ctx context.Context, limit, offset int) ([]Chapter, error) {
+	select {
+	case <-ctx.Done():
+		return []Chapter{}, ErrorOperationCanceled
+	default:
+		var chapters []Chapter
+		result := repo.db.Preload("Project").
+			Joins("JOIN projects ON projects.id = chapters.project_id AND projects.deleted_at IS NULL").
+			Scopes(releasedChapters(time.Now())).
+			Limit(limit).
+			Offset(offset).
+			Order("COALESCE(chapters.publish_at, chapters.created_at) desc").
+			Find(&chapters)
+		return chapters, result.Error
+	}
+}
+
+// Yayın zamanı gelmiş planlanmış bölümleri yayınlar ve yayınlananları döner
+func (repo SqlChapterRepo) PublishDue(ctx context.Context, now time.Time) ([]Chapter, error) {
+	select {
+	case <-ctx.Done():
+		return []Chapter{}, ErrorOperationCanceled
+	default:
+		var chapters []Chapter
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Preload("Project").
+				Where("status = ? AND publish_at <= ?", ChapterScheduled, now).
+				Find(&chapters)
+			if result.Error != nil || len(chapters) == 0 {
+				return result.Error
+			}
+			ids := make([]string, len(chapters))
+			for i := range chapters {
+				ids[i] = chapters[i].ID
+				chapters[i].Status = ChapterPublished
+			}
+			return tx.Model(&Chapter{}).
+				Where("id IN ? AND status = ?", ids, ChapterScheduled).
+				Update("status", ChapterPublished).Error
+		})
+		return chapters, err
+	}
+}
+
+// Numarası verilmemiş bölüm sona eklenir ve sıradaki numarayı alır.
+// Numarası verilmişse (12.5 gibi) numarası kendinden küçük olan son bölümün arkasına yerleşir.
+func (repo SqlChapterRepo) place(chapter *Chapter) error {
+	var last struct {
+		SortKey float64
+		Number  float64
+	}
+	result := repo.db.Model(&Chapter{}).
+		Select("COALESCE(MAX(sort_key), 0) AS sort_key, COALESCE(MAX(number), 0) AS number").
+		Where("project_id = ?", chapter.ProjectID).
+		Scan(&last)
+	if result.Error != nil {
+		return result.Error
+	}
+	if chapter.Number == 0 {
+		chapter.Number = math.Floor(last.Number) + 1
+		chapter.SortKey = last.SortKey + 1
+		return nil
+	}
+	var previous Chapter
+	result = repo.db.Where("project_id = ? AND number <= ?", chapter.ProjectID, chapter.Number).
+		Order("sort_key desc").
+		Limit(1).
+		Find(&previous)
+	if result.Error != nil {
+		return result.Error
+	}
+	key, err := repo.sortKeyAfter(chapter.ProjectID, previous.ID, "")
+	if err != nil {
+		return err
+	}
+	chapter.SortKey = key
+	return nil
+}
+
+// after_id'den hemen sonra gelecek bir sort_key hesaplar, after_id boşsa en başa koyar.
+// Anahtar her zaman iki komşunun tam arasındadır, en baştaki için alt sınır 0'dır.
+// Araya sığmıyorsa proje yeniden numaralanıp tekrar denenir.
+func (repo SqlChapterRepo) sortKeyAfter(project_id string, after_id string, exclude_id string) (float64, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		var lower float64
+		if after_id != "" {
+			var after Chapter
+			if err := repo.db.First(&after, "id = ? AND project_id = ?", after_id, project_id).Error; err != nil {
+				return 0, err
+			}
+			lower = after.SortKey
+		}
+		var next Chapter
+		query := repo.db.Where("project_id = ? AND id <> ?", project_id, exclude_id)
+		if after_id != "" {
+			query = query.Where("sort_key > ?", lower)
+		}
+		result := query.Order("sort_key asc").Limit(1).Find(&next)
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		switch {
+		case next.ID == "":
+			return lower + 1, nil
+		case next.SortKey-lower >= minSortKeyGap:
+			return (lower + next.SortKey) / 2, nil
+		}
+		if err := repo.renumber(project_id); err != nil {
+			return 0, err
+		}
+	}
+	return 0, ErrorOperationFailed
+}
+
+// sort_key'leri mevcut sırayı bozmadan 1, 2, 3 ... yapar
+func (repo SqlChapterRepo) renumber(project_id string) error {
+	var ids []string
+	result := repo.db.Model(&Chapter{}).
+		Where("project_id = ?", project_id).
+		Order("sort_key asc, created_at asc").
+		Pluck("id", &ids)
+	if result.Error != nil {
+		return result.Error
+	}
+	for i, id := range ids {
+		if err := repo.db.Model(&Chapter{}).Where("id = ?", id).Update("sort_key", i+1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bölümü after_id'nin arkasına taşır (boşsa en başa), cilt ve numarasını da aynı transaction'da günceller
+func (repo SqlChapterRepo) Move(ctx context.Context, chapter Chapter, after_id string) (Chapter, error) {
+	select {
+	case <-ctx.Done():
+		return chapter, ErrorOperationCanceled
+	default:
+		if after_id == chapter.ID {
+			return chapter, ErrorInvalidOrder
+		}
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			key, err := SqlChapterRepo{db: tx}.sortKeyAfter(chapter.ProjectID, after_id, chapter.ID)
+			if err != nil {
+				return err
+			}
+			chapter.SortKey = key
+			return tx.Model(&chapter).Updates(map[string]interface{}{
+				"sort_key":  chapter.SortKey,
+				"volume_id": chapter.VolumeID,
+				"number":    chapter.Number,
+			}).Error
+		})
+		return chapter, err
+	}
+}
+
+// Projenin bütün bölümlerini verilen sıraya dizer. Liste eksik ya da fazlaysa hiçbir şey değişmez.
+func (repo SqlChapterRepo) Reorder(ctx context.Context, project_id string, chapter_ids []string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			var existing []string
+			if err := tx.Model(&Chapter{}).Where("project_id = ?", project_id).Pluck("id", &existing).Error; err != nil {
+				return err
+			}
+			if len(existing) != len(chapter_ids) {
+				return ErrorInvalidOrder
+			}
+			known := make(map[string]bool, len(existing))
+			for _, id := range existing {
+				known[id] = true
+			}
+			for i, id := range chapter_ids {
+				if !known[id] {
+					return ErrorInvalidOrder
+				}
+				delete(known, id)
+				if err := tx.Model(&Chapter{}).Where("id = ?", id).Update("sort_key", i+1).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// Okuma sırasında bölümün önceki/sonraki komşularını ve sırasını tek sorguda bulur.
+// Sadece yayınlanmış bölümler sayılır, bölümün kendisi unlisted ya da taslak olsa bile sıraya dahil edilir.
+func (repo SqlChapterRepo) Navigation(ctx context.Context, chapter Chapter) (ChapterNavigation, error) {
+	select {
+	case <-ctx.Done():
+		return ChapterNavigation{}, ErrorOperationCanceled
+	default:
+		var row struct {
+			Position  int
+			Total     int
+			PrevSlug  *string
+			PrevTitle *string
+			NextSlug  *string
+			NextTitle *string
+		}
+		result := repo.db.Raw(`SELECT position, total, prev_slug, prev_title, next_slug, next_title FROM (
+				SELECT id,
+					ROW_NUMBER() OVER reading AS position,
+					COUNT(*) OVER () AS total,
+					LAG(slug) OVER reading AS prev_slug,
+					LAG(title) OVER reading AS prev_title,
+					LEAD(slug) OVER reading AS next_slug,
+					LEAD(title) OVER reading AS next_title
+				FROM chapters
+				WHERE project_id = ? AND deleted_at IS NULL AND (`+releasedChapter+` OR id = ?)
+				WINDOW reading AS (ORDER BY sort_key ASC, created_at ASC)
+			) AS ordered WHERE id = ?`, chapter.ProjectID, ChapterPublished, time.Now(), chapter.ID, chapter.ID).
+			Scan(&row)
+		if result.Error != nil {
+			return ChapterNavigation{}, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ChapterNavigation{}, gorm.ErrRecordNotFound
+		}
+		navigation := ChapterNavigation{Position: row.Position, Total: row.Total}
+		if row.PrevSlug != nil {
+			navigation.Previous = &ChapterLink{Slug: *row.PrevSlug, Title: *row.PrevTitle}
+		}
+		if row.NextSlug != nil {
+			navigation.Next = &ChapterLink{Slug: *row.NextSlug, Title: *row.NextTitle}
+		}
+		return navigation, nil
+	}
+}
+
+// Sıralama alanları eklenmeden önce oluşturulmuş bölümleri created_at sırasıyla numaralar.
+// Sütunlar eklendiğinde eski satırlarda sort_key NULL'dır, yeni bölümler hep bir anahtarla eklenir;
+// bu yüzden sadece bir kere çalışır ve sonradan değiştirilmiş sıralamaya dokunmaz.
+func backfillChapterOrder(db *gorm.DB) error {
+	return db.Exec(`UPDATE chapters SET sort_key = (
+			SELECT COUNT(*) FROM chapters AS c
+			WHERE c.project_id = chapters.project_id
+			AND (c.created_at < chapters.created_at OR (c.created_at = chapters.created_at AND c.id <= chapters.id))
+		), number = CASE WHEN number IS NULL OR number = 0 THEN (
+			SELECT COUNT(*) FROM chapters AS c
+			WHERE c.project_id = chapters.project_id
+			AND (c.created_at < chapters.created_at OR (c.created_at = chapters.created_at AND c.id <= chapters.id))
+		) ELSE number END
+		WHERE sort_key IS NULL`).Error
+}
+
+// Okuyucuya gösterilecek yayın tarihi, eski bölümlerde PublishAt boş
+func (c Chapter) ReleaseTime() time.Time {
+	if c.PublishAt != nil {
+		return *c.PublishAt
+	}
+	return c.CreatedAt
+}
+
+// Taslak, planlanmış ve yayın zamanı gelmemiş bölümleri sadece staff görebilir, releasedChapter ile aynı kural
+func (c Chapter) IsPublic() bool {
+	if c.Status != ChapterPublished && c.Status != ChapterUnlisted {
+		return false
+	}
+	return c.PublishAt == nil || !c.PublishAt.After(time.Now())
+}
+
+// Yayın zamanı ileride olan yayınlanmış bölüm planlanmış sayılır, zamanı gelince scheduler yayınlar
+func (c *Chapter) ScheduleIfFuture(now time.Time) {
+	if c.Status == ChapterPublished && c.PublishAt != nil && c.PublishAt.After(now) {
+		c.Status = ChapterScheduled
+	}
+}
+
 func (c Chapter) IsValid() bool {
 	if len(c.Title) > 128 || len(c.Title) < 3 {
 		return false
@@ -131,5 +545,14 @@ func (c Chapter) IsValid() bool {
 	if len(c.Content) < 64 {
 		return false
 	}
+	switch c.Status {
+	case ChapterDraft, ChapterPublished, ChapterUnlisted:
+	case ChapterScheduled:
+		if c.PublishAt == nil {
+			return false
+		}
+	default:
+		return false
+	}
 	return true
 }
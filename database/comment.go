@@ -1,10 +1,41 @@
 package database
 
-import "gorm.io/gorm"
+import (
+	"context"
+	"strings"
+	"time"
 
-type Comment struct{}
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
-type CommentRepo interface{}
+type Comment struct {
+	ID        string         `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	EditedAt  *time.Time     `json:"edited_at"`
+	Content   string         `gorm:"type:text;not null;" json:"content"`
+	UserID    string         `gorm:"index" json:"user_id"`
+	User      User           `gorm:"foreignKey:UserID" json:"-"`
+	ProjectID *string        `gorm:"index" json:"project_id"`
+	ChapterID *string        `gorm:"index" json:"chapter_id"`
+	ParentID  *string        `gorm:"index" json:"parent_id"`
+	// İlk yorumun ID'si, bütün thread'i tek sorguda çekmek için
+	RootID    *string `gorm:"index" json:"root_id"`
+	IsDeleted bool    `gorm:"-" json:"is_deleted"`
+}
+
+type CommentRepo interface {
+	Find(ctx context.Context, id string) (Comment, error)
+	Add(ctx context.Context, comment Comment) (Comment, error)
+	Update(ctx context.Context, comment Comment) (Comment, error)
+	Delete(ctx context.Context, comment Comment) error
+	ListByChapter(ctx context.Context, chapter_id string, limit, offset int) ([]Comment, error)
+	ListByProject(ctx context.Context, project_id string, limit, offset int) ([]Comment, error)
+	CountByChapter(ctx context.Context, chapter_id string) (int64, error)
+	CountByProject(ctx context.Context, project_id string) (int64, error)
+}
 
 type SqlCommentRepo struct {
 	db *gorm.DB
@@ -15,3 +46,177 @@ func NewSqlCommentRepo(db *gorm.DB) *SqlCommentRepo {
 		db: db,
 	}
 }
+
+func (repo SqlCommentRepo) Find(ctx context.Context, id string) (Comment, error) {
+	select {
+	case <-ctx.Done():
+		return Comment{}, ErrorOperationCanceled
+	default:
+		var comment Comment
+		result := repo.db.Preload("User").First(&comment, "id = ?", id)
+		return comment, result.Error
+	}
+}
+
+// Reply ise parent ile aynı chapter/project'e bağlanır
+func (repo SqlCommentRepo) Add(ctx context.Context, comment Comment) (Comment, error) {
+	select {
+	case <-ctx.Done():
+		return comment, ErrorOperationCanceled
+	default:
+		if comment.ParentID != nil {
+			var parent Comment
+			result := repo.db.First(&parent, "id = ?", *comment.ParentID)
+			if result.Error != nil {
+				return comment, result.Error
+			}
+			comment.ProjectID = parent.ProjectID
+			comment.ChapterID = parent.ChapterID
+			if parent.RootID != nil {
+				comment.RootID = parent.RootID
+			} else {
+				comment.RootID = &parent.ID
+			}
+		}
+		if !comment.IsValid() {
+			return comment, ErrorInvalidComment
+		}
+		comment.ID = uuid.New().String()
+		result := repo.db.Create(&comment)
+		return comment, result.Error
+	}
+}
+
+func (repo SqlCommentRepo) Update(ctx context.Context, comment Comment) (Comment, error) {
+	select {
+	case <-ctx.Done():
+		return comment, ErrorOperationCanceled
+	default:
+		if !comment.IsValid() {
+			return comment, ErrorInvalidComment
+		}
+		now := time.Now()
+		comment.EditedAt = &now
+		result := repo.db.Model(&comment).Updates(map[string]interface{}{
+			"content":   comment.Content,
+			"edited_at": comment.EditedAt,
+		})
+		return comment, result.Error
+	}
+}
+
+func (repo SqlCommentRepo) Delete(ctx context.Context, comment Comment) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Delete(&comment)
+		return result.Error
+	}
+}
+
+func (repo SqlCommentRepo) ListByChapter(ctx context.Context, chapter_id string, limit, offset int) ([]Comment, error) {
+	return repo.listThreads(ctx, "chapter_id = ?", chapter_id, limit, offset)
+}
+
+// Sadece projeye yazılan yorumlar, chapter yorumları dahil değil
+func (repo SqlCommentRepo) ListByProject(ctx context.Context, project_id string, limit, offset int) ([]Comment, error) {
+	return repo.listThreads(ctx, "project_id = ? AND chapter_id IS NULL", project_id, limit, offset)
+}
+
+func (repo SqlCommentRepo) CountByChapter(ctx context.Context, chapter_id string) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&Comment{}).Where("chapter_id = ?", chapter_id).Count(&count)
+		return count, result.Error
+	}
+}
+
+func (repo SqlCommentRepo) CountByProject(ctx context.Context, project_id string) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&Comment{}).Where("project_id = ? AND chapter_id IS NULL", project_id).Count(&count)
+		return count, result.Error
+	}
+}
+
+// Limit ve offset kök yorumlara uygulanır, cevaplar thread'leriyle birlikte gelir.
+// Silinmiş yorumlar thread bozulmasın diye içerikleri boşaltılarak döner.
+func (repo SqlCommentRepo) listThreads(ctx context.Context, query string, id string, limit, offset int) ([]Comment, error) {
+	select {
+	case <-ctx.Done():
+		return []Comment{}, ErrorOperationCanceled
+	default:
+		var roots []Comment
+		// Silinmemiş cevabı olmayan silinmiş kökler sayfa kısa kalmasın diye limitten önce elenir
+		result := repo.db.Unscoped().Preload("User").
+			Where(query+" AND parent_id IS NULL", id).
+			Where("deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments AS replies WHERE replies.root_id = comments.id AND replies.deleted_at IS NULL)").
+			Limit(limit).
+			Offset(offset).
+			Order("created_at desc").
+			Find(&roots)
+		if result.Error != nil {
+			return roots, result.Error
+		}
+		if len(roots) == 0 {
+			return roots, nil
+		}
+		rootIDs := make([]string, len(roots))
+		for i, root := range roots {
+			rootIDs[i] = root.ID
+		}
+		var replies []Comment
+		result = repo.db.Unscoped().Preload("User").
+			Where("root_id IN ?", rootIDs).
+			Order("created_at asc").
+			Find(&replies)
+		if result.Error != nil {
+			return roots, result.Error
+		}
+		hasReplies := make(map[string]bool)
+		for _, reply := range replies {
+			if !reply.DeletedAt.Valid {
+				hasReplies[*reply.RootID] = true
+			}
+		}
+		var comments []Comment
+		for _, comment := range append(roots, replies...) {
+			if comment.DeletedAt.Valid {
+				if comment.ParentID == nil && !hasReplies[comment.ID] {
+					continue
+				}
+				comment.redact()
+			}
+			comments = append(comments, comment)
+		}
+		return comments, nil
+	}
+}
+
+func (c *Comment) redact() {
+	c.IsDeleted = true
+	c.Content = ""
+	c.UserID = ""
+	c.User = User{}
+}
+
+func (c Comment) IsValid() bool {
+	content := strings.TrimSpace(c.Content)
+	if len(content) < 1 || len(content) > 4096 {
+		return false
+	}
+	if c.UserID == "" {
+		return false
+	}
+	if c.ProjectID == nil && c.ChapterID == nil {
+		return false
+	}
+	return true
+}
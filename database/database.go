@@ -13,31 +13,87 @@ import (
 )
 
 type Database struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Driver string
 
-	Users    UserRepo
-	Projects ProjectRepo
-	Chapters ChapterRepo
-	Comments CommentRepo
+	Users         UserRepo
+	Projects      ProjectRepo
+	Chapters      ChapterRepo
+	Revisions     ChapterRevisionRepo
+	Volumes       VolumeRepo
+	Comments      CommentRepo
+	Progress      ReadingProgressRepo
+	Library       LibraryRepo
+	Shelves       ShelfRepo
+	Reviews       ReviewRepo
+	Notifications NotificationRepo
+	Sessions      SessionRepo
+	Roles         RoleRepo
+	Teams         TeamRepo
+	Trash         TrashRepo
+	Tokens        UserTokenRepo
+	TwoFactor     TwoFactorRepo
+	Settings      SettingRepo
+	APIKeys       APIKeyRepo
+	Identities    IdentityRepo
+	Audit         AuditRepo
+	LoginLocks    LoginLockRepo
+	Search        SearchRepo
 }
 
 func New(driver string, source string, config *gorm.Config) (*Database, error) {
 	if strings.TrimSpace(source) == "" {
 		return nil, ErrorDatabaseSourceInvalid
 	}
-	db := &Database{}
+	db := &Database{Driver: driver}
 	if err := db.connect(driver, source, config); err != nil {
 		log.Println("Failed to connect database.")
 		return nil, err
 	}
-	if err := db.DB.AutoMigrate(&User{}, &Project{}, &Chapter{}); err != nil {
+	if err := db.DB.AutoMigrate(&User{}, &Project{}, &Volume{}, &Chapter{}, &ChapterRevision{}, &Comment{}, &ReadingProgress{}, &Shelf{}, &LibraryEntry{}, &Review{}, &ReviewVote{}, &Notification{}, &Session{}, &RetiredToken{}, &RevokedToken{}, &UserRole{}, &ProjectMember{}, &ProjectInvitation{}, &SlugRedirect{}, &UserToken{}, &TwoFactor{}, &RecoveryCode{}, &Setting{}, &APIKey{}, &Identity{}, &AuthState{}, &AuditEvent{}, &LoginLock{}); err != nil {
 		log.Println("Failed to migrate database.")
 		return nil, err
 	}
+	if err := backfillChapterOrder(db.DB); err != nil {
+		log.Println("Failed to migrate chapter order.")
+		return nil, err
+	}
+	if err := migrateStaffFlags(db.DB); err != nil {
+		log.Println("Failed to migrate staff flags to roles.")
+		return nil, err
+	}
+	if err := migrateProjectOwners(db.DB); err != nil {
+		log.Println("Failed to migrate project owners.")
+		return nil, err
+	}
 	db.Users = NewSqlUserRepo(db.DB)
 	db.Projects = NewSqlProjectRepo(db.DB)
 	db.Chapters = NewSqlChapterRepo(db.DB)
-	//db.Comments = NewSqlCommentRepo(db.db)
+	db.Revisions = NewSqlChapterRevisionRepo(db.DB)
+	db.Volumes = NewSqlVolumeRepo(db.DB)
+	db.Comments = NewSqlCommentRepo(db.DB)
+	db.Progress = NewSqlReadingProgressRepo(db.DB)
+	db.Library = NewSqlLibraryRepo(db.DB)
+	db.Shelves = NewSqlShelfRepo(db.DB)
+	db.Reviews = NewSqlReviewRepo(db.DB)
+	db.Notifications = NewSqlNotificationRepo(db.DB)
+	db.Sessions = NewSqlSessionRepo(db.DB)
+	db.Roles = NewSqlRoleRepo(db.DB)
+	db.Teams = NewSqlTeamRepo(db.DB)
+	db.Trash = NewSqlTrashRepo(db.DB)
+	db.Tokens = NewSqlUserTokenRepo(db.DB)
+	db.TwoFactor = NewSqlTwoFactorRepo(db.DB)
+	db.Settings = NewSqlSettingRepo(db.DB)
+	db.APIKeys = NewSqlAPIKeyRepo(db.DB)
+	db.Identities = NewSqlIdentityRepo(db.DB)
+	db.Audit = NewSqlAuditRepo(db.DB)
+	db.LoginLocks = NewSqlLoginLockRepo(db.DB)
+	search, err := NewSqlSearchRepo(db.DB, driver)
+	if err != nil {
+		log.Println("Failed to set up search index.")
+		return nil, err
+	}
+	db.Search = search
 	return db, nil
 }
 
@@ -72,7 +128,7 @@ func (db *Database) connect_postgres(source string, config *gorm.Config) error {
 }
 
 func (db *Database) connect_sqlite(source string, config *gorm.Config) error {
-	sqlDb := sqlite.Open(source)
+	sqlDb := sqlite.New(sqlite.Config{DriverName: sqliteDriverName, DSN: source})
 	gdb, err := gorm.Open(sqlDb, config)
 	if err != nil {
 		return err
@@ -15,8 +15,35 @@ var (
 	// context
 	ErrorOperationCanceled = errors.New("operation canceled")
 	// Validations
-	ErrorInvalidUser    = errors.New("invalid user")
-	ErrorInvalidProject = errors.New("invalid project")
+	ErrorInvalidUser         = errors.New("invalid user")
+	ErrorInvalidProject      = errors.New("invalid project")
+	ErrorInvalidChapter      = errors.New("invalid chapter")
+	ErrorInvalidComment      = errors.New("invalid comment")
+	ErrorInvalidVolume       = errors.New("invalid volume")
+	ErrorInvalidOrder        = errors.New("chapter order must list every chapter of the project exactly once")
+	ErrorInvalidProgress     = errors.New("invalid reading progress")
+	ErrorInvalidShelf        = errors.New("invalid shelf")
+	ErrorInvalidLibraryEntry = errors.New("invalid library entry")
+	ErrorInvalidReview       = errors.New("invalid review")
+	ErrorInvalidVote         = errors.New("can not vote on own review")
+	ErrorInvalidNotification = errors.New("invalid notification")
+	ErrorInvalidSession      = errors.New("invalid or expired session")
+	ErrorSessionReused       = errors.New("refresh token reused, session revoked")
+	ErrorInvalidRole         = errors.New("invalid role")
+	ErrorLastAdmin           = errors.New("can not remove the last admin")
+	ErrorInvalidMember       = errors.New("invalid team member")
+	ErrorInvalidInvitation   = errors.New("invalid invitation")
+	ErrorAlreadyMember       = errors.New("user is already in the team")
+	ErrorLastOwner           = errors.New("project must keep at least one owner")
+	ErrorInvalidTrashKind    = errors.New("invalid trash kind")
+	ErrorParentDeleted       = errors.New("parent is deleted, restore it first")
+	ErrorInvalidToken        = errors.New("invalid or expired token")
+	ErrorInvalidCode         = errors.New("invalid authentication code")
+	ErrorTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrorTwoFactorDisabled   = errors.New("two-factor authentication is not enabled")
+	ErrorInvalidAPIKey       = errors.New("invalid api key")
+	ErrorInvalidIdentity     = errors.New("invalid identity")
+	ErrorIdentityLinked      = errors.New("external account is linked to another user")
 	//
 	ErrorNotImplemented = errors.New("not yet implemented")
 )
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Harici sağlayıcıdaki hesap. Sağlayıcı ve subject ikilisi tek bir kullanıcıya bağlanabilir.
+type Identity struct {
+	ID        string    `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `gorm:"not null;index;" json:"-"`
+	Provider  string    `gorm:"not null;size:64;uniqueIndex:idx_identity_subject;" json:"provider"`
+	Subject   string    `gorm:"not null;size:256;uniqueIndex:idx_identity_subject;" json:"-"`
+	Email     string    `gorm:"size:256;" json:"email"`
+}
+
+// Sağlayıcıya yönlendirilen girişin bilgileri, callback'te bir kez kullanılır.
+// UserID doluysa giriş değil, o kullanıcıya hesap bağlama akışıdır.
+type AuthState struct {
+	StateHash string `gorm:"primaryKey;size:64;"`
+	CreatedAt time.Time
+	Provider  string    `gorm:"not null;size:64;"`
+	Nonce     string    `gorm:"not null;size:64;"`
+	Verifier  string    `gorm:"not null;size:128;"`
+	UserID    string    `gorm:"index;"`
+	ExpiresAt time.Time `gorm:"not null;index;"`
+}
+
+type IdentityRepo interface {
+	Find(ctx context.Context, provider string, subject string) (Identity, error)
+	List(ctx context.Context, user_id string) ([]Identity, error)
+	Link(ctx context.Context, identity Identity) (Identity, error)
+	Unlink(ctx context.Context, user_id string, id string) error
+	CreateState(ctx context.Context, state AuthState) error
+	TakeState(ctx context.Context, provider string, state_hash string) (AuthState, error)
+	PurgeStates(ctx context.Context, now time.Time) (int64, error)
+}
+
+type SqlIdentityRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlIdentityRepo(db *gorm.DB) *SqlIdentityRepo {
+	return &SqlIdentityRepo{
+		db: db,
+	}
+}
+
+func (repo SqlIdentityRepo) Find(ctx context.Context, provider string, subject string) (Identity, error) {
+	select {
+	case <-ctx.Done():
+		return Identity{}, ErrorOperationCanceled
+	default:
+		var identity Identity
+		result := repo.db.First(&identity, "provider = ? AND subject = ?", provider, subject)
+		return identity, result.Error
+	}
+}
+
+func (repo SqlIdentityRepo) List(ctx context.Context, user_id string) ([]Identity, error) {
+	select {
+	case <-ctx.Done():
+		return []Identity{}, ErrorOperationCanceled
+	default:
+		var identities []Identity
+		result := repo.db.Where("user_id = ?", user_id).Order("created_at asc").Find(&identities)
+		return identities, result.Error
+	}
+}
+
+// Hesap zaten bu kullanıcıya bağlıysa mevcut kayıt döner, başka kullanıcıya bağlıysa ErrorIdentityLinked
+func (repo SqlIdentityRepo) Link(ctx context.Context, identity Identity) (Identity, error) {
+	select {
+	case <-ctx.Done():
+		return identity, ErrorOperationCanceled
+	default:
+		if identity.UserID == "" || identity.Provider == "" || identity.Subject == "" {
+			return identity, ErrorInvalidIdentity
+		}
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			var existing Identity
+			result := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).Limit(1).Find(&existing)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				if existing.UserID != identity.UserID {
+					return ErrorIdentityLinked
+				}
+				identity = existing
+				return nil
+			}
+			identity.ID = uuid.New().String()
+			return tx.Create(&identity).Error
+		})
+		return identity, err
+	}
+}
+
+func (repo SqlIdentityRepo) Unlink(ctx context.Context, user_id string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Where("id = ? AND user_id = ?", id, user_id).Delete(&Identity{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+}
+
+func (repo SqlIdentityRepo) CreateState(ctx context.Context, state AuthState) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Create(&state)
+		return result.Error
+	}
+}
+
+// State'i silip döner, aynı state ikinci kez kullanılamaz. Yoksa ya da süresi dolmuşsa ErrorInvalidToken.
+func (repo SqlIdentityRepo) TakeState(ctx context.Context, provider string, state_hash string) (AuthState, error) {
+	select {
+	case <-ctx.Done():
+		return AuthState{}, ErrorOperationCanceled
+	default:
+		var state AuthState
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("state_hash = ? AND provider = ?", state_hash, provider).Limit(1).Find(&state)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrorInvalidToken
+			}
+			result = tx.Where("state_hash = ?", state_hash).Delete(&AuthState{})
+			if result.Error != nil {
+				return result.Error
+			}
+			// Aynı anda gelen iki callback'ten sadece biri siler
+			if result.RowsAffected == 0 || !state.ExpiresAt.After(time.Now()) {
+				return ErrorInvalidToken
+			}
+			return nil
+		})
+		return state, err
+	}
+}
+
+func (repo SqlIdentityRepo) PurgeStates(ctx context.Context, now time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		result := repo.db.Where("expires_at < ?", now).Delete(&AuthState{})
+		return result.RowsAffected, result.Error
+	}
+}
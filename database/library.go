@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Her takip edilen proje bu hazır raflardan birinde durur.
+// Kullanıcının kendi rafları bunlara ek olarak etiket gibi çalışır.
+const (
+	ShelfReading    = "reading"
+	ShelfPlanToRead = "plan_to_read"
+	ShelfCompleted  = "completed"
+	ShelfDropped    = "dropped"
+)
+
+var DefaultShelves = []string{ShelfReading, ShelfPlanToRead, ShelfCompleted, ShelfDropped}
+
+type Shelf struct {
+	ID        string    `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    string    `gorm:"not null;uniqueIndex:idx_user_shelf;" json:"-"`
+	Name      string    `gorm:"not null;size:64;uniqueIndex:idx_user_shelf;" json:"name"`
+}
+
+type LibraryEntry struct {
+	ID            string    `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	UserID        string    `gorm:"not null;uniqueIndex:idx_library_entry;" json:"-"`
+	ProjectID     string    `gorm:"not null;uniqueIndex:idx_library_entry;" json:"project_id"`
+	Project       Project   `gorm:"foreignKey:ProjectID" json:"-"`
+	Status        string    `gorm:"not null;size:16;default:reading;index;" json:"status"`
+	Shelves       []Shelf   `gorm:"many2many:library_entry_shelves;" json:"shelves"`
+	LastChapterID *string   `json:"last_chapter_id"`
+	// Son açılan bölümden sonra yayınlanmış bölüm sayısı, sadece List doldurur
+	Unread int64 `gorm:"->;-:migration" json:"unread"`
+}
+
+type LibraryRepo interface {
+	Find(ctx context.Context, user_id string, project_id string) (LibraryEntry, error)
+	Follow(ctx context.Context, entry LibraryEntry) (LibraryEntry, error)
+	Unfollow(ctx context.Context, user_id string, project_id string) error
+	SetShelves(ctx context.Context, entry LibraryEntry, shelf_ids []string) (LibraryEntry, error)
+	List(ctx context.Context, user_id string, status string, shelf_id string, limit, offset int) ([]LibraryEntry, error)
+	MarkOpened(ctx context.Context, user_id string, chapter Chapter) error
+	Followers(ctx context.Context, project_id string) ([]string, error)
+}
+
+type ShelfRepo interface {
+	Find(ctx context.Context, id string) (Shelf, error)
+	Add(ctx context.Context, shelf Shelf) (Shelf, error)
+	Update(ctx context.Context, shelf Shelf) (Shelf, error)
+	Delete(ctx context.Context, shelf Shelf) error
+	List(ctx context.Context, user_id string) ([]Shelf, error)
+}
+
+type SqlLibraryRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlLibraryRepo(db *gorm.DB) *SqlLibraryRepo {
+	return &SqlLibraryRepo{
+		db: db,
+	}
+}
+
+type SqlShelfRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlShelfRepo(db *gorm.DB) *SqlShelfRepo {
+	return &SqlShelfRepo{
+		db: db,
+	}
+}
+
+func (repo SqlLibraryRepo) Find(ctx context.Context, user_id string, project_id string) (LibraryEntry, error) {
+	select {
+	case <-ctx.Done():
+		return LibraryEntry{}, ErrorOperationCanceled
+	default:
+		var entry LibraryEntry
+		result := repo.withUnread().Preload("Project").Preload("Shelves").
+			First(&entry, "user_id = ? AND project_id = ?", user_id, project_id)
+		return entry, result.Error
+	}
+}
+
+// Proje zaten kütüphanedeyse sadece rafı değişir
+func (repo SqlLibraryRepo) Follow(ctx context.Context, entry LibraryEntry) (LibraryEntry, error) {
+	select {
+	case <-ctx.Done():
+		return entry, ErrorOperationCanceled
+	default:
+		if entry.Status == "" {
+			entry.Status = ShelfReading
+		}
+		if !entry.IsValid() {
+			return entry, ErrorInvalidLibraryEntry
+		}
+		entry.ID = uuid.New().String()
+		result := repo.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "project_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"status", "updated_at"}),
+		}).Create(&entry)
+		if result.Error != nil {
+			return entry, result.Error
+		}
+		return repo.Find(ctx, entry.UserID, entry.ProjectID)
+	}
+}
+
+func (repo SqlLibraryRepo) Unfollow(ctx context.Context, user_id string, project_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			var entry LibraryEntry
+			if err := tx.First(&entry, "user_id = ? AND project_id = ?", user_id, project_id).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&entry).Association("Shelves").Clear(); err != nil {
+				return err
+			}
+			return tx.Delete(&entry).Error
+		})
+	}
+}
+
+// Girdinin özel raflarını verilen listeyle değiştirir, raflar kullanıcıya ait olmalı
+func (repo SqlLibraryRepo) SetShelves(ctx context.Context, entry LibraryEntry, shelf_ids []string) (LibraryEntry, error) {
+	select {
+	case <-ctx.Done():
+		return entry, ErrorOperationCanceled
+	default:
+		var shelves []Shelf
+		if len(shelf_ids) > 0 {
+			result := repo.db.Where("id IN ? AND user_id = ?", shelf_ids, entry.UserID).Find(&shelves)
+			if result.Error != nil {
+				return entry, result.Error
+			}
+		}
+		if len(shelves) != len(shelf_ids) {
+			return entry, ErrorInvalidShelf
+		}
+		if err := repo.db.Model(&entry).Association("Shelves").Replace(shelves); err != nil {
+			return entry, err
+		}
+		return repo.Find(ctx, entry.UserID, entry.ProjectID)
+	}
+}
+
+// status ya da shelf_id boşsa o filtre uygulanmaz, en son güncellenen başta gelir
+func (repo SqlLibraryRepo) List(ctx context.Context, user_id string, status string, shelf_id string, limit, offset int) ([]LibraryEntry, error) {
+	select {
+	case <-ctx.Done():
+		return []LibraryEntry{}, ErrorOperationCanceled
+	default:
+		var entries []LibraryEntry
+		query := repo.withUnread().Preload("Project").Preload("Shelves").
+			Where("library_entries.user_id = ?", user_id)
+		if status != "" {
+			query = query.Where("library_entries.status = ?", status)
+		}
+		if shelf_id != "" {
+			query = query.Where("library_entries.id IN (?)",
+				repo.db.Table("library_entry_shelves").Select("library_entry_id").Where("shelf_id = ?", shelf_id))
+		}
+		result := query.Order("library_entries.updated_at desc").
+			Limit(limit).
+			Offset(offset).
+			Find(&entries)
+		return entries, result.Error
+	}
+}
+
+// Kullanıcı takip ettiği projeden bir bölüm açtığında çağrılır.
+// Eski bir bölümü tekrar okumak okunmamış sayısını geri artırmasın diye sadece ileri gider.
+func (repo SqlLibraryRepo) MarkOpened(ctx context.Context, user_id string, chapter Chapter) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&LibraryEntry{}).
+			Where("user_id = ? AND project_id = ?", user_id, chapter.ProjectID).
+			Where("last_chapter_id IS NULL OR COALESCE((SELECT sort_key FROM chapters WHERE chapters.id = library_entries.last_chapter_id AND chapters.deleted_at IS NULL), 0) < ?", chapter.SortKey).
+			Update("last_chapter_id", chapter.ID)
+		return result.Error
+	}
+}
+
+// Projeyi takip eden kullanıcıların ID'leri, bırakmış (dropped) olanlar hariç
+func (repo SqlLibraryRepo) Followers(ctx context.Context, project_id string) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return []string{}, ErrorOperationCanceled
+	default:
+		var user_ids []string
+		result := repo.db.Model(&LibraryEntry{}).
+			Where("project_id = ? AND status <> ?", project_id, ShelfDropped).
+			Pluck("user_id", &user_ids)
+		return user_ids, result.Error
+	}
+}
+
+// Okunmamış bölüm sayısını, son açılan bölümden sonra gelen yayınlanmış bölümleri sayarak hesaplar
+func (repo SqlLibraryRepo) withUnread() *gorm.DB {
+	return repo.db.Model(&LibraryEntry{}).Select(`library_entries.*, (
+		SELECT COUNT(*) FROM chapters
+		WHERE chapters.project_id = library_entries.project_id
+		AND chapters.deleted_at IS NULL
+		AND `+releasedChapter+`
+		AND chapters.sort_key > COALESCE((
+			SELECT opened.sort_key FROM chapters AS opened
+			WHERE opened.id = library_entries.last_chapter_id AND opened.deleted_at IS NULL
+		), 0)
+	) AS unread`, ChapterPublished, time.Now())
+}
+
+func (repo SqlShelfRepo) Find(ctx context.Context, id string) (Shelf, error) {
+	select {
+	case <-ctx.Done():
+		return Shelf{}, ErrorOperationCanceled
+	default:
+		var shelf Shelf
+		result := repo.db.First(&shelf, "id = ?", id)
+		return shelf, result.Error
+	}
+}
+
+func (repo SqlShelfRepo) Add(ctx context.Context, shelf Shelf) (Shelf, error) {
+	select {
+	case <-ctx.Done():
+		return shelf, ErrorOperationCanceled
+	default:
+		shelf.Name = strings.TrimSpace(shelf.Name)
+		if !shelf.IsValid() {
+			return shelf, ErrorInvalidShelf
+		}
+		shelf.ID = uuid.New().String()
+		result := repo.db.Create(&shelf)
+		return shelf, result.Error
+	}
+}
+
+func (repo SqlShelfRepo) Update(ctx context.Context, shelf Shelf) (Shelf, error) {
+	select {
+	case <-ctx.Done():
+		return shelf, ErrorOperationCanceled
+	default:
+		shelf.Name = strings.TrimSpace(shelf.Name)
+		if !shelf.IsValid() {
+			return shelf, ErrorInvalidShelf
+		}
+		result := repo.db.Save(&shelf)
+		return shelf, result.Error
+	}
+}
+
+// Raf silinince içindeki projeler kütüphaneden çıkmaz, sadece raftan çıkar
+func (repo SqlShelfRepo) Delete(ctx context.Context, shelf Shelf) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM library_entry_shelves WHERE shelf_id = ?", shelf.ID).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&shelf).Error
+		})
+	}
+}
+
+func (repo SqlShelfRepo) List(ctx context.Context, user_id string) ([]Shelf, error) {
+	select {
+	case <-ctx.Done():
+		return []Shelf{}, ErrorOperationCanceled
+	default:
+		var shelves []Shelf
+		result := repo.db.Where("user_id = ?", user_id).Order("name asc").Find(&shelves)
+		return shelves, result.Error
+	}
+}
+
+func IsDefaultShelf(name string) bool {
+	for _, shelf := range DefaultShelves {
+		if shelf == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Shelf) IsValid() bool {
+	if len(s.Name) < 1 || len(s.Name) > 64 {
+		return false
+	}
+	// Hazır raflarla karışmasın
+	if IsDefaultShelf(s.Name) {
+		return false
+	}
+	return s.UserID != ""
+}
+
+func (e LibraryEntry) IsValid() bool {
+	if e.UserID == "" || e.ProjectID == "" {
+		return false
+	}
+	return IsDefaultShelf(e.Status)
+}
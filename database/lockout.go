@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Başarısız giriş sayacı. Key "user:<kullanıcı adı>" ya da "ip:<adres>" biçimindedir, kullanıcı adı
+// var olmasa da sayılır, böylece kilit davranışından hesabın var olup olmadığı anlaşılmaz.
+type LoginLock struct {
+	Key          string     `gorm:"primaryKey;column:lock_key;size:160;" json:"key"`
+	Failures     int        `gorm:"not null;default:0;" json:"failures"`
+	LastFailedAt time.Time  `gorm:"not null;index;" json:"last_failed_at"`
+	LockedUntil  *time.Time `gorm:"index;" json:"locked_until"`
+}
+
+// FreeAttempts kadar hatadan sonra her hata kilidi BaseLockout'tan başlayarak ikiye katlar, MaxLockout'u geçmez.
+// Son hatanın üzerinden Window kadar süre geçerse sayaç sıfırlanır.
+type LockoutPolicy struct {
+	FreeAttempts int
+	BaseLockout  time.Duration
+	MaxLockout   time.Duration
+	Window       time.Duration
+}
+
+type LoginLockRepo interface {
+	LockedUntil(ctx context.Context, now time.Time, keys ...string) (time.Time, error)
+	Fail(ctx context.Context, key string, policy LockoutPolicy, now time.Time) (LoginLock, error)
+	Reset(ctx context.Context, keys ...string) (int64, error)
+	ListLocked(ctx context.Context, now time.Time) ([]LoginLock, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SqlLoginLockRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlLoginLockRepo(db *gorm.DB) *SqlLoginLockRepo {
+	return &SqlLoginLockRepo{
+		db: db,
+	}
+}
+
+// Anahtarlardan en geç açılacak kilidin zamanı, kilit yoksa sıfır zaman
+func (repo SqlLoginLockRepo) LockedUntil(ctx context.Context, now time.Time, keys ...string) (time.Time, error) {
+	select {
+	case <-ctx.Done():
+		return time.Time{}, ErrorOperationCanceled
+	default:
+		var locks []LoginLock
+		result := repo.db.Where("lock_key IN ? AND locked_until > ?", keys, now).Find(&locks)
+		if result.Error != nil {
+			return time.Time{}, result.Error
+		}
+		var until time.Time
+		for _, lock := range locks {
+			if lock.LockedUntil.After(until) {
+				until = *lock.LockedUntil
+			}
+		}
+		return until, nil
+	}
+}
+
+// Hatayı sayıp gerekiyorsa kilitler. Aynı anahtara aynı anda gelen hatalar satır kilidiyle sırayla sayılır.
+func (repo SqlLoginLockRepo) Fail(ctx context.Context, key string, policy LockoutPolicy, now time.Time) (LoginLock, error) {
+	select {
+	case <-ctx.Done():
+		return LoginLock{}, ErrorOperationCanceled
+	default:
+		var lock LoginLock
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoginLock{Key: key, LastFailedAt: now}).Error; err != nil {
+				return err
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lock, "lock_key = ?", key).Error; err != nil {
+				return err
+			}
+			if now.Sub(lock.LastFailedAt) > policy.Window {
+				lock.Failures = 0
+			}
+			lock.Failures++
+			lock.LastFailedAt = now
+			if lock.Failures > policy.FreeAttempts {
+				until := now.Add(policy.Lockout(lock.Failures))
+				lock.LockedUntil = &until
+			}
+			return tx.Save(&lock).Error
+		})
+		return lock, err
+	}
+}
+
+// Sayaçları ve kilitleri siler, başarılı girişte ve admin kilidi açınca kullanılır
+func (repo SqlLoginLockRepo) Reset(ctx context.Context, keys ...string) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		result := repo.db.Where("lock_key IN ?", keys).Delete(&LoginLock{})
+		return result.RowsAffected, result.Error
+	}
+}
+
+func (repo SqlLoginLockRepo) ListLocked(ctx context.Context, now time.Time) ([]LoginLock, error) {
+	select {
+	case <-ctx.Done():
+		return []LoginLock{}, ErrorOperationCanceled
+	default:
+		var locks []LoginLock
+		result := repo.db.Where("locked_until > ?", now).Order("locked_until desc").Find(&locks)
+		return locks, result.Error
+	}
+}
+
+// Son hatası before'dan eski ve kilidi açılmış sayaçları siler
+func (repo SqlLoginLockRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		result := repo.db.Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).Delete(&LoginLock{})
+		return result.RowsAffected, result.Error
+	}
+}
+
+func (p LockoutPolicy) Lockout(failures int) time.Duration {
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	lockout := p.BaseLockout
+	for i := p.FreeAttempts + 1; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return lockout
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	NotificationNewChapter    = "new_chapter"
+	NotificationCommentReply  = "comment_reply"
+	NotificationProjectStatus = "project_status"
+	NotificationTeamInvite    = "team_invite"
+)
+
+type Notification struct {
+	ID        string     `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+	UserID    string     `gorm:"not null;index:idx_user_notification;" json:"user_id"`
+	ReadAt    *time.Time `gorm:"index:idx_user_notification;" json:"read_at"`
+	Kind      string     `gorm:"not null;size:32;" json:"kind"`
+	Message   string     `gorm:"not null;size:512;" json:"message"`
+	ProjectID *string    `json:"project_id"`
+	Project   *Project   `gorm:"foreignKey:ProjectID" json:"-"`
+	ChapterID *string    `json:"chapter_id"`
+	Chapter   *Chapter   `gorm:"foreignKey:ChapterID" json:"-"`
+	CommentID *string    `json:"comment_id"`
+}
+
+type NotificationRepo interface {
+	Find(ctx context.Context, user_id string, id string) (Notification, error)
+	FanOut(ctx context.Context, notification Notification, user_ids []string) ([]Notification, error)
+	List(ctx context.Context, user_id string, unread_only bool, limit, offset int) ([]Notification, error)
+	CountUnread(ctx context.Context, user_id string) (int64, error)
+	MarkRead(ctx context.Context, user_id string, id string) error
+	MarkAllRead(ctx context.Context, user_id string) error
+}
+
+type SqlNotificationRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlNotificationRepo(db *gorm.DB) *SqlNotificationRepo {
+	return &SqlNotificationRepo{
+		db: db,
+	}
+}
+
+func (repo SqlNotificationRepo) Find(ctx context.Context, user_id string, id string) (Notification, error) {
+	select {
+	case <-ctx.Done():
+		return Notification{}, ErrorOperationCanceled
+	default:
+		var notification Notification
+		result := repo.db.Preload("Project").Preload("Chapter").
+			First(&notification, "id = ? AND user_id = ?", id, user_id)
+		return notification, result.Error
+	}
+}
+
+// Aynı bildirimi her kullanıcı için ayrı satır olarak ekler ve eklenenleri döner
+func (repo SqlNotificationRepo) FanOut(ctx context.Context, notification Notification, user_ids []string) ([]Notification, error) {
+	select {
+	case <-ctx.Done():
+		return []Notification{}, ErrorOperationCanceled
+	default:
+		if !notification.IsValid() {
+			return []Notification{}, ErrorInvalidNotification
+		}
+		if len(user_ids) == 0 {
+			return []Notification{}, nil
+		}
+		notifications := make([]Notification, len(user_ids))
+		now := time.Now()
+		for i, user_id := range user_ids {
+			notifications[i] = notification
+			notifications[i].ID = uuid.New().String()
+			notifications[i].UserID = user_id
+			notifications[i].CreatedAt = now
+			notifications[i].Project = nil
+			notifications[i].Chapter = nil
+		}
+		result := repo.db.CreateInBatches(&notifications, 500)
+		return notifications, result.Error
+	}
+}
+
+func (repo SqlNotificationRepo) List(ctx context.Context, user_id string, unread_only bool, limit, offset int) ([]Notification, error) {
+	select {
+	case <-ctx.Done():
+		return []Notification{}, ErrorOperationCanceled
+	default:
+		var notifications []Notification
+		query := repo.db.Preload("Project").Preload("Chapter").Where("user_id = ?", user_id)
+		if unread_only {
+			query = query.Where("read_at IS NULL")
+		}
+		result := query.Order("created_at desc").Limit(limit).Offset(offset).Find(&notifications)
+		return notifications, result.Error
+	}
+}
+
+func (repo SqlNotificationRepo) CountUnread(ctx context.Context, user_id string) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", user_id).Count(&count)
+		return count, result.Error
+	}
+}
+
+// Başka kullanıcının bildirimi için de ErrRecordNotFound döner
+func (repo SqlNotificationRepo) MarkRead(ctx context.Context, user_id string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		var notification Notification
+		result := repo.db.First(&notification, "id = ? AND user_id = ?", id, user_id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if notification.ReadAt != nil {
+			return nil
+		}
+		return repo.db.Model(&notification).Update("read_at", time.Now()).Error
+	}
+}
+
+func (repo SqlNotificationRepo) MarkAllRead(ctx context.Context, user_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&Notification{}).
+			Where("user_id = ? AND read_at IS NULL", user_id).
+			Update("read_at", time.Now())
+		return result.Error
+	}
+}
+
+func (n Notification) IsValid() bool {
+	switch n.Kind {
+	case NotificationNewChapter, NotificationCommentReply, NotificationProjectStatus, NotificationTeamInvite:
+	default:
+		return false
+	}
+	length := utf8.RuneCountInString(n.Message)
+	return length > 0 && length <= 512
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kullanıcının okuduğu her bölüm için bir satır tutulur.
+// Projedeki en son güncellenen satır "kaldığı yer", hepsi birden okuma geçmişidir.
+type ReadingProgress struct {
+	ID        string    `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `gorm:"index" json:"updated_at"`
+	UserID    string    `gorm:"not null;uniqueIndex:idx_reading_progress;" json:"user_id"`
+	ChapterID string    `gorm:"not null;uniqueIndex:idx_reading_progress;" json:"chapter_id"`
+	Chapter   Chapter   `gorm:"foreignKey:ChapterID" json:"-"`
+	ProjectID string    `gorm:"not null;index;" json:"project_id"`
+	Project   Project   `gorm:"foreignKey:ProjectID" json:"-"`
+	Position  float64   `json:"position"` // 0-1 arası scroll oranı
+}
+
+type ReadingProgressRepo interface {
+	Save(ctx context.Context, progress ReadingProgress) (ReadingProgress, error)
+	Find(ctx context.Context, user_id string, project_id string) (ReadingProgress, error)
+	ContinueReading(ctx context.Context, user_id string, limit int) ([]ReadingProgress, error)
+	History(ctx context.Context, user_id string, limit, offset int) ([]ReadingProgress, error)
+	ClearProject(ctx context.Context, user_id string, project_id string) error
+	Clear(ctx context.Context, user_id string) error
+}
+
+type SqlReadingProgressRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlReadingProgressRepo(db *gorm.DB) *SqlReadingProgressRepo {
+	return &SqlReadingProgressRepo{
+		db: db,
+	}
+}
+
+// Aynı bölüm için tekrar çağrılırsa sadece pozisyonu ve zamanı günceller
+func (repo SqlReadingProgressRepo) Save(ctx context.Context, progress ReadingProgress) (ReadingProgress, error) {
+	select {
+	case <-ctx.Done():
+		return progress, ErrorOperationCanceled
+	default:
+		if !progress.IsValid() {
+			return progress, ErrorInvalidProgress
+		}
+		progress.ID = uuid.New().String()
+		result := repo.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "chapter_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"position", "updated_at"}),
+		}).Create(&progress)
+		if result.Error != nil {
+			return progress, result.Error
+		}
+		var saved ReadingProgress
+		result = repo.db.First(&saved, "user_id = ? AND chapter_id = ?", progress.UserID, progress.ChapterID)
+		return saved, result.Error
+	}
+}
+
+// Kullanıcının projede en son okuduğu bölüm. Sonradan taslağa alınan ya da ileri bir tarihe planlanan bölümler sayılmaz.
+func (repo SqlReadingProgressRepo) Find(ctx context.Context, user_id string, project_id string) (ReadingProgress, error) {
+	select {
+	case <-ctx.Done():
+		return ReadingProgress{}, ErrorOperationCanceled
+	default:
+		var progress ReadingProgress
+		result := repo.db.Preload("Chapter").Preload("Project").
+			Joins("JOIN chapters ON chapters.id = reading_progresses.chapter_id AND chapters.deleted_at IS NULL").
+			Where("reading_progresses.user_id = ? AND reading_progresses.project_id = ?", user_id, project_id).
+			Scopes(releasedChapters(time.Now())).
+			Order("reading_progresses.updated_at desc").
+			First(&progress)
+		return progress, result.Error
+	}
+}
+
+// Her projeden en son okunan bölüm, en yeni okunan proje başta
+func (repo SqlReadingProgressRepo) ContinueReading(ctx context.Context, user_id string, limit int) ([]ReadingProgress, error) {
+	select {
+	case <-ctx.Done():
+		return []ReadingProgress{}, ErrorOperationCanceled
+	default:
+		var progresses []ReadingProgress
+		latest := repo.db.Raw(`SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY project_id ORDER BY updated_at DESC) AS recency
+				FROM reading_progresses WHERE user_id = ?
+			) AS latest WHERE recency = 1`, user_id)
+		result := repo.db.Preload("Chapter").Preload("Project").
+			Where("id IN (?)", latest).
+			Order("updated_at desc").
+			Limit(limit).
+			Find(&progresses)
+		return progresses, result.Error
+	}
+}
+
+func (repo SqlReadingProgressRepo) History(ctx context.Context, user_id string, limit, offset int) ([]ReadingProgress, error) {
+	select {
+	case <-ctx.Done():
+		return []ReadingProgress{}, ErrorOperationCanceled
+	default:
+		var progresses []ReadingProgress
+		result := repo.db.Preload("Chapter").Preload("Project").
+			Where("user_id = ?", user_id).
+			Order("updated_at desc").
+			Limit(limit).
+			Offset(offset).
+			Find(&progresses)
+		return progresses, result.Error
+	}
+}
+
+func (repo SqlReadingProgressRepo) ClearProject(ctx context.Context, user_id string, project_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Where("user_id = ? AND project_id = ?", user_id, project_id).Delete(&ReadingProgress{})
+		return result.Error
+	}
+}
+
+func (repo SqlReadingProgressRepo) Clear(ctx context.Context, user_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Where("user_id = ?", user_id).Delete(&ReadingProgress{})
+		return result.Error
+	}
+}
+
+func (p ReadingProgress) IsValid() bool {
+	if p.UserID == "" || p.ChapterID == "" || p.ProjectID == "" {
+		return false
+	}
+	if p.Position < 0 || p.Position > 1 {
+		return false
+	}
+	return true
+}
@@ -2,10 +2,13 @@ package database
 
 import (
 	"context"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Project struct {
@@ -21,15 +24,39 @@ type Project struct {
 	Views     int32          `json:"views"`
 	Image     string         `json:"image"`
 	Slug      string         `gorm:"not null;unique;size:128;;" json:"slug"`
+	// Review'lerden hesaplanır, sadece ReviewRepo günceller
+	RatingAverage float64 `gorm:"not null;default:0;" json:"rating_average"`
+	RatingCount   int64   `gorm:"not null;default:0;" json:"rating_count"`
 }
 
+// Az review almış projeler ortalamaya bu kadar hayali oy varmış gibi yaklaştırılır
+const ratingPriorWeight = 10
+
 type ProjectRepo interface {
 	Find(ctx context.Context, id string) (Project, error)
 	FindBySlug(ctx context.Context, slug string) (Project, error)
-	Add(ctx context.Context, project Project) (Project, error)
+	FindByOldSlug(ctx context.Context, slug string) (Project, error)
+	Add(ctx context.Context, project Project, owner_id string) (Project, error)
+	AddWithChapters(ctx context.Context, project Project, chapters []Chapter, owner_id string) (Project, []Chapter, error)
 	Update(ctx context.Context, project Project) (Project, error)
 	Delete(ctx context.Context, project Project) error
 	List(ctx context.Context, limit int, offset int, orderby string) ([]Project, error)
+	ListByRating(ctx context.Context, limit int, offset int) ([]Project, error)
+	ListLatest(ctx context.Context, limit int, offset int, include_unpublished bool) ([]Project, error)
+	ListByTag(ctx context.Context, tag string, limit int, offset int) ([]Project, error)
+	ListByAuthor(ctx context.Context, author string, limit int, offset int) ([]Project, error)
+	Tags(ctx context.Context) ([]TagCount, error)
+	Authors(ctx context.Context) ([]AuthorCount, error)
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+type AuthorCount struct {
+	Author string `json:"author"`
+	Count  int64  `json:"count"`
 }
 
 type SqlProjectRepo struct {
@@ -64,7 +91,21 @@ func (repo SqlProjectRepo) FindBySlug(ctx context.Context, slug string) (Project
 	}
 }
 
-func (repo SqlProjectRepo) Add(ctx context.Context, project Project) (Project, error) {
+// Başlığı değişmiş projeyi eski slug'ından bulur
+func (repo SqlProjectRepo) FindByOldSlug(ctx context.Context, slug string) (Project, error) {
+	select {
+	case <-ctx.Done():
+		return Project{}, ErrorOperationCanceled
+	default:
+		var project Project
+		result := repo.db.Joins("JOIN slug_redirects ON slug_redirects.target_id = projects.id AND slug_redirects.kind = ?", "projects").
+			First(&project, "slug_redirects.slug = ?", slug)
+		return project, result.Error
+	}
+}
+
+// owner_id boş değilse o kullanıcı aynı transaction'da sahip olarak eklenir, boşsa projeyi sadece adminler düzenler
+func (repo SqlProjectRepo) Add(ctx context.Context, project Project, owner_id string) (Project, error) {
 	select {
 	case <-ctx.Done():
 		return project, ErrorOperationCanceled
@@ -73,29 +114,93 @@ func (repo SqlProjectRepo) Add(ctx context.Context, project Project) (Project, e
 			return project, ErrorInvalidProject
 		}
 		project.ID = uuid.New().String()
-		project.Slug = Slugify(project.Title)
-		result := repo.db.Create(&project)
-		return project, result.Error
+		err := withUniqueSlug(repo.db, func(tx *gorm.DB) error {
+			slug, err := uniqueSlug(tx, "projects", project.Title, "")
+			if err != nil {
+				return err
+			}
+			project.Slug = slug
+			if err := tx.Create(&project).Error; err != nil {
+				return err
+			}
+			if owner_id == "" {
+				return nil
+			}
+			return addMember(tx, ProjectMember{ProjectID: project.ID, UserID: owner_id, Role: TeamOwner})
+		})
+		return project, err
 	}
 }
 
+// Proje ve bölümlerini tek transaction'da ekler, biri bile eklenemezse hiçbiri kalmaz.
+// Bölümler verilen sırayla, created_at sırası korunacak şekilde eklenir.
+func (repo SqlProjectRepo) AddWithChapters(ctx context.Context, project Project, chapters []Chapter, owner_id string) (Project, []Chapter, error) {
+	select {
+	case <-ctx.Done():
+		return project, chapters, ErrorOperationCanceled
+	default:
+		added := make([]Chapter, 0, len(chapters))
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			project, err = SqlProjectRepo{db: tx}.Add(ctx, project, owner_id)
+			if err != nil {
+				return err
+			}
+			chapterRepo := SqlChapterRepo{db: tx}
+			createdAt := time.Now()
+			for i, chapter := range chapters {
+				chapter.ProjectID = project.ID
+				chapter.CreatedAt = createdAt.Add(time.Duration(i) * time.Millisecond)
+				chapter, err = chapterRepo.Add(ctx, chapter)
+				if err != nil {
+					return err
+				}
+				added = append(added, chapter)
+			}
+			return nil
+		})
+		return project, added, err
+	}
+}
+
+// Başlık değiştiyse slug yeniden üretilir, eski slug yönlendirme olarak kalır
 func (repo SqlProjectRepo) Update(ctx context.Context, project Project) (Project, error) {
 	select {
 	case <-ctx.Done():
 		return project, ErrorOperationCanceled
 	default:
-		result := repo.db.Save(&project)
-		return project, result.Error
+		if !project.IsValid() {
+			return project, ErrorInvalidProject
+		}
+		err := withUniqueSlug(repo.db, func(tx *gorm.DB) error {
+			var current Project
+			if err := tx.First(&current, "id = ?", project.ID).Error; err != nil {
+				return err
+			}
+			slug, err := renameSlug(tx, "projects", project.ID, current.Slug, current.Title, project.Title)
+			if err != nil {
+				return err
+			}
+			project.Slug = slug
+			return tx.Omit("rating_average", "rating_count").Save(&project).Error
+		})
+		return project, err
 	}
 }
 
+// Proje bölümleriyle birlikte silinir, hepsi aynı deleted_at'i alır
 func (repo SqlProjectRepo) Delete(ctx context.Context, project Project) error {
 	select {
 	case <-ctx.Done():
 		return ErrorOperationCanceled
 	default:
-		result := repo.db.Delete(&project)
-		return result.Error
+		now := time.Now()
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&Chapter{}).Where("project_id = ?", project.ID).Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+			return tx.Model(&project).Update("deleted_at", now).Error
+		})
 	}
 }
 
@@ -110,6 +215,132 @@ func (repo SqlProjectRepo) List(ctx context.Context, limit int, offset int, orde
 	}
 }
 
+// Bayes ağırlıklı puana göre sıralar: (C*m + ortalama*n) / (C + n).
+// m bütün review'lerin ortalaması, C ratingPriorWeight, n projenin review sayısı.
+func (repo SqlProjectRepo) ListByRating(ctx context.Context, limit int, offset int) ([]Project, error) {
+	select {
+	case <-ctx.Done():
+		return []Project{}, ErrorOperationCanceled
+	default:
+		var mean float64
+		result := repo.db.Model(&Review{}).Select("COALESCE(AVG(score), 0)").Scan(&mean)
+		if result.Error != nil {
+			return []Project{}, result.Error
+		}
+		var projects []Project
+		// Order() clause.Expr kabul etmiyor, sessizce yok sayıyor
+		result = repo.db.Limit(limit).Offset(offset).
+			Clauses(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "(? + rating_average * rating_count) / (? + rating_count) DESC, views DESC",
+				Vars: []interface{}{ratingPriorWeight * mean, ratingPriorWeight},
+			}}).
+			Find(&projects)
+		return projects, result.Error
+	}
+}
+
+// En son bölüm yayınlanan proje başta. include_unpublished ile taslak ve planlanmış bölümler de sayılır.
+func (repo SqlProjectRepo) ListLatest(ctx context.Context, limit int, offset int, include_unpublished bool) ([]Project, error) {
+	select {
+	case <-ctx.Done():
+		return []Project{}, ErrorOperationCanceled
+	default:
+		var projects []Project
+		query := repo.db.Table("projects").
+			Select("projects.*, MAX(COALESCE(chapters.publish_at, chapters.created_at)) as last_chapter_created_at").
+			Joins("JOIN chapters ON chapters.project_id = projects.id").
+			Where("chapters.deleted_at IS NULL AND projects.deleted_at IS NULL")
+		if !include_unpublished {
+			query = query.Scopes(releasedChapters(time.Now()))
+		}
+		result := query.
+			Group("projects.id").
+			Order("last_chapter_created_at DESC").
+			Limit(limit).
+			Offset(offset).
+			Find(&projects)
+		return projects, result.Error
+	}
+}
+
+// tags virgülle ayrılmış tutulduğu için etiketin başına ve sonuna virgül koyarak arar, büyük/küçük harf duyarsız
+func (repo SqlProjectRepo) ListByTag(ctx context.Context, tag string, limit int, offset int) ([]Project, error) {
+	select {
+	case <-ctx.Done():
+		return []Project{}, ErrorOperationCanceled
+	default:
+		var projects []Project
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+		result := repo.db.
+			Where(`',' || REPLACE(REPLACE(LOWER(tags), ', ', ','), ' ,', ',') || ',' LIKE ? ESCAPE '\'`, "%,"+replacer.Replace(tag)+",%").
+			Limit(limit).
+			Offset(offset).
+			Order("title asc").
+			Find(&projects)
+		return projects, result.Error
+	}
+}
+
+func (repo SqlProjectRepo) ListByAuthor(ctx context.Context, author string, limit int, offset int) ([]Project, error) {
+	select {
+	case <-ctx.Done():
+		return []Project{}, ErrorOperationCanceled
+	default:
+		var projects []Project
+		result := repo.db.Where("author = ?", author).Limit(limit).Offset(offset).Order("title asc").Find(&projects)
+		return projects, result.Error
+	}
+}
+
+// Bütün etiketler ve kaç projede geçtikleri, alfabetik
+func (repo SqlProjectRepo) Tags(ctx context.Context) ([]TagCount, error) {
+	select {
+	case <-ctx.Done():
+		return []TagCount{}, ErrorOperationCanceled
+	default:
+		var rows []string
+		result := repo.db.Model(&Project{}).Pluck("tags", &rows)
+		if result.Error != nil {
+			return []TagCount{}, result.Error
+		}
+		counts := make(map[string]int64)
+		for _, row := range rows {
+			seen := make(map[string]bool)
+			for _, tag := range strings.Split(row, ",") {
+				tag = strings.ToLower(strings.TrimSpace(tag))
+				if tag == "" || seen[tag] {
+					continue
+				}
+				seen[tag] = true
+				counts[tag]++
+			}
+		}
+		tags := make([]TagCount, 0, len(counts))
+		for tag, count := range counts {
+			tags = append(tags, TagCount{Tag: tag, Count: count})
+		}
+		sort.Slice(tags, func(i, j int) bool { return tags[i].Tag < tags[j].Tag })
+		return tags, nil
+	}
+}
+
+func (repo SqlProjectRepo) Authors(ctx context.Context) ([]AuthorCount, error) {
+	select {
+	case <-ctx.Done():
+		return []AuthorCount{}, ErrorOperationCanceled
+	default:
+		var authors []AuthorCount
+		result := repo.db.Model(&Project{}).
+			Select("author, COUNT(*) AS count").
+			Where("author <> ''").
+			Group("author").
+			Order("author asc").
+			Scan(&authors)
+		return authors, result.Error
+	}
+}
+
 func (p Project) IsValid() bool {
 	if len(p.Title) > 256 || len(p.Title) < 3 {
 		return false
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kullanıcı başına proje başına tek review, tekrar yazılınca eskisinin üstüne yazılır
+type Review struct {
+	ID             string    `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	UserID         string    `gorm:"not null;uniqueIndex:idx_user_review;" json:"user_id"`
+	User           User      `gorm:"foreignKey:UserID" json:"-"`
+	ProjectID      string    `gorm:"not null;uniqueIndex:idx_user_review;index;" json:"project_id"`
+	Score          int       `gorm:"not null;" json:"score"`
+	Content        string    `gorm:"type:text;" json:"content"`
+	HelpfulCount   int64     `gorm:"not null;default:0;" json:"helpful_count"`
+	UnhelpfulCount int64     `gorm:"not null;default:0;" json:"unhelpful_count"`
+}
+
+type ReviewVote struct {
+	UserID    string    `gorm:"primary_key;" json:"user_id"`
+	ReviewID  string    `gorm:"primary_key;index;" json:"review_id"`
+	Helpful   bool      `json:"helpful"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Review listelerinde kullanılabilecek sıralamalar
+const (
+	ReviewOrderRecent  = "created_at desc"
+	ReviewOrderHelpful = "helpful_count - unhelpful_count desc, created_at desc"
+)
+
+type ReviewRepo interface {
+	Find(ctx context.Context, id string) (Review, error)
+	FindByUser(ctx context.Context, user_id string, project_id string) (Review, error)
+	Save(ctx context.Context, review Review) (Review, error)
+	Delete(ctx context.Context, review Review) error
+	List(ctx context.Context, project_id string, limit, offset int, orderby string) ([]Review, error)
+	Count(ctx context.Context, project_id string) (int64, error)
+	Vote(ctx context.Context, review Review, user_id string, helpful bool) (Review, error)
+	Unvote(ctx context.Context, review Review, user_id string) (Review, error)
+}
+
+type SqlReviewRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlReviewRepo(db *gorm.DB) *SqlReviewRepo {
+	return &SqlReviewRepo{
+		db: db,
+	}
+}
+
+func (repo SqlReviewRepo) Find(ctx context.Context, id string) (Review, error) {
+	select {
+	case <-ctx.Done():
+		return Review{}, ErrorOperationCanceled
+	default:
+		var review Review
+		result := repo.db.Preload("User").First(&review, "id = ?", id)
+		return review, result.Error
+	}
+}
+
+func (repo SqlReviewRepo) FindByUser(ctx context.Context, user_id string, project_id string) (Review, error) {
+	select {
+	case <-ctx.Done():
+		return Review{}, ErrorOperationCanceled
+	default:
+		var review Review
+		result := repo.db.Preload("User").First(&review, "user_id = ? AND project_id = ?", user_id, project_id)
+		return review, result.Error
+	}
+}
+
+// Review'i ekler ya da günceller, projenin ortalama puanını aynı transaction'da yeniler
+func (repo SqlReviewRepo) Save(ctx context.Context, review Review) (Review, error) {
+	select {
+	case <-ctx.Done():
+		return review, ErrorOperationCanceled
+	default:
+		review.Content = strings.TrimSpace(review.Content)
+		if !review.IsValid() {
+			return review, ErrorInvalidReview
+		}
+		review.ID = uuid.New().String()
+		var saved Review
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "project_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"score", "content", "updated_at"}),
+			}).Create(&review)
+			if result.Error != nil {
+				return result.Error
+			}
+			if err := refreshRating(tx, review.ProjectID); err != nil {
+				return err
+			}
+			return tx.Preload("User").First(&saved, "user_id = ? AND project_id = ?", review.UserID, review.ProjectID).Error
+		})
+		return saved, err
+	}
+}
+
+func (repo SqlReviewRepo) Delete(ctx context.Context, review Review) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("review_id = ?", review.ID).Delete(&ReviewVote{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&review).Error; err != nil {
+				return err
+			}
+			return refreshRating(tx, review.ProjectID)
+		})
+	}
+}
+
+func (repo SqlReviewRepo) List(ctx context.Context, project_id string, limit, offset int, orderby string) ([]Review, error) {
+	select {
+	case <-ctx.Done():
+		return []Review{}, ErrorOperationCanceled
+	default:
+		var reviews []Review
+		result := repo.db.Preload("User").
+			Where("project_id = ?", project_id).
+			Limit(limit).
+			Offset(offset).
+			Order(orderby).
+			Find(&reviews)
+		return reviews, result.Error
+	}
+}
+
+func (repo SqlReviewRepo) Count(ctx context.Context, project_id string) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&Review{}).Where("project_id = ?", project_id).Count(&count)
+		return count, result.Error
+	}
+}
+
+// Kullanıcının önceki oyu varsa değiştirilir, kendi review'ine oy veremez
+func (repo SqlReviewRepo) Vote(ctx context.Context, review Review, user_id string, helpful bool) (Review, error) {
+	select {
+	case <-ctx.Done():
+		return review, ErrorOperationCanceled
+	default:
+		if review.UserID == user_id {
+			return review, ErrorInvalidVote
+		}
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			vote := ReviewVote{UserID: user_id, ReviewID: review.ID, Helpful: helpful}
+			result := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "review_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"helpful"}),
+			}).Create(&vote)
+			if result.Error != nil {
+				return result.Error
+			}
+			return refreshVotes(tx, &review)
+		})
+		return review, err
+	}
+}
+
+func (repo SqlReviewRepo) Unvote(ctx context.Context, review Review, user_id string) (Review, error) {
+	select {
+	case <-ctx.Done():
+		return review, ErrorOperationCanceled
+	default:
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ? AND review_id = ?", user_id, review.ID).Delete(&ReviewVote{}).Error; err != nil {
+				return err
+			}
+			return refreshVotes(tx, &review)
+		})
+		return review, err
+	}
+}
+
+// Projedeki rating_average ve rating_count'u review'lerden yeniden hesaplar
+func refreshRating(tx *gorm.DB, project_id string) error {
+	return tx.Exec(`UPDATE projects SET
+			rating_average = COALESCE((SELECT AVG(score) FROM reviews WHERE project_id = ?), 0),
+			rating_count = (SELECT COUNT(*) FROM reviews WHERE project_id = ?)
+		WHERE id = ?`, project_id, project_id, project_id).Error
+}
+
+func refreshVotes(tx *gorm.DB, review *Review) error {
+	err := tx.Exec(`UPDATE reviews SET
+			helpful_count = (SELECT COUNT(*) FROM review_votes WHERE review_id = ? AND helpful = ?),
+			unhelpful_count = (SELECT COUNT(*) FROM review_votes WHERE review_id = ? AND helpful = ?)
+		WHERE id = ?`, review.ID, true, review.ID, false, review.ID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Select("helpful_count", "unhelpful_count").First(review, "id = ?", review.ID).Error
+}
+
+func (r Review) IsValid() bool {
+	if r.Score < 1 || r.Score > 5 {
+		return false
+	}
+	if len(r.Content) > 8192 {
+		return false
+	}
+	return r.UserID != "" && r.ProjectID != ""
+}
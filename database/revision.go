@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChapterRevision struct {
+	ID        string         `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ChapterID string         `gorm:"not null;uniqueIndex:idx_chapter_revision;" json:"chapter_id"`
+	Number    int            `gorm:"not null;uniqueIndex:idx_chapter_revision;" json:"number"`
+	Title     string         `gorm:"not null;size:128;" json:"title"`
+	Content   string         `gorm:"type:text;" json:"content"`
+	EditorID  *string        `json:"editor_id"`
+	Editor    User           `gorm:"foreignKey:EditorID" json:"-"`
+	Note      string         `gorm:"size:256;" json:"note"`
+}
+
+type ChapterRevisionRepo interface {
+	Record(ctx context.Context, chapter Chapter, editor_id *string, note string) (ChapterRevision, error)
+	FindByNumber(ctx context.Context, chapter_id string, number int) (ChapterRevision, error)
+	List(ctx context.Context, chapter_id string, limit, offset int) ([]ChapterRevision, error)
+}
+
+type SqlChapterRevisionRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlChapterRevisionRepo(db *gorm.DB) *SqlChapterRevisionRepo {
+	return &SqlChapterRevisionRepo{
+		db: db,
+	}
+}
+
+// Bölümün şu anki halini yeni bir revizyon olarak kaydeder
+func (repo SqlChapterRevisionRepo) Record(ctx context.Context, chapter Chapter, editor_id *string, note string) (ChapterRevision, error) {
+	select {
+	case <-ctx.Done():
+		return ChapterRevision{}, ErrorOperationCanceled
+	default:
+		var number int
+		result := repo.db.Model(&ChapterRevision{}).
+			Where("chapter_id = ?", chapter.ID).
+			Select("COALESCE(MAX(number), 0)").
+			Scan(&number)
+		if result.Error != nil {
+			return ChapterRevision{}, result.Error
+		}
+		revision := ChapterRevision{
+			ID:        uuid.New().String(),
+			ChapterID: chapter.ID,
+			Number:    number + 1,
+			Title:     chapter.Title,
+			Content:   chapter.Content,
+			EditorID:  editor_id,
+			Note:      note,
+		}
+		result = repo.db.Create(&revision)
+		return revision, result.Error
+	}
+}
+
+func (repo SqlChapterRevisionRepo) FindByNumber(ctx context.Context, chapter_id string, number int) (ChapterRevision, error) {
+	select {
+	case <-ctx.Done():
+		return ChapterRevision{}, ErrorOperationCanceled
+	default:
+		var revision ChapterRevision
+		result := repo.db.Preload("Editor").First(&revision, "chapter_id = ? AND number = ?", chapter_id, number)
+		return revision, result.Error
+	}
+}
+
+func (repo SqlChapterRevisionRepo) List(ctx context.Context, chapter_id string, limit, offset int) ([]ChapterRevision, error) {
+	select {
+	case <-ctx.Done():
+		return []ChapterRevision{}, ErrorOperationCanceled
+	default:
+		var revisions []ChapterRevision
+		result := repo.db.Preload("Editor").
+			Where("chapter_id = ?", chapter_id).
+			Limit(limit).
+			Offset(offset).
+			Order("number desc").
+			Find(&revisions)
+		return revisions, result.Error
+	}
+}
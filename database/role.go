@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RoleAdmin      = "admin"
+	RoleModerator  = "moderator"
+	RoleEditor     = "editor"
+	RoleTranslator = "translator"
+	// Herkesin varsayılan rolü, veritabanında tutulmaz
+	RoleReader = "reader"
+)
+
+// Politika açıksa iki adımlı doğrulama isteyen site rolleri
+var StaffRoles = []string{RoleAdmin, RoleEditor, RoleModerator}
+
+const (
+	PermissionProjectCreate   = "project.create"
+	PermissionProjectEdit     = "project.edit"
+	PermissionProjectDelete   = "project.delete"
+	PermissionChapterCreate   = "chapter.create"
+	PermissionChapterEdit     = "chapter.edit"
+	PermissionChapterPublish  = "chapter.publish"
+	PermissionChapterDelete   = "chapter.delete"
+	PermissionCommentModerate = "comment.moderate"
+	PermissionReviewModerate  = "review.moderate"
+	PermissionUserManage      = "user.manage"
+	// Sadece proje ekibi rollerinde ve admin'de
+	PermissionTeamManage = "team.manage"
+)
+
+// Rollerin izinleri kodda tanımlı, kullanıcılara sadece rol atanır.
+// Site rolleri bütün projelerde geçerlidir, projeye özel yetkiler TeamPermissions'ta.
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionProjectCreate, PermissionProjectEdit, PermissionProjectDelete,
+		PermissionChapterCreate, PermissionChapterEdit, PermissionChapterPublish, PermissionChapterDelete,
+		PermissionCommentModerate, PermissionReviewModerate,
+		PermissionUserManage, PermissionTeamManage,
+	},
+	RoleModerator: {
+		PermissionCommentModerate, PermissionReviewModerate,
+	},
+	RoleEditor: {
+		PermissionProjectCreate, PermissionProjectEdit,
+		PermissionChapterCreate, PermissionChapterEdit, PermissionChapterPublish, PermissionChapterDelete,
+	},
+	// Çeviri grupları kendi projelerini açar, bölüm yetkileri projedeki ekip rolünden gelir
+	RoleTranslator: {
+		PermissionProjectCreate,
+	},
+	RoleReader: {},
+}
+
+type UserRole struct {
+	UserID    string `gorm:"primaryKey;"`
+	Role      string `gorm:"primaryKey;size:32;"`
+	CreatedAt time.Time
+}
+
+type RoleRepo interface {
+	Roles(ctx context.Context, user_id string) ([]string, error)
+	SetRoles(ctx context.Context, user_id string, roles []string) ([]string, error)
+	HasPermission(ctx context.Context, user_id string, permission string) (bool, error)
+	TwoFactorPending(ctx context.Context, user_id string) (bool, error)
+}
+
+type SqlRoleRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlRoleRepo(db *gorm.DB) *SqlRoleRepo {
+	return &SqlRoleRepo{
+		db: db,
+	}
+}
+
+// reader her zaman listede olur
+func (repo SqlRoleRepo) Roles(ctx context.Context, user_id string) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return []string{}, ErrorOperationCanceled
+	default:
+		return userRoles(repo.db, user_id)
+	}
+}
+
+// Kullanıcının rollerini verilenlerle değiştirir. Son admin'in admin rolü alınamaz.
+func (repo SqlRoleRepo) SetRoles(ctx context.Context, user_id string, roles []string) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return []string{}, ErrorOperationCanceled
+	default:
+		rows := []UserRole{}
+		seen := make(map[string]bool)
+		admin := false
+		for _, role := range roles {
+			if !IsRole(role) {
+				return []string{}, ErrorInvalidRole
+			}
+			if role == RoleReader || seen[role] {
+				continue
+			}
+			seen[role] = true
+			admin = admin || role == RoleAdmin
+			rows = append(rows, UserRole{UserID: user_id, Role: role})
+		}
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			if !admin {
+				// İki admin aynı anda birbirinin rolünü almasın diye admin satırları transaction bitene kadar kilitlenir.
+				// sqlite FOR UPDATE desteklemez, orada aynı anda sadece bir transaction yazabilir.
+				var admins []string
+				if err := tx.Model(&UserRole{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+					Where("role = ?", RoleAdmin).
+					Pluck("user_id", &admins).Error; err != nil {
+					return err
+				}
+				current, others := false, 0
+				for _, id := range admins {
+					if id == user_id {
+						current = true
+					} else {
+						others++
+					}
+				}
+				if current && others == 0 {
+					return ErrorLastAdmin
+				}
+			}
+			if err := tx.Where("user_id = ?", user_id).Delete(&UserRole{}).Error; err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+		})
+		if err != nil {
+			return []string{}, err
+		}
+		return userRoles(repo.db, user_id)
+	}
+}
+
+func (repo SqlRoleRepo) HasPermission(ctx context.Context, user_id string, permission string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ErrorOperationCanceled
+	default:
+		roles, err := effectiveRoles(repo.db, user_id)
+		if err != nil {
+			return false, err
+		}
+		return Can(roles, permission), nil
+	}
+}
+
+// Staff rolü olduğu halde politika gereği iki adımlı doğrulamayı açması beklenen kullanıcılar için true
+func (repo SqlRoleRepo) TwoFactorPending(ctx context.Context, user_id string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ErrorOperationCanceled
+	default:
+		roles, err := userRoles(repo.db, user_id)
+		if err != nil {
+			return false, err
+		}
+		return twoFactorPending(repo.db, user_id, roles)
+	}
+}
+
+func userRoles(db *gorm.DB, user_id string) ([]string, error) {
+	var roles []string
+	result := db.Model(&UserRole{}).Where("user_id = ?", user_id).Order("role asc").Pluck("role", &roles)
+	if result.Error != nil {
+		return []string{}, result.Error
+	}
+	return append(roles, RoleReader), nil
+}
+
+// İzin kontrollerinde kullanılan roller. İki adımlı doğrulama bekleniyorsa staff rolleri çıkarılır.
+func effectiveRoles(db *gorm.DB, user_id string) ([]string, error) {
+	roles, err := userRoles(db, user_id)
+	if err != nil {
+		return roles, err
+	}
+	pending, err := twoFactorPending(db, user_id, roles)
+	if err != nil || !pending {
+		return roles, err
+	}
+	effective := []string{}
+	for _, role := range roles {
+		if !IsStaffRole(role) {
+			effective = append(effective, role)
+		}
+	}
+	return effective, nil
+}
+
+func twoFactorPending(db *gorm.DB, user_id string, roles []string) (bool, error) {
+	staff := false
+	for _, role := range roles {
+		staff = staff || IsStaffRole(role)
+	}
+	if !staff {
+		return false, nil
+	}
+	required, err := setting(db, SettingRequireStaffTwoFactor)
+	if err != nil || required != "true" {
+		return false, err
+	}
+	enabled, err := twoFactorEnabled(db, user_id)
+	return !enabled, err
+}
+
+func IsStaffRole(role string) bool {
+	for _, staff := range StaffRoles {
+		if role == staff {
+			return true
+		}
+	}
+	return false
+}
+
+func IsRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// Rollerden herhangi biri izni veriyorsa true
+func Can(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range RolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Bütün rollerin izinleri birleşik ve sıralı
+func Permissions(roles []string) []string {
+	seen := make(map[string]bool)
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range RolePermissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// users tablosundaki eski is_admin/is_staff alanlarını rollere çevirip kolonları siler.
+// Staff eskiden hem içerik ekleyip hem yorum silebildiği için editor ve moderator olur.
+func migrateStaffFlags(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn(&User{}, "is_admin") && !migrator.HasColumn(&User{}, "is_staff") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		flags := map[string][]string{
+			"is_admin": {RoleAdmin},
+			"is_staff": {RoleEditor, RoleModerator},
+		}
+		for column, roles := range flags {
+			if !tx.Migrator().HasColumn(&User{}, column) {
+				continue
+			}
+			for _, role := range roles {
+				err := tx.Exec(`INSERT INTO user_roles (user_id, role, created_at)
+					SELECT id, ?, ? FROM users WHERE `+column+` = ?
+					ON CONFLICT DO NOTHING`, role, time.Now(), true).Error
+				if err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().DropColumn(&User{}, column); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
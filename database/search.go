@@ -0,0 +1,434 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"html"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+type SearchResult struct {
+	Kind         string  `json:"kind"` // project veya chapter
+	ID           string  `json:"id"`
+	Slug         string  `json:"slug"`
+	Title        string  `json:"title"`
+	Snippet      string  `json:"snippet"`
+	Rank         float64 `json:"rank"`
+	ProjectSlug  string  `json:"project_slug"`
+	ProjectTitle string  `json:"project_title"`
+}
+
+type SearchRepo interface {
+	Search(ctx context.Context, query string, limit, offset int) ([]SearchResult, error)
+	Reindex(ctx context.Context) error
+}
+
+const (
+	searchModeFTS5     = "fts5"
+	searchModeFTS4     = "fts4"
+	searchModePostgres = "postgres"
+
+	// snippet içindeki vurgular önce bu işaretlerle gelir, escape edildikten sonra <mark> olur
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+
+	// fts4_rank fonksiyonu kayıtlı sqlite sürücüsü
+	sqliteDriverName = "sqlite3_batnovels"
+)
+
+// kind, ref_id, title, body, author, tags
+var fts4Weights = []float64{0, 0, 10.0, 1.0, 5.0, 3.0}
+
+// fts4'te bm25 yok. Skor her bağlantıya kaydedilen fonksiyonla sorgu içinde hesaplanır,
+// böylece ORDER BY ve LIMIT bütün eşleşmeler üzerinde çalışır.
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fts4_rank", func(info []byte) float64 {
+				return fts4Rank(info, fts4Weights)
+			}, true)
+		},
+	})
+}
+
+// search_index her değişiklikte trigger'larla güncellenir,
+// böylece repo'lar dışındaki yazma işlemleri de index'e yansır.
+type SqlSearchRepo struct {
+	db   *gorm.DB
+	mode string
+}
+
+func NewSqlSearchRepo(db *gorm.DB, driver string) (*SqlSearchRepo, error) {
+	repo := &SqlSearchRepo{
+		db: db,
+	}
+	var err error
+	if driver == "postgres" {
+		err = repo.setupPostgres()
+	} else {
+		err = repo.setupSqlite()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+var sqliteSearchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS search_projects_ai AFTER INSERT ON projects WHEN NEW.deleted_at IS NULL BEGIN
+		INSERT INTO search_index(kind, ref_id, title, body, author, tags) VALUES ('project', NEW.id, NEW.title, NEW.synopsis, NEW.author, NEW.tags);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_projects_au AFTER UPDATE ON projects BEGIN
+		DELETE FROM search_index WHERE kind = 'project' AND ref_id = OLD.id;
+		INSERT INTO search_index(kind, ref_id, title, body, author, tags) SELECT 'project', NEW.id, NEW.title, NEW.synopsis, NEW.author, NEW.tags WHERE NEW.deleted_at IS NULL;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_projects_ad AFTER DELETE ON projects BEGIN
+		DELETE FROM search_index WHERE kind = 'project' AND ref_id = OLD.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_chapters_ai AFTER INSERT ON chapters WHEN NEW.deleted_at IS NULL BEGIN
+		INSERT INTO search_index(kind, ref_id, title, body, author, tags) VALUES ('chapter', NEW.id, NEW.title, NEW.content, '', '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_chapters_au AFTER UPDATE ON chapters BEGIN
+		DELETE FROM search_index WHERE kind = 'chapter' AND ref_id = OLD.id;
+		INSERT INTO search_index(kind, ref_id, title, body, author, tags) SELECT 'chapter', NEW.id, NEW.title, NEW.content, '', '' WHERE NEW.deleted_at IS NULL;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS search_chapters_ad AFTER DELETE ON chapters BEGIN
+		DELETE FROM search_index WHERE kind = 'chapter' AND ref_id = OLD.id;
+	END`,
+}
+
+// FTS5 sadece sqlite_fts5 build tag'i ile derlenince var, yoksa FTS4'e düşülür
+func (repo *SqlSearchRepo) setupSqlite() error {
+	var existing string
+	if err := repo.db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'search_index'").Scan(&existing).Error; err != nil {
+		return err
+	}
+	created := false
+	switch {
+	case strings.Contains(strings.ToLower(existing), "fts5"):
+		repo.mode = searchModeFTS5
+	case existing != "":
+		repo.mode = searchModeFTS4
+	default:
+		err := repo.db.Exec("CREATE VIRTUAL TABLE search_index USING fts5(kind UNINDEXED, ref_id UNINDEXED, title, body, author, tags)").Error
+		if err == nil {
+			repo.mode = searchModeFTS5
+		} else {
+			err = repo.db.Exec("CREATE VIRTUAL TABLE search_index USING fts4(kind, ref_id, title, body, author, tags, notindexed=kind, notindexed=ref_id)").Error
+			if err != nil {
+				return err
+			}
+			repo.mode = searchModeFTS4
+		}
+		created = true
+	}
+	for _, trigger := range sqliteSearchTriggers {
+		if err := repo.db.Exec(trigger).Error; err != nil {
+			return err
+		}
+	}
+	if created {
+		return repo.Reindex(context.Background())
+	}
+	return nil
+}
+
+// Snippet ts_headline ile tsvector'e giren metnin aynısından üretilir, bu yüzden headline sütunu
+// document'la aynı alanları içerir
+const postgresSearchSetup = `
+CREATE TABLE IF NOT EXISTS search_documents (
+	kind text NOT NULL,
+	ref_id text NOT NULL,
+	title text NOT NULL DEFAULT '',
+	headline text NOT NULL DEFAULT '',
+	document tsvector NOT NULL,
+	PRIMARY KEY (kind, ref_id)
+);
+CREATE INDEX IF NOT EXISTS idx_search_documents_document ON search_documents USING GIN (document);
+
+CREATE OR REPLACE FUNCTION search_projects_sync() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP <> 'INSERT' THEN
+		DELETE FROM search_documents WHERE kind = 'project' AND ref_id = OLD.id::text;
+	END IF;
+	IF TG_OP <> 'DELETE' AND NEW.deleted_at IS NULL THEN
+		INSERT INTO search_documents(kind, ref_id, title, headline, document) VALUES ('project', NEW.id::text, NEW.title,
+			concat_ws(' ', NEW.title, NEW.author, NEW.tags, NEW.synopsis),
+			setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(NEW.author, '') || ' ' || coalesce(NEW.tags, '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(NEW.synopsis, '')), 'C'));
+	END IF;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION search_chapters_sync() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP <> 'INSERT' THEN
+		DELETE FROM search_documents WHERE kind = 'chapter' AND ref_id = OLD.id::text;
+	END IF;
+	IF TG_OP <> 'DELETE' AND NEW.deleted_at IS NULL THEN
+		INSERT INTO search_documents(kind, ref_id, title, headline, document) VALUES ('chapter', NEW.id::text, NEW.title,
+			concat_ws(' ', NEW.title, NEW.content),
+			setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce(NEW.content, '')), 'D'));
+	END IF;
+	RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS search_projects_sync ON projects;
+CREATE TRIGGER search_projects_sync AFTER INSERT OR UPDATE OR DELETE ON projects FOR EACH ROW EXECUTE FUNCTION search_projects_sync();
+DROP TRIGGER IF EXISTS search_chapters_sync ON chapters;
+CREATE TRIGGER search_chapters_sync AFTER INSERT OR UPDATE OR DELETE ON chapters FOR EACH ROW EXECUTE FUNCTION search_chapters_sync();
+`
+
+func (repo *SqlSearchRepo) setupPostgres() error {
+	repo.mode = searchModePostgres
+	var exists bool
+	if err := repo.db.Raw("SELECT to_regclass('search_documents') IS NOT NULL").Scan(&exists).Error; err != nil {
+		return err
+	}
+	if err := repo.db.Exec(postgresSearchSetup).Error; err != nil {
+		return err
+	}
+	if !exists {
+		return repo.Reindex(context.Background())
+	}
+	return nil
+}
+
+// Index'i sıfırdan kurar. Trigger'lar normalde yeterli, bu eski veriler ve bozulmalar için.
+func (repo SqlSearchRepo) Reindex(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		var statements []string
+		if repo.mode == searchModePostgres {
+			statements = []string{
+				"DELETE FROM search_documents",
+				// trigger'ları tetiklemek için satırları kendi değerleriyle günceller
+				"UPDATE projects SET id = id WHERE deleted_at IS NULL",
+				"UPDATE chapters SET id = id WHERE deleted_at IS NULL",
+			}
+		} else {
+			statements = []string{
+				"DELETE FROM search_index",
+				"INSERT INTO search_index(kind, ref_id, title, body, author, tags) SELECT 'project', id, title, synopsis, author, tags FROM projects WHERE deleted_at IS NULL",
+				"INSERT INTO search_index(kind, ref_id, title, body, author, tags) SELECT 'chapter', id, title, content, '', '' FROM chapters WHERE deleted_at IS NULL",
+			}
+		}
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// Yayınlanmamış bölümler LIMIT'ten önce elenir, yoksa sayfa kısa kalır. Postgres'te ref_id text, chapters.id uuid.
+func (repo SqlSearchRepo) released() string {
+	id := "id"
+	if repo.mode == searchModePostgres {
+		id = "id::text"
+	}
+	return "(kind = 'project' OR ref_id IN (SELECT " + id + " FROM chapters WHERE deleted_at IS NULL AND " + releasedChapter + "))"
+}
+
+type searchHit struct {
+	Kind    string
+	RefID   string
+	Rank    float64
+	Snippet string
+}
+
+func (repo SqlSearchRepo) Search(ctx context.Context, query string, limit, offset int) ([]SearchResult, error) {
+	select {
+	case <-ctx.Done():
+		return []SearchResult{}, ErrorOperationCanceled
+	default:
+		terms := searchTerms(query)
+		if len(terms) == 0 {
+			return []SearchResult{}, nil
+		}
+		var hits []searchHit
+		var err error
+		switch repo.mode {
+		case searchModePostgres:
+			hits, err = repo.searchPostgres(terms, limit, offset)
+		case searchModeFTS5:
+			hits, err = repo.searchFTS5(terms, limit, offset)
+		default:
+			hits, err = repo.searchFTS4(terms, limit, offset)
+		}
+		if err != nil {
+			return []SearchResult{}, err
+		}
+		return repo.hydrate(hits)
+	}
+}
+
+func (repo SqlSearchRepo) searchFTS5(terms []string, limit, offset int) ([]searchHit, error) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	var hits []searchHit
+	result := repo.db.Raw(`SELECT kind, ref_id, bm25(search_index, 0, 0, 10.0, 1.0, 5.0, 3.0) AS rank,
+			snippet(search_index, -1, ?, ?, '…', 24) AS snippet
+		FROM search_index WHERE search_index MATCH ? AND `+repo.released()+` ORDER BY rank LIMIT ? OFFSET ?`,
+		highlightStart, highlightEnd, strings.Join(parts, " "), ChapterPublished, time.Now(), limit, offset).
+		Scan(&hits)
+	// bm25 küçük oldukça daha alakalı, dışarıya büyük olan daha iyi diye verilir
+	for i := range hits {
+		hits[i].Rank = -hits[i].Rank
+	}
+	return hits, result.Error
+}
+
+// fts4'te bm25 yok, matchinfo('pcnx') ile fts4Rank'teki basit tf-idf skoru kullanılır
+func (repo SqlSearchRepo) searchFTS4(terms []string, limit, offset int) ([]searchHit, error) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + "*"
+	}
+	var hits []searchHit
+	result := repo.db.Raw(`SELECT kind, ref_id, fts4_rank(matchinfo(search_index, 'pcnx')) AS rank,
+			snippet(search_index, ?, ?, '…', -1, 24) AS snippet
+		FROM search_index WHERE search_index MATCH ? AND `+repo.released()+` ORDER BY rank DESC LIMIT ? OFFSET ?`,
+		highlightStart, highlightEnd, strings.Join(parts, " "), ChapterPublished, time.Now(), limit, offset).
+		Scan(&hits)
+	return hits, result.Error
+}
+
+func fts4Rank(info []byte, weights []float64) float64 {
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 3 {
+		return 0
+	}
+	phrases, columns, rows := int(values[0]), int(values[1]), float64(values[2])
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns && c < len(weights); c++ {
+			index := 3 + 3*(p*columns+c)
+			if index+2 >= len(values) {
+				return score
+			}
+			hits, docs := float64(values[index]), float64(values[index+2])
+			if hits == 0 {
+				continue
+			}
+			score += weights[c] * hits * math.Log(1+rows/(1+docs))
+		}
+	}
+	return score
+}
+
+func (repo SqlSearchRepo) searchPostgres(terms []string, limit, offset int) ([]searchHit, error) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	var hits []searchHit
+	result := repo.db.Raw(`SELECT kind, ref_id, ts_rank(document, q) AS rank,
+			ts_headline('simple', headline, q, ?) AS snippet
+		FROM search_documents, to_tsquery('simple', ?) q
+		WHERE document @@ q AND `+repo.released()+` ORDER BY rank DESC LIMIT ? OFFSET ?`,
+		"StartSel="+highlightStart+", StopSel="+highlightEnd+", MaxWords=35, MinWords=15",
+		strings.Join(parts, " & "), ChapterPublished, time.Now(), limit, offset).
+		Scan(&hits)
+	return hits, result.Error
+}
+
+// Index sadece id tutar, slug ve başlıklar tablolardan çekilir
+func (repo SqlSearchRepo) hydrate(hits []searchHit) ([]SearchResult, error) {
+	var projectIDs, chapterIDs []string
+	for _, hit := range hits {
+		if hit.Kind == "project" {
+			projectIDs = append(projectIDs, hit.RefID)
+		} else {
+			chapterIDs = append(chapterIDs, hit.RefID)
+		}
+	}
+	projects := make(map[string]Project)
+	chapters := make(map[string]Chapter)
+	if len(projectIDs) > 0 {
+		var rows []Project
+		if err := repo.db.Where("id IN ?", projectIDs).Find(&rows).Error; err != nil {
+			return []SearchResult{}, err
+		}
+		for _, row := range rows {
+			projects[row.ID] = row
+		}
+	}
+	if len(chapterIDs) > 0 {
+		var rows []Chapter
+		result := repo.db.Preload("Project").
+			Where("id IN ?", chapterIDs).
+			Scopes(releasedChapters(time.Now())).
+			Find(&rows)
+		if err := result.Error; err != nil {
+			return []SearchResult{}, err
+		}
+		for _, row := range rows {
+			chapters[row.ID] = row
+		}
+	}
+	results := []SearchResult{}
+	for _, hit := range hits {
+		result := SearchResult{
+			Kind:    hit.Kind,
+			ID:      hit.RefID,
+			Rank:    hit.Rank,
+			Snippet: highlightSnippet(hit.Snippet),
+		}
+		if hit.Kind == "project" {
+			project, ok := projects[hit.RefID]
+			if !ok {
+				continue
+			}
+			result.Slug = project.Slug
+			result.Title = project.Title
+			result.ProjectSlug = project.Slug
+			result.ProjectTitle = project.Title
+		} else {
+			chapter, ok := chapters[hit.RefID]
+			if !ok {
+				continue
+			}
+			result.Slug = chapter.Slug
+			result.Title = chapter.Title
+			result.ProjectSlug = chapter.Project.Slug
+			result.ProjectTitle = chapter.Project.Title
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, highlightStart, "<mark>")
+	return strings.ReplaceAll(snippet, highlightEnd, "</mark>")
+}
+
+// Kullanıcı sorgusu harf ve rakamlara bölünür, böylece fts söz dizimi hataları oluşmaz
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
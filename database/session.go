@@ -0,0 +1,267 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Her girişte açılan oturum. Refresh token'ın kendisi değil sha256 özeti tutulur,
+// her yenilemede token değişir. Oturum bir token ailesidir, eski token'ların özetleri RetiredToken'da kalır.
+type Session struct {
+	ID              string     `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	UserID          string     `gorm:"not null;index;" json:"user_id"`
+	TokenHash       string     `gorm:"not null;size:64;uniqueIndex;" json:"-"`
+	AccessJTI       string     `gorm:"not null;size:64;" json:"-"`
+	AccessExpiresAt time.Time  `json:"-"`
+	ExpiresAt       time.Time  `gorm:"not null;index;" json:"expires_at"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	UserAgent       string     `gorm:"size:256;" json:"user_agent"`
+	IP              string     `gorm:"size:64;" json:"ip"`
+}
+
+// Oturumda yenilenip yerine yenisi verilmiş refresh token'lar. Hangi nesilden olursa olsun
+// biri tekrar gelirse token çalınmış sayılır ve bütün aile, yani oturum kapatılır.
+// Oturumla birlikte silinir.
+type RetiredToken struct {
+	TokenHash string `gorm:"primaryKey;size:64;"`
+	SessionID string `gorm:"type:uuid;not null;index;"`
+	CreatedAt time.Time
+}
+
+// İptal edilmiş access token'lar, token'ın süresi dolana kadar tutulur
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64;"`
+	ExpiresAt time.Time `gorm:"not null;index;"`
+}
+
+type SessionRepo interface {
+	Find(ctx context.Context, user_id string, id string) (Session, error)
+	Create(ctx context.Context, session Session) (Session, error)
+	Rotate(ctx context.Context, token_hash string, next Session) (Session, error)
+	Revoke(ctx context.Context, user_id string, id string) error
+	RevokeAll(ctx context.Context, user_id string) error
+	RevokeToken(ctx context.Context, jti string, expires_at time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	Purge(ctx context.Context, now time.Time) (int64, error)
+}
+
+type SqlSessionRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlSessionRepo(db *gorm.DB) *SqlSessionRepo {
+	return &SqlSessionRepo{
+		db: db,
+	}
+}
+
+func (repo SqlSessionRepo) Find(ctx context.Context, user_id string, id string) (Session, error) {
+	select {
+	case <-ctx.Done():
+		return Session{}, ErrorOperationCanceled
+	default:
+		var session Session
+		result := repo.db.First(&session, "id = ? AND user_id = ?", id, user_id)
+		return session, result.Error
+	}
+}
+
+func (repo SqlSessionRepo) Create(ctx context.Context, session Session) (Session, error) {
+	select {
+	case <-ctx.Done():
+		return Session{}, ErrorOperationCanceled
+	default:
+		if !session.IsValid() {
+			return Session{}, ErrorInvalidSession
+		}
+		session.ID = uuid.New().String()
+		session.RevokedAt = nil
+		result := repo.db.Create(&session)
+		return session, result.Error
+	}
+}
+
+// token_hash ile bulunan oturumun token'ını next'tekilerle değiştirir.
+// Oturumda daha önce değiştirilmiş herhangi bir token gelirse çalınmış sayılır ve oturum kapatılır.
+func (repo SqlSessionRepo) Rotate(ctx context.Context, token_hash string, next Session) (Session, error) {
+	select {
+	case <-ctx.Done():
+		return Session{}, ErrorOperationCanceled
+	default:
+		// next'in sadece token alanları kullanılır
+		if len(next.TokenHash) != 64 || next.AccessJTI == "" || !next.ExpiresAt.After(time.Now()) {
+			return Session{}, ErrorInvalidSession
+		}
+		var session Session
+		reused := false
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("token_hash = ?", token_hash).Limit(1).Find(&session)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// Oturumun kapatılması commit edilsin diye hata dönülmez
+				var family []Session
+				result = tx.Where("revoked_at IS NULL AND id IN (?)",
+					tx.Model(&RetiredToken{}).Select("session_id").Where("token_hash = ?", token_hash)).
+					Find(&family)
+				if result.Error != nil {
+					return result.Error
+				}
+				reused = len(family) > 0
+				return revokeSessions(tx, family)
+			}
+			if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+				return ErrorInvalidSession
+			}
+			// Aynı token'la eşzamanlı gelen ikinci istek burada boşa düşer
+			result = tx.Model(&Session{}).
+				Where("id = ? AND token_hash = ?", session.ID, token_hash).
+				Updates(map[string]interface{}{
+					"token_hash":        next.TokenHash,
+					"access_jti":        next.AccessJTI,
+					"access_expires_at": next.AccessExpiresAt,
+					"expires_at":        next.ExpiresAt,
+					"updated_at":        time.Now(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrorInvalidSession
+			}
+			if err := tx.Create(&RetiredToken{TokenHash: token_hash, SessionID: session.ID}).Error; err != nil {
+				return err
+			}
+			return tx.First(&session, "id = ?", session.ID).Error
+		})
+		if err != nil {
+			return Session{}, err
+		}
+		if reused {
+			return Session{}, ErrorSessionReused
+		}
+		if session.ID == "" {
+			return Session{}, ErrorInvalidSession
+		}
+		return session, nil
+	}
+}
+
+func (repo SqlSessionRepo) Revoke(ctx context.Context, user_id string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			var sessions []Session
+			result := tx.Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user_id).Find(&sessions)
+			if result.Error != nil {
+				return result.Error
+			}
+			return revokeSessions(tx, sessions)
+		})
+	}
+}
+
+func (repo SqlSessionRepo) RevokeAll(ctx context.Context, user_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			var sessions []Session
+			result := tx.Where("user_id = ? AND revoked_at IS NULL", user_id).Find(&sessions)
+			if result.Error != nil {
+				return result.Error
+			}
+			return revokeSessions(tx, sessions)
+		})
+	}
+}
+
+func (repo SqlSessionRepo) RevokeToken(ctx context.Context, jti string, expires_at time.Time) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		if jti == "" || !expires_at.After(time.Now()) {
+			return nil
+		}
+		result := repo.db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&RevokedToken{JTI: jti, ExpiresAt: expires_at})
+		return result.Error
+	}
+}
+
+func (repo SqlSessionRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count)
+		return count > 0, result.Error
+	}
+}
+
+// Süresi dolan oturumları ve artık geçersiz olan iptal kayıtlarını siler
+func (repo SqlSessionRepo) Purge(ctx context.Context, now time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var purged int64
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Where("expires_at < ?", now).Delete(&RevokedToken{})
+			if result.Error != nil {
+				return result.Error
+			}
+			purged += result.RowsAffected
+			result = tx.Where("expires_at < ?", now).Delete(&Session{})
+			if result.Error != nil {
+				return result.Error
+			}
+			purged += result.RowsAffected
+			return tx.Where("session_id NOT IN (?)", tx.Model(&Session{}).Select("id")).Delete(&RetiredToken{}).Error
+		})
+		return purged, err
+	}
+}
+
+// Oturumları kapatır ve son verilen access token'larını iptal listesine ekler
+func revokeSessions(tx *gorm.DB, sessions []Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	now := time.Now()
+	ids := make([]string, 0, len(sessions))
+	var revoked []RevokedToken
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+		if session.AccessJTI != "" && session.AccessExpiresAt.After(now) {
+			revoked = append(revoked, RevokedToken{JTI: session.AccessJTI, ExpiresAt: session.AccessExpiresAt})
+		}
+	}
+	result := tx.Model(&Session{}).Where("id IN ?", ids).Update("revoked_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if len(revoked) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
+
+func (s Session) IsValid() bool {
+	if s.UserID == "" || len(s.TokenHash) != 64 || s.AccessJTI == "" {
+		return false
+	}
+	return s.ExpiresAt.After(time.Now()) && s.AccessExpiresAt.After(time.Now())
+}
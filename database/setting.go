@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Admin panelinden değiştirilen site ayarları
+const (
+	// "true" ise admin, editor ve moderator rolleri iki adımlı doğrulama açılana kadar yok sayılır
+	SettingRequireStaffTwoFactor = "security.require_staff_2fa"
+)
+
+type Setting struct {
+	Key       string `gorm:"primaryKey;size:64;"`
+	Value     string `gorm:"not null;size:256;"`
+	UpdatedAt time.Time
+}
+
+type SettingRepo interface {
+	// Ayar hiç kaydedilmemişse boş string döner
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value string) error
+}
+
+type SqlSettingRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlSettingRepo(db *gorm.DB) *SqlSettingRepo {
+	return &SqlSettingRepo{
+		db: db,
+	}
+}
+
+func (repo SqlSettingRepo) Get(ctx context.Context, key string) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ErrorOperationCanceled
+	default:
+		return setting(repo.db, key)
+	}
+}
+
+func (repo SqlSettingRepo) Set(ctx context.Context, key string, value string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&Setting{Key: key, Value: value})
+		return result.Error
+	}
+}
+
+func setting(db *gorm.DB, key string) (string, error) {
+	var values []string
+	result := db.Model(&Setting{}).Where("key = ?", key).Limit(1).Pluck("value", &values)
+	if result.Error != nil || len(values) == 0 {
+		return "", result.Error
+	}
+	return values[0], nil
+}
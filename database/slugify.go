@@ -1,10 +1,21 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 	"unicode"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// Aynı anda aynı başlıkla eklenen kayıtlar aynı slug'ı bulabilir, unique index'e takılan tekrar dener
+const slugRetries = 3
+
 func Slugify(str string) string {
 	var slugBuilder strings.Builder
 
@@ -20,3 +31,90 @@ func Slugify(str string) string {
 
 	return slug
 }
+
+// Başlığı değişen proje ve bölümlerin eski slug'ları, eski linkler yeni adrese yönlendirilsin diye tutulur
+type SlugRedirect struct {
+	Kind      string `gorm:"primaryKey;size:16;"` // tablo adı, projects ya da chapters
+	Slug      string `gorm:"primaryKey;size:128;"`
+	TargetID  string `gorm:"type:uuid;not null;index;"`
+	CreatedAt time.Time
+}
+
+// Slug unique olduğu için çakışma varsa sonuna -2, -3 ... ekler.
+// Silinmiş satırlar da unique index'te olduğundan onlara da bakılır.
+// Başka bir kayda yönlendiren eski slug'lar da dolu sayılır, except_id'nin kendi eski slug'ları hariç.
+func uniqueSlug(db *gorm.DB, table string, title string, except_id string) (string, error) {
+	base := Slugify(title)
+	pattern := escapeLike(base) + "-%"
+	slugs := db.Table(table).Where(`slug = ? OR slug LIKE ? ESCAPE '\'`, base, pattern)
+	redirects := db.Model(&SlugRedirect{}).Where("kind = ?", table).Where(`slug = ? OR slug LIKE ? ESCAPE '\'`, base, pattern)
+	if except_id != "" {
+		slugs = slugs.Where("id <> ?", except_id)
+		redirects = redirects.Where("target_id <> ?", except_id)
+	}
+	var taken, redirected []string
+	if err := slugs.Pluck("slug", &taken).Error; err != nil {
+		return "", err
+	}
+	if err := redirects.Pluck("slug", &redirected).Error; err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken)+len(redirected))
+	for _, slug := range append(taken, redirected...) {
+		used[slug] = true
+	}
+	slug := base
+	for i := 2; used[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	return slug, nil
+}
+
+// fn slug'ı uniqueSlug ile alıp kaydı yazar. Başka bir istek arada aynı slug'ı aldıysa fn baştan çalışır.
+// Her deneme kendi transaction'ında, dış transaction varsa savepoint'te çalışır, postgres'te hata dış transaction'ı bozmaz.
+func withUniqueSlug(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	var err error
+	for attempt := 0; attempt < slugRetries; attempt++ {
+		err = db.Transaction(fn)
+		if !isUniqueViolation(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	return false
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Başlık slug'ı değiştirecek kadar değiştiyse yeni slug verir, eskisini yönlendirme olarak saklar.
+// Kayıt eski slug'larından birine geri dönerse o yönlendirme silinir.
+func renameSlug(tx *gorm.DB, table string, id string, old_slug string, old_title string, title string) (string, error) {
+	if Slugify(title) == Slugify(old_title) {
+		return old_slug, nil
+	}
+	slug, err := uniqueSlug(tx, table, title, id)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Delete(&SlugRedirect{}, "kind = ? AND slug = ?", table, slug).Error; err != nil {
+		return "", err
+	}
+	redirect := SlugRedirect{Kind: table, Slug: old_slug, TargetID: id}
+	if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&redirect).Error; err != nil {
+		return "", err
+	}
+	return slug, nil
+}
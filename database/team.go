@@ -0,0 +1,411 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Proje ekibindeki roller
+const (
+	TeamOwner       = "owner"
+	TeamEditor      = "editor"
+	TeamTranslator  = "translator"
+	TeamProofreader = "proofreader"
+)
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// Ekip rollerinin o projedeki izinleri. Site rollerinden sadece admin ve moderasyon izinleri bütün projelerde geçerli.
+var TeamPermissions = map[string][]string{
+	TeamOwner: {
+		PermissionProjectEdit, PermissionProjectDelete, PermissionTeamManage,
+		PermissionChapterCreate, PermissionChapterEdit, PermissionChapterPublish, PermissionChapterDelete,
+	},
+	TeamEditor: {
+		PermissionProjectEdit,
+		PermissionChapterCreate, PermissionChapterEdit, PermissionChapterPublish, PermissionChapterDelete,
+	},
+	TeamTranslator: {
+		PermissionChapterCreate, PermissionChapterEdit,
+	},
+	TeamProofreader: {
+		PermissionChapterEdit,
+	},
+}
+
+type ProjectMember struct {
+	ProjectID string    `gorm:"primaryKey;" json:"-"`
+	UserID    string    `gorm:"primaryKey;index;" json:"-"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Role      string    `gorm:"not null;size:32;" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Bir kullanıcının bir projeye tek bir daveti olur, tekrar davet edilince aynı satır güncellenir
+type ProjectInvitation struct {
+	ID          string     `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ProjectID   string     `gorm:"not null;uniqueIndex:idx_project_invitation;" json:"-"`
+	Project     Project    `gorm:"foreignKey:ProjectID" json:"project"`
+	UserID      string     `gorm:"not null;uniqueIndex:idx_project_invitation;index;" json:"-"`
+	User        User       `gorm:"foreignKey:UserID" json:"user"`
+	InvitedByID string     `gorm:"not null;" json:"-"`
+	InvitedBy   User       `gorm:"foreignKey:InvitedByID" json:"invited_by"`
+	Role        string     `gorm:"not null;size:32;" json:"role"`
+	Status      string     `gorm:"not null;size:16;index;" json:"status"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
+
+type TeamRepo interface {
+	Members(ctx context.Context, project_id string) ([]ProjectMember, error)
+	Member(ctx context.Context, project_id string, user_id string) (ProjectMember, error)
+	AddMember(ctx context.Context, member ProjectMember) (ProjectMember, error)
+	SetRole(ctx context.Context, project_id string, user_id string, role string) (ProjectMember, error)
+	RemoveMember(ctx context.Context, project_id string, user_id string) error
+	HasPermission(ctx context.Context, project_id string, user_id string, permission string) (bool, error)
+	Invite(ctx context.Context, invitation ProjectInvitation) (ProjectInvitation, error)
+	Invitation(ctx context.Context, id string) (ProjectInvitation, error)
+	Invitations(ctx context.Context, project_id string) ([]ProjectInvitation, error)
+	UserInvitations(ctx context.Context, user_id string) ([]ProjectInvitation, error)
+	Respond(ctx context.Context, user_id string, id string, accept bool) (ProjectInvitation, error)
+	CancelInvitation(ctx context.Context, project_id string, id string) error
+}
+
+type SqlTeamRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlTeamRepo(db *gorm.DB) *SqlTeamRepo {
+	return &SqlTeamRepo{
+		db: db,
+	}
+}
+
+// Sahipler önce, sonra katılma sırasıyla
+func (repo SqlTeamRepo) Members(ctx context.Context, project_id string) ([]ProjectMember, error) {
+	select {
+	case <-ctx.Done():
+		return []ProjectMember{}, ErrorOperationCanceled
+	default:
+		var members []ProjectMember
+		result := repo.db.Preload("User").
+			Where("project_id = ?", project_id).
+			Clauses(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "CASE WHEN role = ? THEN 0 ELSE 1 END, created_at ASC",
+				Vars: []interface{}{TeamOwner},
+			}}).
+			Find(&members)
+		return members, result.Error
+	}
+}
+
+func (repo SqlTeamRepo) Member(ctx context.Context, project_id string, user_id string) (ProjectMember, error) {
+	select {
+	case <-ctx.Done():
+		return ProjectMember{}, ErrorOperationCanceled
+	default:
+		var member ProjectMember
+		result := repo.db.Preload("User").First(&member, "project_id = ? AND user_id = ?", project_id, user_id)
+		return member, result.Error
+	}
+}
+
+// Zaten ekipteyse rolü güncellenir
+func (repo SqlTeamRepo) AddMember(ctx context.Context, member ProjectMember) (ProjectMember, error) {
+	select {
+	case <-ctx.Done():
+		return ProjectMember{}, ErrorOperationCanceled
+	default:
+		if !member.IsValid() {
+			return ProjectMember{}, ErrorInvalidMember
+		}
+		if err := addMember(repo.db, member); err != nil {
+			return ProjectMember{}, err
+		}
+		return repo.Member(ctx, member.ProjectID, member.UserID)
+	}
+}
+
+func (repo SqlTeamRepo) SetRole(ctx context.Context, project_id string, user_id string, role string) (ProjectMember, error) {
+	select {
+	case <-ctx.Done():
+		return ProjectMember{}, ErrorOperationCanceled
+	default:
+		if !IsTeamRole(role) {
+			return ProjectMember{}, ErrorInvalidMember
+		}
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			var member ProjectMember
+			if err := tx.First(&member, "project_id = ? AND user_id = ?", project_id, user_id).Error; err != nil {
+				return err
+			}
+			if member.Role == TeamOwner && role != TeamOwner {
+				if err := keepOwner(tx, project_id, user_id); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&ProjectMember{}).
+				Where("project_id = ? AND user_id = ?", project_id, user_id).
+				Update("role", role).Error
+		})
+		if err != nil {
+			return ProjectMember{}, err
+		}
+		return repo.Member(ctx, project_id, user_id)
+	}
+}
+
+// Son sahip ekipten çıkamaz, önce başka birini sahip yapmalı
+func (repo SqlTeamRepo) RemoveMember(ctx context.Context, project_id string, user_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			var member ProjectMember
+			if err := tx.First(&member, "project_id = ? AND user_id = ?", project_id, user_id).Error; err != nil {
+				return err
+			}
+			if member.Role == TeamOwner {
+				if err := keepOwner(tx, project_id, user_id); err != nil {
+					return err
+				}
+			}
+			return tx.Where("project_id = ? AND user_id = ?", project_id, user_id).Delete(&ProjectMember{}).Error
+		})
+	}
+}
+
+// Site rolü ya da projedeki ekip rolü izni veriyorsa true
+func (repo SqlTeamRepo) HasPermission(ctx context.Context, project_id string, user_id string, permission string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ErrorOperationCanceled
+	default:
+		roles, err := effectiveRoles(repo.db, user_id)
+		if err != nil {
+			return false, err
+		}
+		// Editor gibi site rolleri başkasının projesinde yetki vermez, proje ve bölüm izinleri ekipten gelir
+		for _, role := range roles {
+			if role == RoleAdmin {
+				return true, nil
+			}
+		}
+		if isModeration(permission) && Can(roles, permission) {
+			return true, nil
+		}
+		var team_roles []string
+		result := repo.db.Model(&ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", project_id, user_id).
+			Pluck("role", &team_roles)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		for _, role := range team_roles {
+			for _, granted := range TeamPermissions[role] {
+				if granted == permission {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+}
+
+// Bekleyen ya da yanıtlanmış eski davet varsa yeniden bekleyen hale getirilir
+func (repo SqlTeamRepo) Invite(ctx context.Context, invitation ProjectInvitation) (ProjectInvitation, error) {
+	select {
+	case <-ctx.Done():
+		return ProjectInvitation{}, ErrorOperationCanceled
+	default:
+		if !invitation.IsValid() {
+			return ProjectInvitation{}, ErrorInvalidInvitation
+		}
+		var members int64
+		result := repo.db.Model(&ProjectMember{}).
+			Where("project_id = ? AND user_id = ?", invitation.ProjectID, invitation.UserID).
+			Count(&members)
+		if result.Error != nil {
+			return ProjectInvitation{}, result.Error
+		}
+		if members > 0 {
+			return ProjectInvitation{}, ErrorAlreadyMember
+		}
+		invitation.ID = uuid.New().String()
+		invitation.Status = InvitationPending
+		invitation.RespondedAt = nil
+		result = repo.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role", "status", "invited_by_id", "responded_at", "updated_at"}),
+		}).Create(&invitation)
+		if result.Error != nil {
+			return ProjectInvitation{}, result.Error
+		}
+		var saved ProjectInvitation
+		result = repo.db.Preload(clause.Associations).
+			First(&saved, "project_id = ? AND user_id = ?", invitation.ProjectID, invitation.UserID)
+		return saved, result.Error
+	}
+}
+
+func (repo SqlTeamRepo) Invitation(ctx context.Context, id string) (ProjectInvitation, error) {
+	select {
+	case <-ctx.Done():
+		return ProjectInvitation{}, ErrorOperationCanceled
+	default:
+		var invitation ProjectInvitation
+		result := repo.db.Preload(clause.Associations).First(&invitation, "id = ?", id)
+		return invitation, result.Error
+	}
+}
+
+func (repo SqlTeamRepo) Invitations(ctx context.Context, project_id string) ([]ProjectInvitation, error) {
+	select {
+	case <-ctx.Done():
+		return []ProjectInvitation{}, ErrorOperationCanceled
+	default:
+		var invitations []ProjectInvitation
+		result := repo.db.Preload(clause.Associations).
+			Where("project_id = ? AND status = ?", project_id, InvitationPending).
+			Order("created_at desc").
+			Find(&invitations)
+		return invitations, result.Error
+	}
+}
+
+func (repo SqlTeamRepo) UserInvitations(ctx context.Context, user_id string) ([]ProjectInvitation, error) {
+	select {
+	case <-ctx.Done():
+		return []ProjectInvitation{}, ErrorOperationCanceled
+	default:
+		var invitations []ProjectInvitation
+		result := repo.db.Preload(clause.Associations).
+			Joins("JOIN projects ON projects.id = project_invitations.project_id AND projects.deleted_at IS NULL").
+			Where("project_invitations.user_id = ? AND project_invitations.status = ?", user_id, InvitationPending).
+			Order("project_invitations.created_at desc").
+			Find(&invitations)
+		return invitations, result.Error
+	}
+}
+
+// Sadece davet edilen kullanıcı yanıtlayabilir, kabul edilince ekibe davetteki rolle eklenir
+func (repo SqlTeamRepo) Respond(ctx context.Context, user_id string, id string, accept bool) (ProjectInvitation, error) {
+	select {
+	case <-ctx.Done():
+		return ProjectInvitation{}, ErrorOperationCanceled
+	default:
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			var invitation ProjectInvitation
+			result := tx.First(&invitation, "id = ? AND user_id = ? AND status = ?", id, user_id, InvitationPending)
+			if result.Error != nil {
+				return result.Error
+			}
+			status := InvitationDeclined
+			if accept {
+				status = InvitationAccepted
+				if err := addMember(tx, ProjectMember{ProjectID: invitation.ProjectID, UserID: user_id, Role: invitation.Role}); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&invitation).Updates(map[string]interface{}{
+				"status":       status,
+				"responded_at": time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return ProjectInvitation{}, err
+		}
+		return repo.Invitation(ctx, id)
+	}
+}
+
+func (repo SqlTeamRepo) CancelInvitation(ctx context.Context, project_id string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Where("id = ? AND project_id = ? AND status = ?", id, project_id, InvitationPending).
+			Delete(&ProjectInvitation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+}
+
+func addMember(tx *gorm.DB, member ProjectMember) error {
+	return tx.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role"}),
+	}).Create(&member).Error
+}
+
+// user_id dışında başka bir sahip yoksa ErrorLastOwner
+func keepOwner(tx *gorm.DB, project_id string, user_id string) error {
+	var owners int64
+	result := tx.Model(&ProjectMember{}).
+		Where("project_id = ? AND role = ? AND user_id <> ?", project_id, TeamOwner, user_id).
+		Count(&owners)
+	if result.Error != nil {
+		return result.Error
+	}
+	if owners == 0 {
+		return ErrorLastOwner
+	}
+	return nil
+}
+
+func IsTeamRole(role string) bool {
+	_, ok := TeamPermissions[role]
+	return ok
+}
+
+func (m ProjectMember) IsValid() bool {
+	return m.ProjectID != "" && m.UserID != "" && IsTeamRole(m.Role)
+}
+
+func (i ProjectInvitation) IsValid() bool {
+	return i.ProjectID != "" && i.UserID != "" && i.InvitedByID != "" && i.UserID != i.InvitedByID && IsTeamRole(i.Role)
+}
+
+func isModeration(permission string) bool {
+	return permission == PermissionCommentModerate || permission == PermissionReviewModerate
+}
+
+// Göçün yapıldığı zaman settings tablosunda bu anahtarla tutulur
+const migrationProjectOwners = "migration.project_owners"
+
+// Ekipler eklenmeden önce açılmış projelerin sahibi yoktu. Author alanı bir kullanıcı adına uyuyorsa o kullanıcı
+// sahip yapılır, uymayanları sadece adminler düzenleyebilir. Sonradan o adla kayıt olan biri sahip olmasın diye
+// bir kere çalışır.
+func migrateProjectOwners(db *gorm.DB) error {
+	done, err := setting(db, migrationProjectOwners)
+	if err != nil || done != "" {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Exec(`INSERT INTO project_members (project_id, user_id, role, created_at)
+			SELECT projects.id, users.id, ?, ? FROM projects
+			JOIN users ON LOWER(users.username) = LOWER(TRIM(projects.author)) AND users.deleted_at IS NULL
+			WHERE NOT EXISTS (SELECT 1 FROM project_members WHERE project_members.project_id = projects.id AND project_members.role = ?)
+			ON CONFLICT DO NOTHING`, TeamOwner, now, TeamOwner).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&Setting{Key: migrationProjectOwners, Value: now.UTC().Format(time.RFC3339)}).Error
+	})
+}
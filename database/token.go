@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	// Şifresi doğru girilmiş ama iki adımlı doğrulama kodu beklenen giriş
+	TokenTwoFactor = "two_factor"
+)
+
+// E-posta doğrulama ve şifre sıfırlama linklerindeki ve iki adımlı girişteki token'lar. Kullanıcıya imzalı hali gider,
+// burada sadece id'si tutulur. Tek kullanımlık olması UsedAt ile sağlanır.
+type UserToken struct {
+	ID        string    `gorm:"primaryKey;size:64;"`
+	CreatedAt time.Time `gorm:"index;"`
+	UserID    string    `gorm:"not null;index;"`
+	Purpose   string    `gorm:"not null;size:32;"`
+	ExpiresAt time.Time `gorm:"not null;index;"`
+	UsedAt    *time.Time
+	// Yanlış girilen kod sayısı, iki adımlı girişte sınırlanır
+	Attempts int `gorm:"not null;default:0;"`
+}
+
+type UserTokenRepo interface {
+	Create(ctx context.Context, token UserToken) (UserToken, error)
+	Find(ctx context.Context, purpose string, id string) (UserToken, error)
+	Use(ctx context.Context, token UserToken) error
+	Fail(ctx context.Context, token UserToken) (int, error)
+	CountSince(ctx context.Context, user_id string, purpose string, since time.Time) (int64, error)
+	Purge(ctx context.Context, now time.Time) (int64, error)
+}
+
+type SqlUserTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlUserTokenRepo(db *gorm.DB) *SqlUserTokenRepo {
+	return &SqlUserTokenRepo{
+		db: db,
+	}
+}
+
+func (repo SqlUserTokenRepo) Create(ctx context.Context, token UserToken) (UserToken, error) {
+	select {
+	case <-ctx.Done():
+		return token, ErrorOperationCanceled
+	default:
+		if !token.IsValid() {
+			return token, ErrorInvalidToken
+		}
+		result := repo.db.Create(&token)
+		return token, result.Error
+	}
+}
+
+// Kullanılmış, süresi dolmuş ya da başka amaçla verilmiş token'lar için ErrorInvalidToken döner
+func (repo SqlUserTokenRepo) Find(ctx context.Context, purpose string, id string) (UserToken, error) {
+	select {
+	case <-ctx.Done():
+		return UserToken{}, ErrorOperationCanceled
+	default:
+		var token UserToken
+		result := repo.db.Where("id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", id, purpose, time.Now()).
+			Limit(1).
+			Find(&token)
+		if result.Error != nil {
+			return token, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return token, ErrorInvalidToken
+		}
+		return token, nil
+	}
+}
+
+// Token'ı ve kullanıcının aynı amaçla verilmiş diğer token'larını kullanılmış sayar.
+// Aynı anda iki istek gelirse sadece biri başarılı olur.
+func (repo SqlUserTokenRepo) Use(ctx context.Context, token UserToken) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		now := time.Now()
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&UserToken{}).
+				Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+				Update("used_at", now)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrorInvalidToken
+			}
+			return tx.Model(&UserToken{}).
+				Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+				Update("used_at", now).Error
+		})
+	}
+}
+
+// Yanlış deneme sayısını artırıp yeni sayıyı döner
+func (repo SqlUserTokenRepo) Fail(ctx context.Context, token UserToken) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&UserToken{}).Where("id = ?", token.ID).Update("attempts", gorm.Expr("attempts + 1"))
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		var attempts []int
+		result = repo.db.Model(&UserToken{}).Where("id = ?", token.ID).Pluck("attempts", &attempts)
+		if result.Error != nil || len(attempts) == 0 {
+			return 0, result.Error
+		}
+		return attempts[0], nil
+	}
+}
+
+// Aynı kullanıcıya art arda e-posta gönderilmesini sınırlamak için
+func (repo SqlUserTokenRepo) CountSince(ctx context.Context, user_id string, purpose string, since time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND created_at > ?", user_id, purpose, since).
+			Count(&count)
+		return count, result.Error
+	}
+}
+
+func (repo SqlUserTokenRepo) Purge(ctx context.Context, now time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		result := repo.db.Where("expires_at < ?", now).Delete(&UserToken{})
+		return result.RowsAffected, result.Error
+	}
+}
+
+func (t UserToken) IsValid() bool {
+	if t.ID == "" || t.UserID == "" {
+		return false
+	}
+	if t.Purpose != TokenVerifyEmail && t.Purpose != TokenResetPassword && t.Purpose != TokenTwoFactor {
+		return false
+	}
+	return t.ExpiresAt.After(time.Now())
+}
@@ -0,0 +1,351 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Çöp kutusundaki kayıt türleri, tablo adlarıyla aynı
+const (
+	TrashProjects = "projects"
+	TrashChapters = "chapters"
+	TrashComments = "comments"
+	TrashUsers    = "users"
+)
+
+type TrashItem struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`            // başlık, yorum içeriği ya da kullanıcı adı
+	Parent    string    `json:"parent,omitempty"` // bölümün projesi, yorumun yazarı, kullanıcının e-postası
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type TrashRepo interface {
+	List(ctx context.Context, kind string, limit int, offset int) ([]TrashItem, error)
+	Restore(ctx context.Context, kind string, id string) error
+	Purge(ctx context.Context, kind string, id string) error
+	PurgeOlderThan(ctx context.Context, before time.Time) (int64, error)
+}
+
+type SqlTrashRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlTrashRepo(db *gorm.DB) *SqlTrashRepo {
+	return &SqlTrashRepo{
+		db: db,
+	}
+}
+
+func IsTrashKind(kind string) bool {
+	switch kind {
+	case TrashProjects, TrashChapters, TrashComments, TrashUsers:
+		return true
+	}
+	return false
+}
+
+// Projeyle birlikte silinen bölümler ayrıca listelenmez, proje geri yüklenince onlar da döner.
+// Cevabı olduğu için içeriği temizlenip yerinde bırakılan yorumlar da listelenmez.
+func (repo SqlTrashRepo) List(ctx context.Context, kind string, limit int, offset int) ([]TrashItem, error) {
+	select {
+	case <-ctx.Done():
+		return []TrashItem{}, ErrorOperationCanceled
+	default:
+		var query *gorm.DB
+		switch kind {
+		case TrashProjects:
+			query = repo.db.Table("projects").
+				Select("projects.id, projects.title, '' AS parent, projects.deleted_at").
+				Where("projects.deleted_at IS NOT NULL")
+		case TrashChapters:
+			query = repo.db.Table("chapters").
+				Select("chapters.id, chapters.title, projects.title AS parent, chapters.deleted_at").
+				Joins("LEFT JOIN projects ON projects.id = chapters.project_id").
+				Where("chapters.deleted_at IS NOT NULL").
+				Where("projects.deleted_at IS NULL OR projects.deleted_at <> chapters.deleted_at")
+		case TrashComments:
+			query = repo.db.Table("comments").
+				Select("comments.id, comments.content AS title, users.username AS parent, comments.deleted_at").
+				Joins("LEFT JOIN users ON users.id = comments.user_id").
+				Where("comments.deleted_at IS NOT NULL AND comments.user_id <> ''")
+		case TrashUsers:
+			query = repo.db.Table("users").
+				Select("users.id, users.username AS title, users.email AS parent, users.deleted_at").
+				Where("users.deleted_at IS NOT NULL")
+		default:
+			return []TrashItem{}, ErrorInvalidTrashKind
+		}
+		var items []TrashItem
+		result := query.Order(kind + ".deleted_at desc").Limit(limit).Offset(offset).Scan(&items)
+		for i := range items {
+			items[i].Kind = kind
+		}
+		return items, result.Error
+	}
+}
+
+// Proje, aynı anda silinmiş bölümleriyle birlikte geri gelir. Bölüm ve yorumların
+// projesi ya da bölümü hâlâ silinmişse önce onlar geri yüklenmeli.
+func (repo SqlTrashRepo) Restore(ctx context.Context, kind string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		if !IsTrashKind(kind) {
+			return ErrorInvalidTrashKind
+		}
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			switch kind {
+			case TrashProjects:
+				var project Project
+				if err := tx.Unscoped().First(&project, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+					return err
+				}
+				err := tx.Unscoped().Model(&Chapter{}).
+					Where("project_id = ? AND deleted_at = ?", id, project.DeletedAt).
+					Update("deleted_at", nil).Error
+				if err != nil {
+					return err
+				}
+			case TrashChapters:
+				var chapter Chapter
+				if err := tx.Unscoped().First(&chapter, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+					return err
+				}
+				if err := requireRestored(tx, &Project{}, &chapter.ProjectID); err != nil {
+					return err
+				}
+			case TrashComments:
+				var comment Comment
+				if err := tx.Unscoped().First(&comment, "id = ? AND deleted_at IS NOT NULL AND user_id <> ''", id).Error; err != nil {
+					return err
+				}
+				if err := requireRestored(tx, &Project{}, comment.ProjectID); err != nil {
+					return err
+				}
+				if err := requireRestored(tx, &Chapter{}, comment.ChapterID); err != nil {
+					return err
+				}
+			}
+			result := tx.Table(kind).Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+			if result.Error == nil && result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return result.Error
+		})
+	}
+}
+
+// Üst kayıt silinmişse ErrorParentDeleted döner
+func requireRestored(tx *gorm.DB, model interface{}, id *string) error {
+	if id == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(model).Where("id = ?", *id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrorParentDeleted
+	}
+	return nil
+}
+
+// Sadece çöp kutusundaki kayıtlar kalıcı olarak silinebilir, bağlı her şey de silinir
+func (repo SqlTrashRepo) Purge(ctx context.Context, kind string, id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		if !IsTrashKind(kind) {
+			return ErrorInvalidTrashKind
+		}
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Table(kind).Where("id = ? AND deleted_at IS NOT NULL", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return purge(tx, kind, id)
+		})
+	}
+}
+
+// before'dan önce silinmiş her şeyi kalıcı olarak siler, silinen kayıt sayısını döner
+func (repo SqlTrashRepo) PurgeOlderThan(ctx context.Context, before time.Time) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var purged int64
+		// Projeler bölümlerini de sildiği için önce gelir
+		for _, kind := range []string{TrashProjects, TrashChapters, TrashComments, TrashUsers} {
+			var ids []string
+			query := repo.db.Table(kind).Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+			if kind == TrashComments {
+				query = query.Where("user_id <> ''")
+			}
+			if err := query.Pluck("id", &ids).Error; err != nil {
+				return purged, err
+			}
+			for _, id := range ids {
+				err := repo.db.Transaction(func(tx *gorm.DB) error {
+					return purge(tx, kind, id)
+				})
+				if err != nil {
+					return purged, err
+				}
+				purged++
+			}
+		}
+		return purged, nil
+	}
+}
+
+func purge(tx *gorm.DB, kind string, id string) error {
+	switch kind {
+	case TrashProjects:
+		return purgeProject(tx, id)
+	case TrashChapters:
+		return purgeChapters(tx, []string{id})
+	case TrashComments:
+		return purgeComment(tx, id)
+	case TrashUsers:
+		return purgeUser(tx, id)
+	}
+	return ErrorInvalidTrashKind
+}
+
+func purgeProject(tx *gorm.DB, id string) error {
+	var chapter_ids []string
+	if err := tx.Unscoped().Model(&Chapter{}).Where("project_id = ?", id).Pluck("id", &chapter_ids).Error; err != nil {
+		return err
+	}
+	if err := purgeChapters(tx, chapter_ids); err != nil {
+		return err
+	}
+	review_ids := tx.Model(&Review{}).Select("id").Where("project_id = ?", id)
+	return runSteps(
+		func() *gorm.DB { return tx.Where("review_id IN (?)", review_ids).Delete(&ReviewVote{}) },
+		func() *gorm.DB { return tx.Where("project_id = ?", id).Delete(&Review{}) },
+		func() *gorm.DB { return tx.Unscoped().Where("project_id = ?", id).Delete(&Volume{}) },
+		func() *gorm.DB { return tx.Unscoped().Where("project_id = ?", id).Delete(&Comment{}) },
+		func() *gorm.DB { return tx.Where("project_id = ?", id).Delete(&LibraryEntry{}) },
+		func() *gorm.DB { return tx.Where("project_id = ?", id).Delete(&ReadingProgress{}) },
+		func() *gorm.DB { return tx.Where("project_id = ?", id).Delete(&ProjectMember{}) },
+		func() *gorm.DB { return tx.Where("project_id = ?", id).Delete(&ProjectInvitation{}) },
+		func() *gorm.DB { return tx.Where("project_id = ?", id).Delete(&Notification{}) },
+		func() *gorm.DB {
+			return tx.Where("kind = ? AND target_id = ?", TrashProjects, id).Delete(&SlugRedirect{})
+		},
+		func() *gorm.DB { return tx.Unscoped().Where("id = ?", id).Delete(&Project{}) },
+	)
+}
+
+func purgeChapters(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return runSteps(
+		func() *gorm.DB { return tx.Unscoped().Where("chapter_id IN ?", ids).Delete(&ChapterRevision{}) },
+		func() *gorm.DB { return tx.Where("chapter_id IN ?", ids).Delete(&ReadingProgress{}) },
+		func() *gorm.DB {
+			return tx.Model(&LibraryEntry{}).Where("last_chapter_id IN ?", ids).Update("last_chapter_id", nil)
+		},
+		func() *gorm.DB { return tx.Unscoped().Where("chapter_id IN ?", ids).Delete(&Comment{}) },
+		func() *gorm.DB { return tx.Where("chapter_id IN ?", ids).Delete(&Notification{}) },
+		func() *gorm.DB {
+			return tx.Where("kind = ? AND target_id IN ?", TrashChapters, ids).Delete(&SlugRedirect{})
+		},
+		func() *gorm.DB { return tx.Unscoped().Where("id IN ?", ids).Delete(&Chapter{}) },
+	)
+}
+
+// Adımlar sırayla çalışır, biri hata verirse sonrakiler çalıştırılmaz
+func runSteps(steps ...func() *gorm.DB) error {
+	for _, step := range steps {
+		if err := step().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cevabı olan yorum silinirse thread bozulur, içeriği ve yazarı temizlenip yerinde bırakılır
+func purgeComment(tx *gorm.DB, id string) error {
+	var replies int64
+	if err := tx.Unscoped().Model(&Comment{}).Where("parent_id = ?", id).Count(&replies).Error; err != nil {
+		return err
+	}
+	if replies > 0 {
+		return tx.Unscoped().Model(&Comment{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"content": "", "user_id": ""}).Error
+	}
+	return tx.Unscoped().Where("id = ?", id).Delete(&Comment{}).Error
+}
+
+// Kullanıcının kişisel verileri silinir. Yorumları thread'ler bozulmasın diye silinmiş olarak
+// yerinde kalır, review'leri ve oyları silinip puanlar yeniden hesaplanır.
+func purgeUser(tx *gorm.DB, id string) error {
+	var voted []Review
+	if err := tx.Where("id IN (?)", tx.Model(&ReviewVote{}).Select("review_id").Where("user_id = ?", id)).Find(&voted).Error; err != nil {
+		return err
+	}
+	var project_ids []string
+	if err := tx.Model(&Review{}).Where("user_id = ?", id).Pluck("project_id", &project_ids).Error; err != nil {
+		return err
+	}
+	own_reviews := tx.Model(&Review{}).Select("id").Where("user_id = ?", id)
+	if err := runSteps(
+		func() *gorm.DB {
+			return tx.Where("user_id = ? OR review_id IN (?)", id, own_reviews).Delete(&ReviewVote{})
+		},
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&Review{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&Session{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&UserToken{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&TwoFactor{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&RecoveryCode{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&APIKey{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&Identity{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&AuthState{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&UserRole{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&Shelf{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&LibraryEntry{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&ReadingProgress{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&Notification{}) },
+		func() *gorm.DB { return tx.Where("user_id = ?", id).Delete(&ProjectMember{}) },
+		func() *gorm.DB {
+			return tx.Where("user_id = ? OR invited_by_id = ?", id, id).Delete(&ProjectInvitation{})
+		},
+		func() *gorm.DB {
+			return tx.Unscoped().Model(&Comment{}).Where("user_id = ?", id).
+				Updates(map[string]interface{}{"content": "", "user_id": "", "deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", time.Now())})
+		},
+		func() *gorm.DB {
+			return tx.Unscoped().Model(&ChapterRevision{}).Where("editor_id = ?", id).Update("editor_id", nil)
+		},
+		func() *gorm.DB { return tx.Unscoped().Where("id = ?", id).Delete(&User{}) },
+	); err != nil {
+		return err
+	}
+	for _, project_id := range project_ids {
+		if err := refreshRating(tx, project_id); err != nil {
+			return err
+		}
+	}
+	for i := range voted {
+		if voted[i].UserID == id {
+			continue
+		}
+		if err := refreshVotes(tx, &voted[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kullanıcının TOTP anahtarı. EnabledAt boşsa kurulum başlamış ama ilk kodla onaylanmamıştır.
+type TwoFactor struct {
+	UserID    string `gorm:"primaryKey;"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Secret    string `gorm:"not null;size:64;"`
+	EnabledAt *time.Time
+	// Son kabul edilen kodun zaman adımı, aynı kod iki kez kullanılamaz
+	LastStep int64 `gorm:"not null;default:0;"`
+}
+
+// Telefonu kaybeden kullanıcı için tek kullanımlık kodlar, sadece özetleri tutulur
+type RecoveryCode struct {
+	ID        uint `gorm:"primaryKey;"`
+	CreatedAt time.Time
+	UserID    string `gorm:"not null;index;"`
+	CodeHash  string `gorm:"not null;size:64;uniqueIndex;"`
+	UsedAt    *time.Time
+}
+
+type TwoFactorRepo interface {
+	Find(ctx context.Context, user_id string) (TwoFactor, error)
+	IsEnabled(ctx context.Context, user_id string) (bool, error)
+	Setup(ctx context.Context, user_id string, secret string) error
+	Enable(ctx context.Context, user_id string, step int64, code_hashes []string) error
+	UseStep(ctx context.Context, user_id string, step int64) error
+	UseRecoveryCode(ctx context.Context, user_id string, code_hash string) error
+	ReplaceRecoveryCodes(ctx context.Context, user_id string, code_hashes []string) error
+	RecoveryCodesLeft(ctx context.Context, user_id string) (int64, error)
+	Disable(ctx context.Context, user_id string) error
+}
+
+type SqlTwoFactorRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlTwoFactorRepo(db *gorm.DB) *SqlTwoFactorRepo {
+	return &SqlTwoFactorRepo{
+		db: db,
+	}
+}
+
+func (repo SqlTwoFactorRepo) Find(ctx context.Context, user_id string) (TwoFactor, error) {
+	select {
+	case <-ctx.Done():
+		return TwoFactor{}, ErrorOperationCanceled
+	default:
+		var two_factor TwoFactor
+		result := repo.db.First(&two_factor, "user_id = ?", user_id)
+		return two_factor, result.Error
+	}
+}
+
+func (repo SqlTwoFactorRepo) IsEnabled(ctx context.Context, user_id string) (bool, error) {
+	select {
+	case <-ctx.Done():
+		return false, ErrorOperationCanceled
+	default:
+		return twoFactorEnabled(repo.db, user_id)
+	}
+}
+
+// Onaylanmamış kurulum varsa anahtarı değiştirir, açıksa ErrorTwoFactorEnabled döner
+func (repo SqlTwoFactorRepo) Setup(ctx context.Context, user_id string, secret string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			enabled, err := twoFactorEnabled(tx, user_id)
+			if err != nil {
+				return err
+			}
+			if enabled {
+				return ErrorTwoFactorEnabled
+			}
+			return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&TwoFactor{UserID: user_id, Secret: secret}).Error
+		})
+	}
+}
+
+// İlk doğru kodla kurulumu onaylar ve kurtarma kodlarını kaydeder
+func (repo SqlTwoFactorRepo) Enable(ctx context.Context, user_id string, step int64, code_hashes []string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&TwoFactor{}).
+				Where("user_id = ? AND enabled_at IS NULL", user_id).
+				Updates(map[string]interface{}{"enabled_at": time.Now(), "last_step": step})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrorTwoFactorEnabled
+			}
+			return replaceRecoveryCodes(tx, user_id, code_hashes)
+		})
+	}
+}
+
+// Adım daha önce kullanıldıysa ErrorInvalidCode döner. Aynı anda gelen iki istekten biri kazanır.
+func (repo SqlTwoFactorRepo) UseStep(ctx context.Context, user_id string, step int64) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&TwoFactor{}).
+			Where("user_id = ? AND enabled_at IS NOT NULL AND last_step < ?", user_id, step).
+			Update("last_step", step)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrorInvalidCode
+		}
+		return nil
+	}
+}
+
+func (repo SqlTwoFactorRepo) UseRecoveryCode(ctx context.Context, user_id string, code_hash string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		result := repo.db.Model(&RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user_id, code_hash).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrorInvalidCode
+		}
+		return nil
+	}
+}
+
+// Eski kodlar kullanılmamış olsa da geçersizleşir
+func (repo SqlTwoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, user_id string, code_hashes []string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			enabled, err := twoFactorEnabled(tx, user_id)
+			if err != nil {
+				return err
+			}
+			if !enabled {
+				return ErrorTwoFactorDisabled
+			}
+			return replaceRecoveryCodes(tx, user_id, code_hashes)
+		})
+	}
+}
+
+func (repo SqlTwoFactorRepo) RecoveryCodesLeft(ctx context.Context, user_id string) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ErrorOperationCanceled
+	default:
+		var count int64
+		result := repo.db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user_id).Count(&count)
+		return count, result.Error
+	}
+}
+
+// Anahtar ve kurtarma kodları silinir
+func (repo SqlTwoFactorRepo) Disable(ctx context.Context, user_id string) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", user_id).Delete(&RecoveryCode{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("user_id = ?", user_id).Delete(&TwoFactor{}).Error
+		})
+	}
+}
+
+func twoFactorEnabled(db *gorm.DB, user_id string) (bool, error) {
+	var count int64
+	result := db.Model(&TwoFactor{}).Where("user_id = ? AND enabled_at IS NOT NULL", user_id).Count(&count)
+	return count > 0, result.Error
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, user_id string, code_hashes []string) error {
+	if err := tx.Where("user_id = ?", user_id).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(code_hashes) == 0 {
+		return nil
+	}
+	codes := make([]RecoveryCode, len(code_hashes))
+	for i, hash := range code_hashes {
+		codes[i] = RecoveryCode{UserID: user_id, CodeHash: hash}
+	}
+	return tx.Create(&codes).Error
+}
@@ -16,13 +16,13 @@ type User struct {
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index"`
 	LastLogin      time.Time      `json:"last_login"`
-	IsAdmin        bool           `json:"is_admin"`
-	IsStaff        bool           `json:"is_staff"`
 	Username       string         `gorm:"not null;size:256;unique;;" json:"username"`
 	Email          string         `gorm:"not null;size:256;unique;;" json:"email"`
 	Name           string         `gorm:"not null;size:128;;" json:"name"`
 	Password       string         `gorm:"not null;size:128;;" json:"-"`
 	ProfilePicture string         `gorm:"size:128;" json:"profile_picture"`
+	// Doğrulama linki açılınca ya da şifre e-postayla sıfırlanınca dolar
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
 }
 
 type UserRepo interface {
@@ -86,7 +86,9 @@ func (repo SqlUserRepo) Add(ctx context.Context, user User) error {
 			return ErrorInvalidUser
 		}
 		user.ID = uuid.New().String()
-		user.SetPassword(user.Password)
+		if err := user.SetPassword(user.Password); err != nil {
+			return err
+		}
 		result := repo.db.Create(&user)
 		return result.Error
 	}
@@ -131,7 +133,7 @@ func (u User) IsValid() bool {
 
 // does not save with new password
 func (u *User) SetPassword(passwd string) error {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(passwd), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
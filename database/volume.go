@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Volume struct {
+	ID        string         `gorm:"type:uuid;primary_key;" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ProjectID string         `gorm:"not null;index;" json:"project_id"`
+	Number    float64        `json:"number"`
+	Title     string         `gorm:"not null;size:128;" json:"title"`
+}
+
+type VolumeRepo interface {
+	Find(ctx context.Context, id string) (Volume, error)
+	Add(ctx context.Context, volume Volume) (Volume, error)
+	Update(ctx context.Context, volume Volume) (Volume, error)
+	Delete(ctx context.Context, volume Volume) error
+	List(ctx context.Context, project_id string) ([]Volume, error)
+}
+
+type SqlVolumeRepo struct {
+	db *gorm.DB
+}
+
+func NewSqlVolumeRepo(db *gorm.DB) *SqlVolumeRepo {
+	return &SqlVolumeRepo{
+		db: db,
+	}
+}
+
+func (repo SqlVolumeRepo) Find(ctx context.Context, id string) (Volume, error) {
+	select {
+	case <-ctx.Done():
+		return Volume{}, ErrorOperationCanceled
+	default:
+		var volume Volume
+		result := repo.db.First(&volume, "id = ?", id)
+		return volume, result.Error
+	}
+}
+
+func (repo SqlVolumeRepo) Add(ctx context.Context, volume Volume) (Volume, error) {
+	select {
+	case <-ctx.Done():
+		return volume, ErrorOperationCanceled
+	default:
+		if !volume.IsValid() {
+			return volume, ErrorInvalidVolume
+		}
+		volume.ID = uuid.New().String()
+		result := repo.db.Create(&volume)
+		return volume, result.Error
+	}
+}
+
+func (repo SqlVolumeRepo) Update(ctx context.Context, volume Volume) (Volume, error) {
+	select {
+	case <-ctx.Done():
+		return volume, ErrorOperationCanceled
+	default:
+		if !volume.IsValid() {
+			return volume, ErrorInvalidVolume
+		}
+		result := repo.db.Save(&volume)
+		return volume, result.Error
+	}
+}
+
+// Cilt silinince bölümleri silinmez, sadece ciltsiz kalır
+func (repo SqlVolumeRepo) Delete(ctx context.Context, volume Volume) error {
+	select {
+	case <-ctx.Done():
+		return ErrorOperationCanceled
+	default:
+		return repo.db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&Chapter{}).Where("volume_id = ?", volume.ID).Update("volume_id", nil)
+			if result.Error != nil {
+				return result.Error
+			}
+			return tx.Delete(&volume).Error
+		})
+	}
+}
+
+func (repo SqlVolumeRepo) List(ctx context.Context, project_id string) ([]Volume, error) {
+	select {
+	case <-ctx.Done():
+		return []Volume{}, ErrorOperationCanceled
+	default:
+		var volumes []Volume
+		result := repo.db.Where("project_id = ?", project_id).Order("number asc").Find(&volumes)
+		return volumes, result.Error
+	}
+}
+
+func (v Volume) IsValid() bool {
+	if len(v.Title) > 128 {
+		return false
+	}
+	if v.ProjectID == "" || v.Number < 0 {
+		return false
+	}
+	return true
+}
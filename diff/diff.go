@@ -0,0 +1,239 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	text string
+	a, b int // satırın a ve b'deki indexi
+}
+
+// a'dan b'ye satır bazlı unified diff üretir. Metinler aynıysa boş string döner.
+func Unified(fromName, toName, a, b string, context int) string {
+	ops := lineOps(splitLines(a), splitLines(b))
+	changed := false
+	for _, o := range ops {
+		if o.kind != opEqual {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for _, hunk := range hunks(ops, context) {
+		writeHunk(&out, hunk)
+	}
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Myers'ın doğrusal bellekli algoritmasıyla silinen ve eklenen satırları işaretleyip düzenleme listesi çıkarır.
+// Bellek satır sayısıyla doğru orantılı, LCS tablosu gibi iki metnin çarpımı kadar değil.
+func lineOps(a, b []string) []op {
+	d := differ{a: a, b: b, deleted: make([]bool, len(a)), inserted: make([]bool, len(b))}
+	d.compare(0, len(a), 0, len(b))
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.deleted[i]:
+			ops = append(ops, op{opDelete, a[i], i, j})
+			i++
+		case j < len(b) && d.inserted[j]:
+			ops = append(ops, op{opInsert, b[j], i, j})
+			j++
+		default:
+			ops = append(ops, op{opEqual, a[i], i, j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+type differ struct {
+	a, b     []string
+	deleted  []bool // a'daki satır silindi
+	inserted []bool // b'deki satır eklendi
+}
+
+// a[aLo:aHi] ile b[bLo:bHi] arasındaki farkları işaretler. Ortak baş ve son kırpılır,
+// kalan kısım iki yönden aranan orta noktadan bölünüp iki yarısı ayrı ayrı karşılaştırılır.
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	if aLo == aHi || bLo == bHi {
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+		for j := bLo; j < bHi; j++ {
+			d.inserted[j] = true
+		}
+		return
+	}
+	x, y, ok := d.bisect(aLo, aHi, bLo, bHi)
+	if !ok {
+		// Ortak satır yok, hepsi silinip eklenir
+		d.compare(aLo, aHi, bHi, bHi)
+		d.compare(aHi, aHi, bLo, bHi)
+		return
+	}
+	d.compare(aLo, x, bLo, y)
+	d.compare(x, aHi, y, bHi)
+}
+
+// En kısa düzenleme yolunu baştan ve sondan aynı anda arar, yolların buluştuğu noktayı döner
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset, size := maxD, 2*maxD+2
+	forward, backward := make([]int, size), make([]int, size)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	// Toplam uzunluk farkı tekse yollar ileri adımda, çiftse geri adımda buluşur
+	odd := delta%2 != 0
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		for k := -step + fStart; k <= step-fEnd; k += 2 {
+			index := offset + k
+			var x int
+			if k == -step || (k != step && forward[index-1] < forward[index+1]) {
+				x = forward[index+1]
+			} else {
+				x = forward[index-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[index] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				other := offset + delta - k
+				if other >= 0 && other < size && backward[other] != -1 && x >= n-backward[other] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+		for k := -step + bStart; k <= step-bEnd; k += 2 {
+			index := offset + k
+			var x int
+			if k == -step || (k != step && backward[index-1] < backward[index+1]) {
+				x = backward[index+1]
+			} else {
+				x = backward[index-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-x-1] == d.b[bHi-y-1] {
+				x++
+				y++
+			}
+			backward[index] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				other := offset + delta - k
+				if other >= 0 && other < size && forward[other] != -1 {
+					fx := forward[other]
+					if fx >= n-x {
+						return aLo + fx, bLo + fx - (other - offset), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Değişiklikleri etraflarında context kadar satırla gruplar
+func hunks(ops []op, context int) [][]op {
+	var result [][]op
+	start, end := -1, -1
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		from, to := max(i-context, 0), min(i+context+1, len(ops))
+		if start >= 0 && from <= end {
+			end = to
+			continue
+		}
+		if start >= 0 {
+			result = append(result, ops[start:end])
+		}
+		start, end = from, to
+	}
+	if start >= 0 {
+		result = append(result, ops[start:end])
+	}
+	return result
+}
+
+func writeHunk(out *strings.Builder, hunk []op) {
+	aStart, bStart := hunk[0].a, hunk[0].b
+	aCount, bCount := 0, 0
+	for _, o := range hunk {
+		if o.kind != opInsert {
+			aCount++
+		}
+		if o.kind != opDelete {
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, o := range hunk {
+		out.WriteByte(byte(o.kind))
+		out.WriteString(o.text)
+		out.WriteByte('\n')
+	}
+}
+
+// Boş aralıkta satır numarası, kendinden önceki satırı gösterir (GNU diff gibi)
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
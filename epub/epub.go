@@ -0,0 +1,197 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+type Image struct {
+	Data      []byte
+	MediaType string
+}
+
+type Chapter struct {
+	Title   string
+	Content string
+}
+
+type Book struct {
+	Identifier  string // urn:uuid:... gibi benzersiz bir değer
+	Title       string
+	Author      string
+	Description string
+	Subjects    []string
+	Language    string
+	Modified    time.Time
+	Cover       *Image
+	Chapters    []Chapter
+}
+
+var coverExtensions = map[string]string{
+	"image/jpeg":    "jpg",
+	"image/png":     "png",
+	"image/gif":     "gif",
+	"image/webp":    "webp",
+	"image/svg+xml": "svg",
+}
+
+// EPUB'ın kabul ettiği kapak formatlarından biri mi
+func IsSupportedImage(mediaType string) bool {
+	_, ok := coverExtensions[mediaType]
+	return ok
+}
+
+type manifestItem struct {
+	ID         string
+	Href       string
+	MediaType  string
+	Properties string
+}
+
+// Kitabı EPUB 3 olarak w'ya yazar. Eski okuyucular için toc.ncx de eklenir.
+func (b Book) Write(w io.Writer) error {
+	if b.Language == "" {
+		b.Language = "und"
+	}
+	if b.Modified.IsZero() {
+		b.Modified = time.Now()
+	}
+	archive := zip.NewWriter(w)
+
+	// mimetype ilk dosya olmalı ve sıkıştırılmamalı
+	mimetype, err := archive.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: b.Modified})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := map[string]string{}
+	order := []string{"META-INF/container.xml"}
+	files["META-INF/container.xml"] = containerXML
+
+	manifest := []manifestItem{
+		{ID: "nav", Href: "nav.xhtml", MediaType: "application/xhtml+xml", Properties: "nav"},
+		{ID: "ncx", Href: "toc.ncx", MediaType: "application/x-dtbncx+xml"},
+		{ID: "style", Href: "style.css", MediaType: "text/css"},
+	}
+	var spine []string
+	var coverHref string
+	if b.Cover != nil && IsSupportedImage(b.Cover.MediaType) {
+		coverHref = "cover." + coverExtensions[b.Cover.MediaType]
+		manifest = append(manifest,
+			manifestItem{ID: "cover-image", Href: coverHref, MediaType: b.Cover.MediaType, Properties: "cover-image"},
+			manifestItem{ID: "cover", Href: "cover.xhtml", MediaType: "application/xhtml+xml"},
+		)
+		spine = append(spine, "cover")
+		cover, err := render(coverTemplate, map[string]string{"Title": b.Title, "Href": coverHref})
+		if err != nil {
+			return err
+		}
+		files["OEBPS/cover.xhtml"] = cover
+		order = append(order, "OEBPS/cover.xhtml")
+	}
+	for i, chapter := range b.Chapters {
+		id := fmt.Sprintf("chapter-%03d", i+1)
+		manifest = append(manifest, manifestItem{ID: id, Href: id + ".xhtml", MediaType: "application/xhtml+xml"})
+		spine = append(spine, id)
+		content, err := render(chapterTemplate, map[string]interface{}{
+			"Language":   b.Language,
+			"Title":      chapter.Title,
+			"Paragraphs": Paragraphs(chapter.Content),
+		})
+		if err != nil {
+			return err
+		}
+		files["OEBPS/"+id+".xhtml"] = content
+		order = append(order, "OEBPS/"+id+".xhtml")
+	}
+
+	data := map[string]interface{}{
+		"Book":     b,
+		"Modified": b.Modified.UTC().Format("2006-01-02T15:04:05Z"),
+		"Manifest": manifest,
+		"Spine":    spine,
+		"HasCover": coverHref != "",
+	}
+	for _, tmpl := range []*template.Template{packageTemplate, navTemplate, ncxTemplate} {
+		content, err := render(tmpl, data)
+		if err != nil {
+			return err
+		}
+		name := "OEBPS/" + tmpl.Name()
+		files[name] = content
+		order = append(order, name)
+	}
+	files["OEBPS/style.css"] = stylesheet
+	order = append(order, "OEBPS/style.css")
+
+	for _, name := range order {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: b.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(file, files[name]); err != nil {
+			return err
+		}
+	}
+	if coverHref != "" {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: "OEBPS/" + coverHref, Method: zip.Deflate, Modified: b.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(b.Cover.Data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Düz metni boş olmayan satırlarından paragraflara böler
+func Paragraphs(content string) []string {
+	var paragraphs []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+func render(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// XML'de geçersiz olan kontrol karakterlerini atıp escape eder
+func escape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return r
+		}
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
+
+func newTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(template.FuncMap{
+		"esc": escape,
+		"inc": func(i int) int { return i + 1 },
+	}).Parse(text))
+}
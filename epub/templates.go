@@ -0,0 +1,114 @@
+package epub
+
+const containerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const stylesheet = `body { margin: 0 5%; line-height: 1.5; }
+h1 { text-align: center; margin: 1em 0 1.5em; }
+p { text-indent: 1.5em; margin: 0 0 0.5em; }
+.cover { text-align: center; margin: 0; padding: 0; }
+.cover img { max-width: 100%; max-height: 100%; }
+`
+
+var packageTemplate = newTemplate("content.opf", `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{esc .Book.Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{esc .Book.Identifier}}</dc:identifier>
+    <dc:title>{{esc .Book.Title}}</dc:title>
+    <dc:language>{{esc .Book.Language}}</dc:language>
+{{- if .Book.Author}}
+    <dc:creator>{{esc .Book.Author}}</dc:creator>
+{{- end}}
+{{- if .Book.Description}}
+    <dc:description>{{esc .Book.Description}}</dc:description>
+{{- end}}
+{{- range .Book.Subjects}}
+    <dc:subject>{{esc .}}</dc:subject>
+{{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+{{- if .HasCover}}
+    <meta name="cover" content="cover-image"/>
+{{- end}}
+  </metadata>
+  <manifest>
+{{- range .Manifest}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="{{.MediaType}}"{{if .Properties}} properties="{{.Properties}}"{{end}}/>
+{{- end}}
+  </manifest>
+  <spine toc="ncx">
+{{- range .Spine}}
+    <itemref idref="{{.}}"/>
+{{- end}}
+  </spine>
+</package>
+`)
+
+var navTemplate = newTemplate("nav.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{esc .Book.Language}}" lang="{{esc .Book.Language}}">
+<head>
+  <title>{{esc .Book.Title}}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{esc .Book.Title}}</h1>
+    <ol>
+{{- range $i, $chapter := .Book.Chapters}}
+      <li><a href="chapter-{{printf "%03d" (inc $i)}}.xhtml">{{esc $chapter.Title}}</a></li>
+{{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+`)
+
+var ncxTemplate = newTemplate("toc.ncx", `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="{{esc .Book.Identifier}}"/>
+  </head>
+  <docTitle><text>{{esc .Book.Title}}</text></docTitle>
+  <navMap>
+{{- range $i, $chapter := .Book.Chapters}}
+    <navPoint id="nav-{{inc $i}}" playOrder="{{inc $i}}">
+      <navLabel><text>{{esc $chapter.Title}}</text></navLabel>
+      <content src="chapter-{{printf "%03d" (inc $i)}}.xhtml"/>
+    </navPoint>
+{{- end}}
+  </navMap>
+</ncx>
+`)
+
+var chapterTemplate = newTemplate("chapter.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{esc .Language}}" lang="{{esc .Language}}">
+<head>
+  <title>{{esc .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <h1>{{esc .Title}}</h1>
+{{- range .Paragraphs}}
+  <p>{{esc .}}</p>
+{{- end}}
+</body>
+</html>
+`)
+
+var coverTemplate = newTemplate("cover.xhtml", `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+  <title>{{esc .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body class="cover">
+  <img src="{{.Href}}" alt="{{esc .Title}}"/>
+</body>
+</html>
+`)
@@ -0,0 +1,188 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Event struct {
+	ID   string // "<epoch>-<seq>", Last-Event-ID olarak geri gelir
+	Type string
+	Data []byte
+	seq  uint64
+	at   time.Time
+}
+
+// Kullanıcı başına bağlantı kuyruğu, dolarsa bağlantı kapatılır ve istemci
+// Last-Event-ID ile yeniden bağlanıp kaçırdıklarını alır.
+const subscriberBuffer = 32
+
+// Olaylar kullanıcı başına sıralı bir geçmişte tutulur,
+// yeniden bağlanan istemciye kaçırdıkları buradan tekrar gönderilir.
+type Hub struct {
+	mu          sync.Mutex
+	epoch       string
+	seq         uint64
+	historySize int
+	history     map[string][]Event
+	evicted     map[string]uint64 // geçmişten düşen son olayın sırası
+	swept       uint64            // Sweep ile silinen en yeni olayın sırası, bütün kullanıcılar için
+	subscribers map[string]map[*Subscription]struct{}
+	closed      bool
+}
+
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	hub    *Hub
+	userID string
+	once   sync.Once
+}
+
+// historySize kullanıcı başına tekrar gönderilebilecek en fazla olay sayısı
+func NewHub(historySize int) *Hub {
+	return &Hub{
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		historySize: historySize,
+		history:     make(map[string][]Event),
+		evicted:     make(map[string]uint64),
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Olayı kullanıcının açık bütün bağlantılarına gönderir
+func (h *Hub) Publish(user_id string, kind string, data []byte) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	event := Event{ID: fmt.Sprintf("%s-%d", h.epoch, h.seq), Type: kind, Data: data, seq: h.seq, at: time.Now()}
+	if h.closed {
+		return event
+	}
+	history := append(h.history[user_id], event)
+	if len(history) > h.historySize {
+		h.evicted[user_id] = history[len(history)-h.historySize-1].seq
+		history = append([]Event(nil), history[len(history)-h.historySize:]...)
+	}
+	h.history[user_id] = history
+	for sub := range h.subscribers[user_id] {
+		select {
+		case sub.c <- event:
+		default:
+			h.remove(sub)
+		}
+	}
+	return event
+}
+
+// last_event_id'den sonra kaçırılan olayları da döner. complete false ise
+// (sunucu yeniden başlamış ya da geçmiş taşmış) istemci baştan yüklemeli.
+func (h *Hub) Subscribe(user_id string, last_event_id string) (sub *Subscription, missed []Event, complete bool) {
+	c := make(chan Event, subscriberBuffer)
+	sub = &Subscription{C: c, c: c, hub: h, userID: user_id}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(c)
+		return sub, nil, true
+	}
+	if h.subscribers[user_id] == nil {
+		h.subscribers[user_id] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[user_id][sub] = struct{}{}
+	missed, complete = h.since(user_id, last_event_id)
+	return sub, missed, complete
+}
+
+func (h *Hub) since(user_id string, last_event_id string) ([]Event, bool) {
+	if last_event_id == "" {
+		return nil, true
+	}
+	epoch, seq, ok := parseID(last_event_id)
+	if !ok || epoch != h.epoch {
+		return nil, false
+	}
+	var missed []Event
+	for _, event := range h.history[user_id] {
+		if event.seq > seq {
+			missed = append(missed, event)
+		}
+	}
+	// Aradaki olaylardan biri geçmişten düşmüşse ya da silinmiş olabilirse eksik kalır
+	return missed, seq >= h.evicted[user_id] && seq >= h.swept
+}
+
+// before'dan önce yayınlanan olayları geçmişten siler, geçmişi boşalan kullanıcılar tamamen çıkarılır.
+// Yoksa bir kere olay almış her kullanıcının geçmişi bağlanmasa da bellekte kalır. Silinen olay sayısını döner.
+func (h *Hub) Sweep(before time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	removed := 0
+	for user_id, history := range h.history {
+		expired := 0
+		for expired < len(history) && history[expired].at.Before(before) {
+			expired++
+		}
+		if expired == 0 {
+			continue
+		}
+		removed += expired
+		if last := history[expired-1].seq; last > h.swept {
+			h.swept = last
+		}
+		if expired == len(history) {
+			delete(h.history, user_id)
+			delete(h.evicted, user_id)
+			continue
+		}
+		h.history[user_id] = append([]Event(nil), history[expired:]...)
+	}
+	return removed
+}
+
+// Bütün bağlantıları kapatır, Server.Shutdown'ın açık stream'leri beklememesi için
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// h.mu tutulurken çağrılmalı
+func (h *Hub) remove(sub *Subscription) {
+	sub.once.Do(func() {
+		delete(h.subscribers[sub.userID], sub)
+		if len(h.subscribers[sub.userID]) == 0 {
+			delete(h.subscribers, sub.userID)
+		}
+		close(sub.c)
+	})
+}
+
+func parseID(id string) (string, uint64, bool) {
+	epoch, seq, found := strings.Cut(id, "-")
+	if !found {
+		return "", 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return epoch, n, true
+}
@@ -0,0 +1,189 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+type Entry struct {
+	ID        string // urn:uuid:... gibi değişmeyen bir değer
+	Title     string
+	Link      string
+	Author    string
+	Summary   string
+	Published time.Time
+	Updated   time.Time
+}
+
+type Feed struct {
+	ID       string
+	Title    string
+	Subtitle string
+	Link     string // sitedeki sayfa
+	SelfLink string // feed'in kendi adresi
+	Author   string
+	Updated  time.Time
+	Entries  []Entry
+}
+
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Type  string `xml:"type,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Link      atomLink    `xml:"link"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Published string      `xml:"published,omitempty"`
+	Updated   string      `xml:"updated"`
+	Summary   *atomText   `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Creator     string  `xml:"dc:creator,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	SelfLink      rssSelf   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// Atom 1.0 (RFC 4287) olarak yazar
+func (f Feed) WriteAtom(w io.Writer) error {
+	feed := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Subtitle,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.SelfLink},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+		Entries: []atomEntry{},
+	}
+	if f.Author != "" {
+		feed.Author = &atomPerson{Name: f.Author}
+	}
+	for _, e := range f.Entries {
+		entry := atomEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Link:    atomLink{Rel: "alternate", Type: "text/html", Href: e.Link},
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+		}
+		if !e.Published.IsZero() {
+			entry.Published = e.Published.UTC().Format(time.RFC3339)
+		}
+		// Feed'in yazarı yoksa Atom her girdide yazar ister
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		} else if f.Author == "" {
+			entry.Author = &atomPerson{Name: f.Title}
+		}
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: e.Summary}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return write(w, feed)
+}
+
+// RSS 2.0 olarak yazar
+func (f Feed) WriteRSS(w io.Writer) error {
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   f.Subtitle,
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+			SelfLink:      rssSelf{Href: f.SelfLink, Rel: "self", Type: "application/rss+xml"},
+			Items:         []rssItem{},
+		},
+	}
+	if feed.Channel.Description == "" {
+		feed.Channel.Description = f.Title
+	}
+	for _, e := range f.Entries {
+		published := e.Published
+		if published.IsZero() {
+			published = e.Updated
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			GUID:        rssGUID{IsPermaLink: false, Value: e.ID},
+			Creator:     e.Author,
+			PubDate:     published.UTC().Format(time.RFC1123Z),
+			Description: e.Summary,
+		})
+	}
+	return write(w, feed)
+}
+
+func write(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,165 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// OPDS 1.2 medya tipleri ve link rel'leri
+const (
+	NavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	AcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	OpenSearchType  = "application/opensearchdescription+xml"
+
+	RelStart       = "start"
+	RelSubsection  = "subsection"
+	RelSearch      = "search"
+	RelAcquisition = "http://opds-spec.org/acquisition/open-access"
+	RelImage       = "http://opds-spec.org/image"
+	RelThumbnail   = "http://opds-spec.org/image/thumbnail"
+	RelNew         = "http://opds-spec.org/sort/new"
+	RelPopular     = "http://opds-spec.org/sort/popular"
+)
+
+type Link struct {
+	Rel   string
+	Type  string
+	Href  string
+	Title string
+}
+
+type CatalogEntry struct {
+	ID         string
+	Title      string
+	Author     string
+	Summary    string
+	Content    string // navigation girdilerinde kısa açıklama
+	Categories []string
+	Published  time.Time
+	Updated    time.Time
+	Links      []Link
+}
+
+// Navigation ya da acquisition feed'i, hangisi olduğu self link'in tipinden anlaşılır
+type Catalog struct {
+	ID       string
+	Title    string
+	Subtitle string
+	Author   string
+	Updated  time.Time
+	Links    []Link
+	Entries  []CatalogEntry
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type opdsEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Updated    string         `xml:"updated"`
+	Issued     string         `xml:"dc:issued,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Links      []atomLink     `xml:"link"`
+}
+
+type opdsFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	DC       string      `xml:"xmlns:dc,attr"`
+	OPDS     string      `xml:"xmlns:opds,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []opdsEntry `xml:"entry"`
+}
+
+func atomLinks(links []Link) []atomLink {
+	result := make([]atomLink, 0, len(links))
+	for _, link := range links {
+		result = append(result, atomLink{Rel: link.Rel, Type: link.Type, Href: link.Href, Title: link.Title})
+	}
+	return result
+}
+
+// OPDS 1.2 katalog feed'i olarak yazar
+func (c Catalog) WriteOPDS(w io.Writer) error {
+	feed := opdsFeed{
+		DC:       "http://purl.org/dc/terms/",
+		OPDS:     "http://opds-spec.org/2010/catalog",
+		ID:       c.ID,
+		Title:    c.Title,
+		Subtitle: c.Subtitle,
+		Updated:  c.Updated.UTC().Format(time.RFC3339),
+		Links:    atomLinks(c.Links),
+		Entries:  []opdsEntry{},
+	}
+	if c.Author != "" {
+		feed.Author = &atomPerson{Name: c.Author}
+	}
+	for _, e := range c.Entries {
+		entry := opdsEntry{
+			ID:      e.ID,
+			Title:   e.Title,
+			Updated: e.Updated.UTC().Format(time.RFC3339),
+			Links:   atomLinks(e.Links),
+		}
+		if e.Author != "" {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		if !e.Published.IsZero() {
+			entry.Issued = e.Published.UTC().Format("2006-01-02")
+		}
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: e.Summary}
+		}
+		if e.Content != "" {
+			entry.Content = &atomText{Type: "text", Body: e.Content}
+		}
+		for _, category := range e.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category, Label: category})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return write(w, feed)
+}
+
+// KOReader gibi okuyucular aramayı bu açıklamadaki şablonla yapar.
+// Template'te {searchTerms} yer tutucusu olmalı.
+type OpenSearchDescription struct {
+	ShortName   string
+	Description string
+	Template    string
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+type openSearchDocument struct {
+	XMLName        xml.Name      `xml:"http://a9.com/-/spec/opensearch/1.1/ OpenSearchDescription"`
+	ShortName      string        `xml:"ShortName"`
+	Description    string        `xml:"Description"`
+	InputEncoding  string        `xml:"InputEncoding"`
+	OutputEncoding string        `xml:"OutputEncoding"`
+	URL            openSearchURL `xml:"Url"`
+}
+
+func (d OpenSearchDescription) Write(w io.Writer) error {
+	return write(w, openSearchDocument{
+		ShortName:      d.ShortName,
+		Description:    d.Description,
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URL:            openSearchURL{Type: AcquisitionType, Template: d.Template},
+	})
+}
@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/batt0s/batnovels/database"
+)
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Metadata struct {
+		Titles       []string `xml:"title"`
+		Creators     []string `xml:"creator"`
+		Descriptions []string `xml:"description"`
+		Subjects     []string `xml:"subject"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine []struct {
+		IDRef  string `xml:"idref,attr"`
+		Linear string `xml:"linear,attr"`
+	} `xml:"spine>itemref"`
+}
+
+// Spine sırasındaki her XHTML belgesi bir bölüm olur, metni olmayanlar (kapak vb.) atlanır
+func parseEpub(archive *zip.Reader) (Draft, error) {
+	var draft Draft
+	data, err := readFile(archive, "META-INF/container.xml")
+	if err != nil {
+		return draft, err
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(data, &container); err != nil {
+		return draft, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return draft, ErrorUnsupportedFormat
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	data, err = readFile(archive, opfPath)
+	if err != nil {
+		return draft, err
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return draft, err
+	}
+
+	meta := pkg.Metadata
+	draft.Project = database.Project{
+		Title:    first(meta.Titles),
+		Author:   strings.Join(meta.Creators, ", "),
+		Synopsis: descriptionText(first(meta.Descriptions)),
+		Tags:     splitTags(strings.Join(meta.Subjects, ",")),
+	}
+
+	base := path.Dir(opfPath)
+	for _, ref := range pkg.Spine {
+		if ref.Linear == "no" {
+			continue
+		}
+		for _, item := range pkg.Manifest {
+			if item.ID != ref.IDRef {
+				continue
+			}
+			if item.MediaType != "application/xhtml+xml" || strings.Contains(item.Properties, "nav") {
+				break
+			}
+			data, err := readFile(archive, path.Join(base, item.Href))
+			if err != nil {
+				return draft, err
+			}
+			title, content := parseXhtml(data)
+			if strings.TrimSpace(content) == "" {
+				break
+			}
+			if title == "" {
+				title = strings.TrimSuffix(path.Base(item.Href), path.Ext(item.Href))
+			}
+			draft.Chapters = append(draft.Chapters, database.Chapter{
+				Title:   title,
+				Content: content,
+			})
+			break
+		}
+	}
+	if len(draft.Chapters) == 0 {
+		return draft, ErrorEmptyArchive
+	}
+	return draft, nil
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "blockquote": true, "section": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "tr": true, "hr": true,
+}
+
+// XHTML'den düz metin çıkarır. İlk h1-h3 başlık olur ve metne eklenmez, o yoksa <title> kullanılır.
+func parseXhtml(data []byte) (string, string) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	var title, heading string
+	var text, current strings.Builder
+	var stack []string
+	inHeading := false
+	flush := func() {
+		line := strings.Join(strings.Fields(current.String()), " ")
+		current.Reset()
+		if line != "" {
+			text.WriteString(line)
+			text.WriteString("\n\n")
+		}
+	}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF || err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			stack = append(stack, name)
+			if blockElements[name] {
+				flush()
+			}
+			if heading == "" && (name == "h1" || name == "h2" || name == "h3") {
+				inHeading = true
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			if inHeading && (name == "h1" || name == "h2" || name == "h3") {
+				inHeading = false
+				heading = strings.Join(strings.Fields(current.String()), " ")
+				current.Reset()
+				continue
+			}
+			if blockElements[name] {
+				flush()
+			}
+		case xml.CharData:
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			switch {
+			case parent == "title":
+				title += string(t)
+			case contains(stack, "head"), parent == "script", parent == "style":
+			default:
+				current.Write(t)
+			}
+		}
+	}
+	flush()
+	if heading != "" {
+		title = heading
+	}
+	return strings.TrimSpace(title), strings.TrimSpace(text.String())
+}
+
+// Açıklamalar bazen HTML içerir, etiketleri temizler
+func descriptionText(description string) string {
+	if !strings.Contains(description, "<") {
+		return description
+	}
+	_, text := parseXhtml([]byte("<div>" + description + "</div>"))
+	return text
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
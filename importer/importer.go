@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/batt0s/batnovels/database"
+)
+
+var (
+	ErrorUnsupportedFormat = errors.New("file is neither an epub nor a zip of markdown files")
+	ErrorMissingProject    = errors.New("markdown archive has no project.md")
+	ErrorEmptyArchive      = errors.New("archive contains no chapters")
+)
+
+// İçe aktarılan dosyadan çıkan, henüz kaydedilmemiş proje ve bölümler
+type Draft struct {
+	Project  database.Project
+	Chapters []database.Chapter
+}
+
+// Dosyanın EPUB mi yoksa markdown zip'i mi olduğuna bakıp uygun parser'ı çağırır
+func Parse(data []byte) (Draft, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Draft{}, ErrorUnsupportedFormat
+	}
+	if mimetype, err := readFile(archive, "mimetype"); err == nil &&
+		strings.TrimSpace(string(mimetype)) == "application/epub+zip" {
+		return parseEpub(archive)
+	}
+	return parseMarkdown(archive)
+}
+
+func readFile(archive *zip.Reader, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name == name {
+			reader, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer reader.Close()
+			return io.ReadAll(reader)
+		}
+	}
+	return nil, errors.New("file " + name + " not found in archive")
+}
+
+func splitTags(tags string) string {
+	tags = strings.Trim(strings.TrimSpace(tags), "[]")
+	var cleaned []string
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.Trim(strings.TrimSpace(tag), `"'`)
+		if tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
@@ -0,0 +1,185 @@
+package importer
+
+import (
+	"archive/zip"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/batt0s/batnovels/database"
+)
+
+type markdownFile struct {
+	name        string
+	frontMatter map[string]string
+	body        string
+}
+
+// project.md proje bilgilerini (front matter) ve özeti (gövde) taşır,
+// diğer her .md dosyası bir bölümdür. Sıralama "order" alanı, yoksa dosya adındaki sayı ile yapılır.
+func parseMarkdown(archive *zip.Reader) (Draft, error) {
+	var draft Draft
+	var projectFile *markdownFile
+	var chapters []markdownFile
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.EqualFold(path.Ext(file.Name), ".md") {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return draft, err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return draft, err
+		}
+		frontMatter, body := parseFrontMatter(string(data))
+		md := markdownFile{name: file.Name, frontMatter: frontMatter, body: body}
+		if strings.EqualFold(path.Base(file.Name), "project.md") {
+			projectFile = &md
+			continue
+		}
+		chapters = append(chapters, md)
+	}
+	if projectFile == nil {
+		if len(chapters) == 0 {
+			return draft, ErrorUnsupportedFormat
+		}
+		return draft, ErrorMissingProject
+	}
+	if len(chapters) == 0 {
+		return draft, ErrorEmptyArchive
+	}
+	sort.SliceStable(chapters, func(i, j int) bool {
+		oi, iok := chapterOrder(chapters[i])
+		oj, jok := chapterOrder(chapters[j])
+		if iok && jok && oi != oj {
+			return oi < oj
+		}
+		if iok != jok {
+			return iok
+		}
+		return naturalLess(chapters[i].name, chapters[j].name)
+	})
+
+	meta := projectFile.frontMatter
+	draft.Project = database.Project{
+		Title:    meta["title"],
+		Author:   meta["author"],
+		Status:   meta["status"],
+		Tags:     splitTags(meta["tags"]),
+		Image:    meta["image"],
+		Synopsis: strings.TrimSpace(projectFile.body),
+	}
+	if synopsis, ok := meta["synopsis"]; ok {
+		draft.Project.Synopsis = synopsis
+	}
+	for _, md := range chapters {
+		title, body := md.frontMatter["title"], strings.TrimSpace(md.body)
+		if title == "" {
+			title, body = markdownHeading(body)
+		}
+		if title == "" {
+			title = strings.TrimSuffix(path.Base(md.name), path.Ext(md.name))
+		}
+		draft.Chapters = append(draft.Chapters, database.Chapter{
+			Title:   title,
+			Content: body,
+		})
+	}
+	return draft, nil
+}
+
+// "---" satırları arasındaki "anahtar: değer" çiftlerini okur
+func parseFrontMatter(data string) (map[string]string, string) {
+	frontMatter := map[string]string{}
+	data = strings.TrimPrefix(strings.ReplaceAll(data, "\r\n", "\n"), "\ufeff")
+	if !strings.HasPrefix(data, "---\n") {
+		return frontMatter, data
+	}
+	rest := data[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return frontMatter, data
+	}
+	for _, line := range strings.Split(rest[:end], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		frontMatter[key] = value
+	}
+	body := rest[end+len("\n---"):]
+	if newline := strings.Index(body, "\n"); newline >= 0 {
+		body = body[newline+1:]
+	} else {
+		body = ""
+	}
+	return frontMatter, body
+}
+
+// Gövde bir "# Başlık" ile başlıyorsa onu başlık olarak alıp gövdeden çıkarır
+func markdownHeading(body string) (string, string) {
+	first, rest, _ := strings.Cut(body, "\n")
+	if strings.HasPrefix(first, "#") {
+		return strings.TrimSpace(strings.TrimLeft(first, "#")), strings.TrimSpace(rest)
+	}
+	return "", body
+}
+
+// Front matter'da sıra yoksa dosya adındaki ilk sayı kullanılır ("chapter-12.md" -> 12)
+func chapterOrder(md markdownFile) (float64, bool) {
+	for _, key := range []string{"order", "number", "chapter"} {
+		if value, ok := md.frontMatter[key]; ok {
+			order, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				return order, true
+			}
+		}
+	}
+	name := path.Base(md.name)
+	for i := range name {
+		if digits := leadingDigits(name[i:]); digits != "" {
+			order, err := strconv.ParseFloat(digits, 64)
+			return order, err == nil
+		}
+	}
+	return 0, false
+}
+
+// "chapter-2.md" "chapter-10.md"'den önce gelsin diye sayıları sayı olarak karşılaştırır
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := leadingDigits(a), leadingDigits(b)
+		if da != "" && db != "" {
+			na, _ := strconv.Atoi(da)
+			nb, _ := strconv.Atoi(db)
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string // boşsa sadece düz metin gönderilir
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTP sunucusu üzerinden gönderir. Sunucu destekliyorsa STARTTLS kullanılır,
+// Username boşsa kimlik doğrulaması yapılmaz.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := Build(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, from.Address, []string{msg.To}, data)
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// Her mesajı Dir altına ayrı bir .eml dosyası olarak yazar, geliştirme ve testler için
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func (m FileMailer) Send(ctx context.Context, msg Message) error {
+	data, err := Build(m.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + randomHex(4) + ".eml"
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o644)
+}
+
+// Mesajı sadece log'a yazar, hiçbir yere göndermez
+type LogMailer struct {
+	From string
+}
+
+func (m LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("[mailer] To: %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// RFC 5322 mesajı oluşturur. HTML varsa düz metinle birlikte multipart/alternative olarak gönderilir.
+func Build(from string, msg Message, date time.Time) ([]byte, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	recipient, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	header("From", sender.String())
+	header("To", recipient.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", "<"+randomHex(16)+"@"+domain(sender.Address)+">")
+	header("MIME-Version", "1.0")
+	if msg.HTML == "" {
+		writePart(&buf, "text/plain", msg.Text)
+		return buf.Bytes(), nil
+	}
+	boundary := randomHex(16)
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ kind, body string }{{"text/plain", msg.Text}, {"text/html", msg.HTML}} {
+		buf.WriteString("--" + boundary + "\r\n")
+		writePart(&buf, part.kind, part.body)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes(), nil
+}
+
+func writePart(buf *bytes.Buffer, kind string, body string) {
+	buf.WriteString("Content-Type: " + kind + "; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	// Binary değilken satır sonları CRLF'e çevrilir
+	writer := quotedprintable.NewWriter(buf)
+	writer.Write([]byte(body))
+	writer.Close()
+}
+
+func domain(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,78 @@
+package mailer
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+// Konu ve düz metin text/template, HTML html/template ile işlenir, değerler otomatik kaçırılır
+type Template struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+func newTemplate(name string, subject string, text string, html string) *Template {
+	return &Template{
+		subject: template.Must(template.New(name + ".subject").Parse(subject)),
+		text:    template.Must(template.New(name + ".txt").Parse(text)),
+		html:    htmltemplate.Must(htmltemplate.New(name + ".html").Parse(html)),
+	}
+}
+
+func (t *Template) Render(to string, data interface{}) (Message, error) {
+	msg := Message{To: to}
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	// Konuda satır sonu header enjeksiyonuna yol açar
+	msg.Subject = strings.Join(strings.Fields(buf.String()), " ")
+	buf.Reset()
+	if err := t.text.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.Text = buf.String()
+	buf.Reset()
+	if err := t.html.Execute(&buf, data); err != nil {
+		return msg, err
+	}
+	msg.HTML = buf.String()
+	return msg, nil
+}
+
+// Name, Link ve Expires alanlarını bekler
+var VerifyEmail = newTemplate("verify_email",
+	`Confirm your email address for BatNovels`,
+	`Hi {{.Name}},
+
+Please confirm your email address by opening the link below:
+
+{{.Link}}
+
+The link expires in {{.Expires}}. If you did not create a BatNovels account, you can ignore this email.
+`,
+	`<p>Hi {{.Name}},</p>
+<p>Please confirm your email address by clicking the link below:</p>
+<p><a href="{{.Link}}">Confirm email address</a></p>
+<p>The link expires in {{.Expires}}. If you did not create a BatNovels account, you can ignore this email.</p>
+`)
+
+// Name, Link ve Expires alanlarını bekler
+var ResetPassword = newTemplate("reset_password",
+	`Reset your BatNovels password`,
+	`Hi {{.Name}},
+
+Someone asked to reset the password of your BatNovels account. Open the link below to choose a new one:
+
+{{.Link}}
+
+The link expires in {{.Expires}} and can be used once. If you did not ask for this, you can ignore this email; your password will not change.
+`,
+	`<p>Hi {{.Name}},</p>
+<p>Someone asked to reset the password of your BatNovels account. Click the link below to choose a new one:</p>
+<p><a href="{{.Link}}">Reset password</a></p>
+<p>The link expires in {{.Expires}} and can be used once. If you did not ask for this, you can ignore this email; your password will not change.</p>
+`)
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/batt0s/batnovels/controllers"
 )
@@ -29,8 +30,9 @@ func main() {
 		signal.Notify(sigint, os.Interrupt)
 		<-sigint
 		log.Println("Interrupt signal received. Shutting down.")
-		ctx, _ := context.WithTimeout(context.Background(), 60)
-		err := app.Server.Shutdown(ctx)
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		err := app.Shutdown(ctx)
 		if err != nil {
 			log.Println("HTTP Server shutdown error: \n", err.Error())
 		}
@@ -0,0 +1,275 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+var (
+	ErrorDiscovery    = errors.New("oidc discovery failed")
+	ErrorExchange     = errors.New("oidc code exchange failed")
+	ErrorInvalidToken = errors.New("invalid id token")
+)
+
+const (
+	// Bilinmeyen kid gelince JWKS en fazla bu sıklıkla yeniden çekilir
+	keyRefreshInterval = time.Minute
+	clockSkew          = time.Minute
+)
+
+// Sağlayıcı ayarları. Endpoint'ler Issuer'ın discovery belgesinden okunur.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string // boşsa openid email profile
+}
+
+// /.well-known/openid-configuration içinden kullanılan alanlar
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// ID token'dan okunan kullanıcı bilgileri
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Discovery ve JWKS ilk kullanımda çekilip saklanır, sağlayıcı kapalıyken uygulama yine de açılabilir
+type Client struct {
+	Config
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     jwk.Set
+	keysAt   time.Time
+}
+
+func NewClient(config Config) *Client {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Client{
+		Config:     config,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *Client) Metadata(ctx context.Context) (Metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.metadata != nil {
+		return *c.metadata, nil
+	}
+	var metadata Metadata
+	err := c.getJSON(ctx, strings.TrimRight(c.Issuer, "/")+"/.well-known/openid-configuration", &metadata)
+	if err != nil {
+		return metadata, fmt.Errorf("%w: %v", ErrorDiscovery, err)
+	}
+	// Başka bir issuer adına konuşan belge kabul edilmez
+	if metadata.Issuer != c.Issuer {
+		return metadata, fmt.Errorf("%w: issuer %q does not match %q", ErrorDiscovery, metadata.Issuer, c.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return metadata, fmt.Errorf("%w: missing endpoints", ErrorDiscovery)
+	}
+	c.metadata = &metadata
+	return metadata, nil
+}
+
+// Kullanıcının yönlendirileceği adres. PKCE (S256) kullanılır, verifier callback'te Exchange'e verilir.
+func (c *Client) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", c.ClientID)
+	query.Set("redirect_uri", c.RedirectURL)
+	query.Set("scope", strings.Join(c.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", CodeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Authorization code'u token endpoint'inde ID token'la değiştirir, token doğrulanmadan döner
+func (c *Client) Exchange(ctx context.Context, code string, verifier string) (string, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.RedirectURL)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 2.3.1, client_secret_basic
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrorExchange, err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrorExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("%w: %s %s", ErrorExchange, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: no id_token in response", ErrorExchange)
+	}
+	return body.IDToken, nil
+}
+
+// ID token'ın imzasını sağlayıcının JWKS'i ile, issuer, audience, süre ve nonce'unu da beklenen değerlerle kontrol eder
+func (c *Client) Verify(ctx context.Context, raw string, nonce string) (Claims, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	message, err := jws.Parse([]byte(raw))
+	if err != nil || len(message.Signatures()) != 1 {
+		return Claims{}, ErrorInvalidToken
+	}
+	keys, err := c.keySet(ctx, message.Signatures()[0].ProtectedHeaders().KeyID())
+	if err != nil {
+		return Claims{}, err
+	}
+	token, err := jwt.Parse([]byte(raw),
+		// Tek anahtarlı sağlayıcılar kid göndermeyebilir, o zaman o anahtar kullanılır
+		jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true), jws.WithUseDefault(true)),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(c.ClientID),
+		jwt.WithAcceptableSkew(clockSkew),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrorInvalidToken, err)
+	}
+	claims := token.PrivateClaims()
+	if value, _ := claims["nonce"].(string); value == "" || value != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrorInvalidToken)
+	}
+	// Birden fazla audience varsa token bu istemciye verilmiş olmalı
+	if len(token.Audience()) > 1 {
+		if azp, _ := claims["azp"].(string); azp != c.ClientID {
+			return Claims{}, fmt.Errorf("%w: azp mismatch", ErrorInvalidToken)
+		}
+	}
+	if token.Subject() == "" {
+		return Claims{}, fmt.Errorf("%w: no subject", ErrorInvalidToken)
+	}
+	result := Claims{Subject: token.Subject()}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.PreferredUsername, _ = claims["preferred_username"].(string)
+	// Bazı sağlayıcılar bool yerine "true" gönderir
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		result.EmailVerified = verified
+	case string:
+		result.EmailVerified = verified == "true"
+	}
+	return result, nil
+}
+
+// Saklanan JWKS'te kid yoksa sağlayıcı anahtarını değiştirmiş olabilir, yeniden çekilir
+func (c *Client) keySet(ctx context.Context, kid string) (jwk.Set, error) {
+	metadata, err := c.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys != nil {
+		if _, found := c.keys.LookupKeyID(kid); found || kid == "" || time.Since(c.keysAt) < keyRefreshInterval {
+			return c.keys, nil
+		}
+	}
+	var raw json.RawMessage
+	if err := c.getJSON(ctx, metadata.JWKSURI, &raw); err != nil {
+		return nil, fmt.Errorf("%w: jwks: %v", ErrorDiscovery, err)
+	}
+	keys, err := jwk.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: jwks: %v", ErrorDiscovery, err)
+	}
+	c.keys = keys
+	c.keysAt = time.Now()
+	return keys, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, address string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, address)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// state, nonce ve PKCE verifier için
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,487 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+	"github.com/batt0s/batnovels/controllers"
+	"github.com/batt0s/batnovels/database"
+)
+
+// Handler testleri bütün router üzerinden, ayrı bir veritabanıyla çalışır
+const apiDatabase = "api_test.db"
+
+var (
+	apiOnce sync.Once
+	apiApp  *controllers.App
+)
+
+func testApp(t *testing.T) *controllers.App {
+	t.Helper()
+	apiOnce.Do(func() {
+		os.Remove(apiDatabase)
+		os.Setenv("DATABASE_URL", apiDatabase)
+		os.Setenv("MAIL_DRIVER", "log")
+		apiApp = &controllers.App{AppMode: "test"}
+		if err := apiApp.Init(); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	})
+	if apiApp.Database == nil {
+		t.Fatalf("test app failed to start")
+	}
+	return apiApp
+}
+
+func call(t *testing.T, method string, path string, header http.Header, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	testApp(t).Router.ServeHTTP(w, req)
+	return w
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// Kullanıcıyı kaydedip giriş yapar, access token ve kullanıcıyı döner
+func apiUser(t *testing.T, username string, roles ...string) (string, database.User) {
+	t.Helper()
+	app := testApp(t)
+	w := call(t, "POST", "/api/user/register", nil, map[string]string{
+		"username": username, "email": username + "@example.com", "name": username, "password": "secret-password",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("register %s: %d %s", username, w.Code, w.Body)
+	}
+	user, _ := app.Database.Users.FindByUsername(ctx, username)
+	if len(roles) > 0 {
+		if _, err := app.Database.Roles.SetRoles(ctx, user.ID, roles); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	}
+	w = call(t, "POST", "/api/user/login", nil, map[string]string{"username": username, "password": "secret-password"})
+	var token controllers.TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &token); err != nil || token.Token == "" {
+		t.Fatalf("login %s: %d %s", username, w.Code, w.Body)
+	}
+	return token.Token, user
+}
+
+func apiProject(t *testing.T, title string, image string) database.Project {
+	t.Helper()
+	project, err := testApp(t).Database.Projects.Add(ctx, database.Project{
+		Title:    title,
+		Synopsis: strings.Repeat("a synopsis long enough for validation ", 2),
+		Author:   "Someone",
+		Status:   "ongoing",
+		Image:    image,
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	return project
+}
+
+// Handler testlerinden ilk bu çalışmalı, site geneli feed boşken başlar
+func TestFeedConditionalRequests(t *testing.T) {
+	app := testApp(t)
+	later := http.Header{"If-Modified-Since": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}
+	w := call(t, "GET", "/feeds/latest.atom", later, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Last-Modified") != "" {
+		t.Fatalf("Want empty feed served without Last-Modified, got %d %q", w.Code, w.Header().Get("Last-Modified"))
+	}
+
+	project := apiProject(t, "Feed Project", "")
+	tomorrow := time.Now().Add(24 * time.Hour)
+	for _, chapter := range []database.Chapter{
+		{Title: "Out Now", Content: strings.Repeat("a chapter that is already out ", 3), ProjectID: project.ID},
+		{Title: "Out Tomorrow", Content: strings.Repeat("a chapter that comes out tomorrow ", 3), ProjectID: project.ID,
+			Status: database.ChapterPublished, PublishAt: &tomorrow},
+	} {
+		if _, err := app.Database.Chapters.Add(ctx, chapter); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	}
+	for _, path := range []string{"/feeds/latest.atom", "/feeds/latest.rss", "/feeds/project/" + project.Slug + ".atom"} {
+		w := call(t, "GET", path, nil, nil)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Out Now") || strings.Contains(w.Body.String(), "Out Tomorrow") {
+			t.Errorf("%s: want only the released chapter, got %d %s", path, w.Code, w.Body)
+			continue
+		}
+		etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+		if etag == "" || modified == "" {
+			t.Errorf("%s: want ETag and Last-Modified, got %q and %q", path, etag, modified)
+		}
+		for _, c := range []struct {
+			header http.Header
+			want   int
+		}{
+			{http.Header{"If-None-Match": {`"stale", ` + etag}}, http.StatusNotModified},
+			{http.Header{"If-None-Match": {"W/" + etag}}, http.StatusNotModified},
+			{http.Header{"If-Modified-Since": {modified}}, http.StatusNotModified},
+			{http.Header{"If-Modified-Since": {time.Now().Add(-48 * time.Hour).UTC().Format(http.TimeFormat)}}, http.StatusOK},
+			// If-None-Match varsa If-Modified-Since'a bakılmaz
+			{http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {modified}}, http.StatusOK},
+		} {
+			if w := call(t, "GET", path, c.header, nil); w.Code != c.want {
+				t.Errorf("%s with %v: want %d, got %d", path, c.header, c.want, w.Code)
+			}
+		}
+	}
+}
+
+func TestExportCoverStaysOffPrivateNetwork(t *testing.T) {
+	var hits int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	}))
+	defer internal.Close()
+	project := apiProject(t, "Cover Probe", internal.URL+"/cover.png")
+
+	w := call(t, "GET", "/api/project/"+project.Slug+"/export.epub", nil, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/epub+zip" {
+		t.Fatalf("Want epub export, got %d %s", w.Code, w.Body)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Errorf("Want no request to loopback cover host, got %d", hits)
+	}
+}
+
+// Site genelinde editor rolü olan biri ekibinde olmadığı projeye dokunamaz
+func TestProjectPermissionsNeedMembership(t *testing.T) {
+	ownerToken, _ := apiUser(t, "project-owner", database.RoleEditor)
+	staffToken, _ := apiUser(t, "outside-editor", database.RoleEditor)
+	adminToken, _ := apiUser(t, "site-admin", database.RoleAdmin)
+	w := call(t, "POST", "/api/project/", bearer(ownerToken), map[string]string{
+		"title": "Owned Project", "synopsis": strings.Repeat("a project with an owner ", 3), "author": "Someone", "status": "ongoing",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Want project created, got %d %s", w.Code, w.Body)
+	}
+	var project database.Project
+	json.Unmarshal(w.Body.Bytes(), &project)
+	update := map[string]string{"title": "Taken Over", "synopsis": project.Synopsis, "author": "Someone", "status": "ongoing"}
+	chapter := map[string]string{"title": "Intruder", "content": strings.Repeat("a chapter nobody asked for ", 4)}
+
+	if w := call(t, "PATCH", "/api/project/"+project.Slug, bearer(staffToken), update); w.Code != http.StatusForbidden {
+		t.Errorf("Want 403 for a site editor editing someone else's project, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "POST", "/api/project/"+project.Slug+"/chapters", bearer(staffToken), chapter); w.Code != http.StatusForbidden {
+		t.Errorf("Want 403 for a site editor adding chapters, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "DELETE", "/api/project/"+project.Slug, bearer(staffToken), nil); w.Code != http.StatusForbidden {
+		t.Errorf("Want 403 for a site editor deleting the project, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "PATCH", "/api/project/"+project.Slug, bearer(adminToken), update); w.Code != http.StatusOK {
+		t.Errorf("Want admins to edit every project, got %d %s", w.Code, w.Body)
+	}
+}
+
+// Her girişte yeni challenge alınsa da yanlış kodlar kullanıcı başına sayılır
+func TestTwoFactorAttemptsAcrossChallenges(t *testing.T) {
+	app := testApp(t)
+	_, user := apiUser(t, "two-factor-guesser")
+	secret, _ := authentication.NewTOTPSecret()
+	if err := app.Database.TwoFactor.Setup(ctx, user.ID, secret); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := app.Database.TwoFactor.Enable(ctx, user.ID, 0, []string{}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	login := func() *httptest.ResponseRecorder {
+		return call(t, "POST", "/api/user/login", nil, map[string]string{"username": user.Username, "password": "secret-password"})
+	}
+	challenge := func(w *httptest.ResponseRecorder) string {
+		t.Helper()
+		var response controllers.TwoFactorChallengeResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.ChallengeToken == "" {
+			t.Fatalf("Want a new challenge, got %d %s", w.Code, w.Body)
+		}
+		return response.ChallengeToken
+	}
+	spare := challenge(login())
+
+	// Kilit, şifre adımında ya da kod adımında görülebilir
+	locked := 0
+	for i := 1; i <= 10 && locked == 0; i++ {
+		w := login()
+		if w.Code == http.StatusTooManyRequests {
+			locked = i
+			break
+		}
+		w = call(t, "POST", "/api/user/login/2fa", nil, map[string]string{"challenge_token": challenge(w), "code": "not-a-code"})
+		if w.Code == http.StatusTooManyRequests {
+			locked = i
+		} else if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Want 401 for a wrong code, got %d %s", w.Code, w.Body)
+		}
+	}
+	if locked == 0 || locked > 7 {
+		t.Fatalf("Want wrong codes on fresh challenges locked after a few attempts, locked at %d", locked)
+	}
+	code, _ := authentication.TOTPCode(secret, time.Now())
+	if w := call(t, "POST", "/api/user/login/2fa", nil, map[string]string{"challenge_token": spare, "code": code}); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Want even the right code refused while locked, got %d %s", w.Code, w.Body)
+	}
+}
+
+// Oturumla yeni bir API anahtarı açar
+func apiKey(t *testing.T, token string, scopes ...string) http.Header {
+	t.Helper()
+	w := call(t, "POST", "/api/user/me/api-keys", bearer(token), map[string]interface{}{"name": "script", "scopes": scopes})
+	var key controllers.APIKeyCreatedResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &key); err != nil || key.Key == "" {
+		t.Fatalf("create api key: %d %s", w.Code, w.Body)
+	}
+	return http.Header{"Authorization": {"ApiKey " + key.Key}}
+}
+
+func TestAPIKeyWritesNeedScope(t *testing.T) {
+	token, _ := apiUser(t, "key-owner", database.RoleEditor)
+	w := call(t, "POST", "/api/project/", bearer(token), map[string]string{
+		"title": "Scripted Project", "synopsis": strings.Repeat("a project edited by a script ", 3), "author": "Someone", "status": "ongoing",
+	})
+	var project database.Project
+	if err := json.Unmarshal(w.Body.Bytes(), &project); err != nil || project.Slug == "" {
+		t.Fatalf("Want project created, got %d %s", w.Code, w.Body)
+	}
+	key := apiKey(t, token, database.PermissionProjectEdit)
+	update := map[string]string{"title": project.Title, "synopsis": project.Synopsis, "author": "Script", "status": "ongoing"}
+
+	if w := call(t, "PATCH", "/api/project/"+project.Slug, key, update); w.Code != http.StatusOK {
+		t.Errorf("Want a scoped route to accept the key, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "GET", "/api/user/me/library", key, nil); w.Code != http.StatusOK {
+		t.Errorf("Want reads allowed with a key, got %d %s", w.Code, w.Body)
+	}
+	denied := []struct {
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"POST", "/api/project/" + project.Slug + "/comments", map[string]string{"content": "posted by a script"}},
+		{"PUT", "/api/project/" + project.Slug + "/review", map[string]interface{}{"score": 5, "content": "reviewed by a script"}},
+		{"PUT", "/api/user/me/library/" + project.Slug, map[string]string{"status": "reading"}},
+		{"POST", "/api/user/me/notifications/read-all", nil},
+		{"POST", "/api/user/me/invitations/some-id/accept", nil},
+		{"DELETE", "/api/comment/some-id", nil},
+	}
+	for _, route := range denied {
+		if w := call(t, route.method, route.path, key, route.body); w.Code != http.StatusForbidden {
+			t.Errorf("Want %s %s denied without a scope, got %d %s", route.method, route.path, w.Code, w.Body)
+		}
+	}
+	moderator := apiKey(t, token, database.PermissionCommentModerate)
+	if w := call(t, "DELETE", "/api/comment/some-id", moderator, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Want comment.moderate to open comment deletion, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "POST", "/api/user/me/invitations/some-id/accept", bearer(token), nil); w.Code == http.StatusForbidden {
+		t.Errorf("Want invitations still usable with a session, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAccountResetsRevokeAPIKeys(t *testing.T) {
+	app := testApp(t)
+	token, user := apiUser(t, "reset-key-owner")
+	key := apiKey(t, token)
+	user, _ = app.Database.Users.Find(ctx, user.ID)
+	reset, id, err := authentication.NewSignedToken([]byte(app.Secret), database.TokenResetPassword, user.Password)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := app.Database.Tokens.Create(ctx, database.UserToken{ID: id, UserID: user.ID, Purpose: database.TokenResetPassword, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if w := call(t, "POST", "/api/user/password/reset", nil, map[string]string{"token": reset, "password": "another-password"}); w.Code != http.StatusOK {
+		t.Fatalf("Want password reset, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "GET", "/api/user/me/library", key, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Want api key revoked by password reset, got %d %s", w.Code, w.Body)
+	}
+
+	adminToken, _ := apiUser(t, "reset-admin", database.RoleAdmin)
+	token, user = apiUser(t, "lost-phone")
+	key = apiKey(t, token)
+	if err := app.Database.TwoFactor.Setup(ctx, user.ID, "SECRET"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := app.Database.TwoFactor.Enable(ctx, user.ID, 0, []string{}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if w := call(t, "DELETE", "/api/admin/users/lost-phone/2fa", bearer(adminToken), nil); w.Code != http.StatusOK {
+		t.Fatalf("Want two-factor reset, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "GET", "/api/user/me/library", key, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Want api key revoked by two-factor reset, got %d %s", w.Code, w.Body)
+	}
+}
+
+// Şifre adımı sayacı sıfırlamaz, yanlış kodlar da hesaba ve adrese sayılır
+func TestLoginLockCoversTwoFactor(t *testing.T) {
+	app := testApp(t)
+	app.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	defer func() { app.TrustedProxies = nil }()
+	from := http.Header{"X-Forwarded-For": {"203.0.113.50, 198.51.100.7"}}
+	_, user := apiUser(t, "half-logged-in")
+	if err := app.Database.TwoFactor.Setup(ctx, user.ID, "SECRET"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := app.Database.TwoFactor.Enable(ctx, user.ID, 0, []string{}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	wrong := map[string]string{"username": user.Username, "password": "wrong-password"}
+	right := map[string]string{"username": user.Username, "password": "secret-password"}
+
+	for i := 0; i < 4; i++ {
+		if w := call(t, "POST", "/api/user/login", from, wrong); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Want 401 for a wrong password, got %d %s", w.Code, w.Body)
+		}
+	}
+	w := call(t, "POST", "/api/user/login", from, right)
+	var challenge controllers.TwoFactorChallengeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &challenge); err != nil || challenge.ChallengeToken == "" {
+		t.Fatalf("Want a challenge, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "POST", "/api/user/login/2fa", from, map[string]string{"challenge_token": challenge.ChallengeToken, "code": "not-a-code"}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Want 401 for a wrong code, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "POST", "/api/user/login", from, wrong); w.Code != http.StatusUnauthorized || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Want the sixth failure to lock the account, got %d %v", w.Code, w.Header())
+	}
+	if w := call(t, "POST", "/api/user/login", from, right); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Want the account locked after the password step, got %d %s", w.Code, w.Body)
+	}
+
+	var lock database.LoginLock
+	if err := app.Database.DB.Where("lock_key = ?", "ip:198.51.100.7").First(&lock).Error; err != nil || lock.Failures != 6 {
+		t.Errorf("Want six failures counted for the forwarded address, got %+v, %v", lock, err)
+	}
+	events, err := app.Database.Audit.List(ctx, database.AuditFilter{Action: database.AuditTwoFactorFailed, UserID: user.ID}, 10, 0)
+	if err != nil || len(events) != 1 || events[0].IP != "198.51.100.7" {
+		t.Errorf("Want the wrong code audited from the forwarded address, got %+v, %v", events, err)
+	}
+
+	// Proxy tanımlı değilse başlık yok sayılır
+	app.TrustedProxies = nil
+	call(t, "POST", "/api/user/login", from, map[string]string{"username": "nobody-here", "password": "wrong-password"})
+	events, _ = app.Database.Audit.List(ctx, database.AuditFilter{Action: database.AuditLoginFailed, Subjects: []string{"nobody-here"}}, 10, 0)
+	if len(events) != 1 || events[0].IP != "192.0.2.1" {
+		t.Errorf("Want X-Forwarded-For ignored without trusted proxies, got %+v", events)
+	}
+}
+
+// Yayın zamanı ileride olan yayınlanmış bölüm planlanmış sayılır ve okuyucuya gösterilmez
+func TestFuturePublishedChapterWaitsForRelease(t *testing.T) {
+	token, _ := apiUser(t, "early-bird", database.RoleEditor)
+	w := call(t, "POST", "/api/project/", bearer(token), map[string]string{
+		"title": "Tomorrow Project", "synopsis": strings.Repeat("a project released tomorrow ", 3), "author": "Someone", "status": "ongoing",
+	})
+	var project database.Project
+	json.Unmarshal(w.Body.Bytes(), &project)
+	tomorrow := time.Now().Add(24 * time.Hour)
+
+	w = call(t, "POST", "/api/project/"+project.Slug+"/chapters", bearer(token), map[string]interface{}{
+		"title": "Not Yet", "content": strings.Repeat("a chapter that is not out yet ", 4), "status": database.ChapterPublished, "publish_at": tomorrow,
+	})
+	var chapter database.Chapter
+	if err := json.Unmarshal(w.Body.Bytes(), &chapter); err != nil || chapter.Status != database.ChapterScheduled {
+		t.Fatalf("Want a future published chapter scheduled, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "GET", "/api/chapter/"+chapter.Slug, nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Want 404 for a chapter before its release, got %d %s", w.Code, w.Body)
+	}
+
+	w = call(t, "POST", "/api/project/"+project.Slug+"/chapters", bearer(token), map[string]interface{}{
+		"title": "Out Now", "content": strings.Repeat("a chapter that is already out ", 4), "status": database.ChapterPublished,
+	})
+	json.Unmarshal(w.Body.Bytes(), &chapter)
+	w = call(t, "PATCH", "/api/chapter/"+chapter.Slug, bearer(token), map[string]interface{}{"publish_at": tomorrow})
+	if err := json.Unmarshal(w.Body.Bytes(), &chapter); err != nil || chapter.Status != database.ChapterScheduled {
+		t.Fatalf("Want moving publish_at ahead to schedule the chapter, got %d %s", w.Code, w.Body)
+	}
+	if w := call(t, "GET", "/api/chapter/"+chapter.Slug, nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("Want 404 for a chapter moved past now, got %d %s", w.Code, w.Body)
+	}
+}
+
+// Hesap ayarlarındaki iki adımlı doğrulama kodları da girişteki kilide sayılır
+func TestTwoFactorAccountRoutesCountFailures(t *testing.T) {
+	app := testApp(t)
+	app.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")}
+	defer func() { app.TrustedProxies = nil }()
+	token, user := apiUser(t, "session-thief")
+	secret, _ := authentication.NewTOTPSecret()
+	if err := app.Database.TwoFactor.Setup(ctx, user.ID, secret); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := app.Database.TwoFactor.Enable(ctx, user.ID, 0, []string{}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	header := bearer(token)
+	header.Set("X-Forwarded-For", "198.51.100.22")
+	code, _ := authentication.TOTPCode(secret, time.Now())
+
+	if w := call(t, "POST", "/api/user/me/2fa/recovery-codes", header, map[string]string{"code": code}); w.Code != http.StatusForbidden {
+		t.Errorf("Want 403 for new recovery codes without the password, got %d %s", w.Code, w.Body)
+	}
+	locked := 0
+	for i := 1; i <= 10 && locked == 0; i++ {
+		w := call(t, "DELETE", "/api/user/me/2fa", header, map[string]string{"password": "secret-password", "code": "not-a-code"})
+		if w.Code == http.StatusTooManyRequests {
+			locked = i
+		} else if w.Code != http.StatusForbidden {
+			t.Fatalf("Want 403 for a wrong code, got %d %s", w.Code, w.Body)
+		}
+	}
+	if locked == 0 || locked > 7 {
+		t.Fatalf("Want wrong codes on account routes locked after a few attempts, locked at %d", locked)
+	}
+	w := call(t, "POST", "/api/user/me/2fa/recovery-codes", header, map[string]string{"password": "secret-password", "code": code})
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Want even the right code refused while locked, got %d %s", w.Code, w.Body)
+	}
+	if enabled, _ := app.Database.TwoFactor.IsEnabled(ctx, user.ID); !enabled {
+		t.Errorf("Want two-factor still enabled")
+	}
+}
+
+// Feed ve katalog linkleri isteğin Host başlığından değil site adresinden kurulur
+func TestFeedLinksUseSiteURL(t *testing.T) {
+	app := testApp(t)
+	spoofed := http.Header{"X-Forwarded-Proto": {"https"}}
+	for _, path := range []string{"/feeds/latest.atom", "/opds/"} {
+		w := call(t, "GET", path, spoofed, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Want %s served, got %d %s", path, w.Code, w.Body)
+		}
+		if !strings.Contains(w.Body.String(), `href="`+app.SiteURL+path+`"`) || strings.Contains(w.Body.String(), "example.com") {
+			t.Errorf("Want %s self link under %s, got %s", path, app.SiteURL, w.Body)
+		}
+	}
+}
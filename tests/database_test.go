@@ -2,12 +2,18 @@ package tests
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/batt0s/batnovels/authentication"
 	"github.com/batt0s/batnovels/database"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -30,6 +36,7 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Println("Could not remove test.db")
 	}
+	os.Remove(apiDatabase)
 	os.Exit(exitVal)
 }
 
@@ -61,3 +68,1516 @@ func TestFindUserByUsername(t *testing.T) {
 	}
 	user.ID = usr.ID
 }
+
+var (
+	project = database.Project{
+		Title:    "Test Project",
+		Synopsis: "A synopsis that is long enough to pass the project validation rules.",
+		Author:   "Tester",
+		Status:   "ongoing",
+		Tags:     "fantasy,action",
+	}
+	chapter = database.Chapter{
+		Title:   "First Chapter",
+		Content: "The content of the first chapter, long enough to pass chapter validation.",
+	}
+)
+
+func TestAddProject(t *testing.T) {
+	var err error
+	project, err = db.Projects.Add(ctx, project, "")
+	if err != nil {
+		t.Errorf("[ERROR] -> %v", err)
+	}
+	chapter.ProjectID = project.ID
+	chapter, err = db.Chapters.Add(ctx, chapter)
+	if err != nil {
+		t.Errorf("[ERROR] -> %v", err)
+	}
+}
+
+func TestCommentThreads(t *testing.T) {
+	root, err := db.Comments.Add(ctx, database.Comment{
+		Content:   "root",
+		UserID:    user.ID,
+		ProjectID: &project.ID,
+		ChapterID: &chapter.ID,
+	})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	reply, err := db.Comments.Add(ctx, database.Comment{Content: "reply", UserID: user.ID, ParentID: &root.ID})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if reply.ChapterID == nil || *reply.ChapterID != chapter.ID {
+		t.Errorf("Want reply on chapter %s, got %v", chapter.ID, reply.ChapterID)
+	}
+	nested, err := db.Comments.Add(ctx, database.Comment{Content: "nested", UserID: user.ID, ParentID: &reply.ID})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if nested.RootID == nil || *nested.RootID != root.ID {
+		t.Errorf("Want root %s, got %v", root.ID, nested.RootID)
+	}
+
+	if err := db.Comments.Delete(ctx, root); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	comments, err := db.Comments.ListByChapter(ctx, chapter.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(comments) != 3 {
+		t.Fatalf("Want 3 comments, got %d", len(comments))
+	}
+	if !comments[0].IsDeleted || comments[0].Content != "" {
+		t.Errorf("Want deleted root to be redacted, got %+v", comments[0])
+	}
+	count, err := db.Comments.CountByChapter(ctx, chapter.ID)
+	if err != nil {
+		t.Errorf("[ERROR] -> %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Want 2, got %d", count)
+	}
+
+	// Cevapsız silinmiş yeni kök ilk sayfayı boş bırakmamalı
+	lonely, _ := db.Comments.Add(ctx, database.Comment{Content: "lonely", UserID: user.ID, ProjectID: &project.ID, ChapterID: &chapter.ID})
+	if err := db.Comments.Delete(ctx, lonely); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	comments, _ = db.Comments.ListByChapter(ctx, chapter.ID, 1, 0)
+	if len(comments) != 3 || comments[0].ID != root.ID {
+		t.Errorf("Want full first page with thread %s, got %+v", root.ID, comments)
+	}
+
+	// Cevapları da silinmiş kök boş bir thread olarak görünmemeli
+	abandoned, _ := db.Comments.Add(ctx, database.Comment{Content: "abandoned", UserID: user.ID, ProjectID: &project.ID, ChapterID: &chapter.ID})
+	gone, _ := db.Comments.Add(ctx, database.Comment{Content: "gone", UserID: user.ID, ParentID: &abandoned.ID})
+	if err := db.Comments.Delete(ctx, gone); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Comments.Delete(ctx, abandoned); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	comments, _ = db.Comments.ListByChapter(ctx, chapter.ID, 1, 0)
+	if len(comments) != 3 || comments[0].ID != root.ID {
+		t.Errorf("Want thread with only deleted replies dropped, got %+v", comments)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	results, err := db.Search.Search(ctx, "first chap", 10, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(results) == 0 || results[0].Slug != chapter.Slug {
+		t.Fatalf("Want %s as top result, got %+v", chapter.Slug, results)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>") {
+		t.Errorf("Want highlighted snippet, got %q", results[0].Snippet)
+	}
+
+	chapter.Content = "Rewritten content about dragons, long enough to pass chapter validation."
+	chapter, err = db.Chapters.Update(ctx, chapter)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	results, _ = db.Search.Search(ctx, "dragons", 10, 0)
+	if len(results) != 1 {
+		t.Errorf("Want 1 result after update, got %d", len(results))
+	}
+
+	extra, err := db.Chapters.Add(ctx, database.Chapter{
+		Title:     "Dragon Interlude",
+		Content:   "A short side story that only exists to be deleted in the search test.",
+		ProjectID: project.ID,
+	})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Chapters.Delete(ctx, extra); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	results, _ = db.Search.Search(ctx, "interlude", 10, 0)
+	if len(results) != 0 {
+		t.Errorf("Want deleted chapter out of the index, got %+v", results)
+	}
+
+	// Sıralama sorguda yapılmalı, ikinci sayfa ilkinin devamı olur
+	lanterns, err := db.Projects.Add(ctx, database.Project{
+		Title:    "Search Order Project",
+		Synopsis: strings.Repeat("a project that only holds search ranking chapters ", 2),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	for _, c := range []database.Chapter{
+		{Title: "Quiet Night", Content: "Someone carries a lantern through the village while everyone sleeps.", ProjectID: lanterns.ID},
+		{Title: "The Lantern", Content: "A chapter named after the old lantern that hangs above the inn door.", ProjectID: lanterns.ID},
+	} {
+		if _, err := db.Chapters.Add(ctx, c); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	}
+	first, _ := db.Search.Search(ctx, "lantern", 1, 0)
+	second, _ := db.Search.Search(ctx, "lantern", 1, 1)
+	if len(first) != 1 || len(second) != 1 || first[0].Title != "The Lantern" || first[0].Rank < second[0].Rank {
+		t.Errorf("Want title match ranked first across pages, got %+v and %+v", first, second)
+	}
+}
+
+func TestAddProjectWithChapters(t *testing.T) {
+	imported := database.Project{
+		Title:    project.Title,
+		Synopsis: project.Synopsis,
+		Author:   "Importer",
+		Status:   "ongoing",
+	}
+	chapters := []database.Chapter{
+		{Title: chapter.Title, Content: strings.Repeat("first imported chapter ", 4)},
+		{Title: chapter.Title, Content: strings.Repeat("second imported chapter ", 4)},
+	}
+	imported, added, err := db.Projects.AddWithChapters(ctx, imported, chapters, user.ID)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if owner, err := db.Teams.Member(ctx, imported.ID, user.ID); err != nil || owner.Role != database.TeamOwner {
+		t.Errorf("Want the importer added as owner, got %+v, %v", owner, err)
+	}
+	if imported.Slug == project.Slug {
+		t.Errorf("Want a unique project slug, got %s twice", imported.Slug)
+	}
+	if len(added) != 2 || added[0].Slug == chapter.Slug || added[0].Slug == added[1].Slug {
+		t.Errorf("Want unique chapter slugs, got %+v", added)
+	}
+
+	var memberships int64
+	db.DB.Model(&database.ProjectMember{}).Where("user_id = ?", user.ID).Count(&memberships)
+	chapters = append(chapters, database.Chapter{Title: "Too short", Content: "short"})
+	_, _, err = db.Projects.AddWithChapters(ctx, database.Project{
+		Title:    "Rolled Back",
+		Synopsis: project.Synopsis,
+	}, chapters, user.ID)
+	if !errors.Is(err, database.ErrorInvalidChapter) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidChapter, err)
+	}
+	if _, err := db.Projects.FindBySlug(ctx, "rolled-back"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want import to be rolled back, got %v", err)
+	}
+	var after int64
+	db.DB.Model(&database.ProjectMember{}).Where("user_id = ?", user.ID).Count(&after)
+	if after != memberships {
+		t.Errorf("Want the owner rolled back with the import, got %d memberships, had %d", after, memberships)
+	}
+}
+
+func TestChapterRevisions(t *testing.T) {
+	original := chapter.Content
+	chapter.Content = original + "\nA second paragraph added after publication."
+	revised, err := db.Chapters.Revise(ctx, chapter, &user.ID, "add paragraph")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Chapters.Revise(ctx, revised, &user.ID, "no change"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	revisions, err := db.Revisions.List(ctx, chapter.ID, 10, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	// TestSearch'teki Update de revizyon olarak kaydedilmiştir
+	if len(revisions) != 3 {
+		t.Fatalf("Want baseline, update and one edit, got %d revisions", len(revisions))
+	}
+	if revisions[0].Number != 3 || revisions[0].Note != "add paragraph" || revisions[0].Editor.Username != user.Username {
+		t.Errorf("Unexpected latest revision %+v", revisions[0])
+	}
+	if revisions[1].Content != original || revisions[1].EditorID != nil {
+		t.Errorf("Want update with original content, got %+v", revisions[1])
+	}
+	chapter = revised
+}
+
+func TestPublishDue(t *testing.T) {
+	publishAt := time.Now().Add(-time.Minute)
+	scheduled, err := db.Chapters.Add(ctx, database.Chapter{
+		Title:     "Scheduled Chapter",
+		Content:   strings.Repeat("scheduled chapter content ", 4),
+		Status:    database.ChapterScheduled,
+		PublishAt: &publishAt,
+		ProjectID: project.ID,
+	})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	chapters, _ := db.Chapters.ListPublishedBySlug(ctx, project.Slug, 100, 0, "created_at asc")
+	for _, c := range chapters {
+		if c.ID == scheduled.ID {
+			t.Fatalf("Want scheduled chapter hidden before publishing")
+		}
+	}
+	published, err := db.Chapters.PublishDue(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(published) != 1 || published[0].ID != scheduled.ID {
+		t.Fatalf("Want %s published, got %+v", scheduled.Slug, published)
+	}
+	chapters, _ = db.Chapters.ListPublishedBySlug(ctx, project.Slug, 100, 0, "created_at asc")
+	if chapters[len(chapters)-1].ID != scheduled.ID {
+		t.Errorf("Want scheduled chapter listed after publishing")
+	}
+}
+
+// Yayın zamanı ileride olan bölümler okuyucuya açık listelere ve aramaya LIMIT'ten önce girmez
+func TestUnreleasedChapters(t *testing.T) {
+	release, err := db.Projects.Add(ctx, database.Project{Title: "Release Window", Synopsis: strings.Repeat("a project with a future chapter ", 3), Author: "Someone", Status: "ongoing"}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	defer db.Projects.Delete(ctx, release)
+	released, err := db.Chapters.Add(ctx, database.Chapter{Title: "Released Chapter", Content: strings.Repeat("zeppelin over the harbour ", 4), ProjectID: release.ID})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	tomorrow := time.Now().Add(24 * time.Hour)
+	if _, err := db.Chapters.Add(ctx, database.Chapter{Title: "Tomorrow Chapter", Content: strings.Repeat("zeppelin over the mountains ", 4),
+		Status: database.ChapterPublished, PublishAt: &tomorrow, ProjectID: release.ID}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	latest, err := db.Chapters.ListLatestPublished(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(latest) != 1 || latest[0].ID != released.ID {
+		t.Errorf("Want %s as latest, got %+v", released.Slug, latest)
+	}
+	chapters, _ := db.Chapters.ListPublishedBySlug(ctx, release.Slug, 100, 0, "created_at asc")
+	if len(chapters) != 1 || chapters[0].ID != released.ID {
+		t.Errorf("Want only %s listed, got %+v", released.Slug, chapters)
+	}
+	results, err := db.Search.Search(ctx, "mountains", 10, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Want future chapter hidden from search, got %+v", results)
+	}
+	results, _ = db.Search.Search(ctx, "zeppelin", 1, 0)
+	if len(results) != 1 || results[0].ID != released.ID {
+		t.Errorf("Want %s on the first page, got %+v", released.Slug, results)
+	}
+}
+
+func chapterSlugs(t *testing.T) []string {
+	chapters, err := db.Chapters.ListBySlug(ctx, project.Slug, -1, 0, database.ChapterReadingOrder)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	var slugs []string
+	for _, c := range chapters {
+		slugs = append(slugs, c.Slug)
+	}
+	return slugs
+}
+
+func TestChapterOrder(t *testing.T) {
+	side, err := db.Chapters.Add(ctx, database.Chapter{
+		Title:     "Side Story",
+		Content:   strings.Repeat("side story content ", 4),
+		Number:    1.5,
+		ProjectID: project.ID,
+	})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	want := chapter.Slug + ",side-story,scheduled-chapter"
+	if got := strings.Join(chapterSlugs(t), ","); got != want {
+		t.Fatalf("Want %s, got %s", want, got)
+	}
+
+	first, _ := db.Chapters.FindBySlug(ctx, chapter.Slug)
+	scheduled, _ := db.Chapters.FindBySlug(ctx, "scheduled-chapter")
+	if _, err := db.Chapters.Move(ctx, first, scheduled.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	want = "side-story,scheduled-chapter," + chapter.Slug
+	if got := strings.Join(chapterSlugs(t), ","); got != want {
+		t.Errorf("Want %s, got %s", want, got)
+	}
+
+	if err := db.Chapters.Reorder(ctx, project.ID, []string{first.ID, side.ID}); !errors.Is(err, database.ErrorInvalidOrder) {
+		t.Errorf("Want %v for partial order, got %v", database.ErrorInvalidOrder, err)
+	}
+	if err := db.Chapters.Reorder(ctx, project.ID, []string{first.ID, side.ID, scheduled.ID}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	want = chapter.Slug + ",side-story,scheduled-chapter"
+	if got := strings.Join(chapterSlugs(t), ","); got != want {
+		t.Errorf("Want %s, got %s", want, got)
+	}
+}
+
+// En başa taşınan bölümler pozitif anahtar alır ve yeniden başlatınca sıra değişmez
+func TestChapterOrderSurvivesRestart(t *testing.T) {
+	ordered, chapters, err := db.Projects.AddWithChapters(ctx, database.Project{
+		Title:    "Restart Order",
+		Synopsis: strings.Repeat("a project that gets reordered ", 3),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, []database.Chapter{
+		{Title: "Part One", Content: strings.Repeat("part one content ", 5)},
+		{Title: "Part Two", Content: strings.Repeat("part two content ", 5)},
+		{Title: "Part Three", Content: strings.Repeat("part three content ", 5)},
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	defer db.Projects.Delete(ctx, ordered)
+	for _, moved := range []database.Chapter{chapters[2], chapters[1]} {
+		moved, err = db.Chapters.Move(ctx, moved, "")
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		if moved.SortKey <= 0 {
+			t.Errorf("Want a positive sort key, got %v", moved.SortKey)
+		}
+	}
+	slugs := func(db *database.Database) string {
+		list, err := db.Chapters.ListBySlug(ctx, ordered.Slug, -1, 0, database.ChapterReadingOrder)
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		var slugs []string
+		for _, c := range list {
+			slugs = append(slugs, c.Slug)
+		}
+		return strings.Join(slugs, ",")
+	}
+	want := "part-two,part-three,part-one"
+	if got := slugs(db); got != want {
+		t.Fatalf("Want %s, got %s", want, got)
+	}
+	restarted, err := database.New("sqlite", "test.db", &gorm.Config{})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if got := slugs(restarted); got != want {
+		t.Errorf("Want %s after restart, got %s", want, got)
+	}
+}
+
+func TestChapterNavigation(t *testing.T) {
+	side, _ := db.Chapters.FindBySlug(ctx, "side-story")
+	navigation, err := db.Chapters.Navigation(ctx, side)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if navigation.Position != 2 || navigation.Total != 3 {
+		t.Errorf("Want position 2 of 3, got %d of %d", navigation.Position, navigation.Total)
+	}
+	if navigation.Previous == nil || navigation.Previous.Slug != chapter.Slug {
+		t.Errorf("Want previous %s, got %+v", chapter.Slug, navigation.Previous)
+	}
+	if navigation.Next == nil || navigation.Next.Slug != "scheduled-chapter" {
+		t.Errorf("Want next scheduled-chapter, got %+v", navigation.Next)
+	}
+
+	// Taslaklar komşu olarak görünmez
+	side.Status = database.ChapterDraft
+	if _, err := db.Chapters.Update(ctx, side); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	first, _ := db.Chapters.FindBySlug(ctx, chapter.Slug)
+	navigation, err = db.Chapters.Navigation(ctx, first)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if navigation.Previous != nil || navigation.Next == nil || navigation.Next.Slug != "scheduled-chapter" || navigation.Total != 2 {
+		t.Errorf("Want draft skipped, got %+v", navigation)
+	}
+	side.Status = database.ChapterPublished
+	db.Chapters.Update(ctx, side)
+}
+
+func TestReadingProgress(t *testing.T) {
+	side, _ := db.Chapters.FindBySlug(ctx, "side-story")
+	for _, p := range []database.ReadingProgress{
+		{UserID: user.ID, ChapterID: chapter.ID, ProjectID: project.ID, Position: 1},
+		{UserID: user.ID, ChapterID: side.ID, ProjectID: project.ID, Position: 0.25},
+	} {
+		if _, err := db.Progress.Save(ctx, p); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	saved, err := db.Progress.Save(ctx, database.ReadingProgress{UserID: user.ID, ChapterID: side.ID, ProjectID: project.ID, Position: 0.5})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if saved.Position != 0.5 {
+		t.Errorf("Want position 0.5, got %v", saved.Position)
+	}
+	if _, err := db.Progress.Save(ctx, database.ReadingProgress{UserID: user.ID, ChapterID: side.ID, ProjectID: project.ID, Position: 2}); !errors.Is(err, database.ErrorInvalidProgress) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidProgress, err)
+	}
+
+	latest, err := db.Progress.ContinueReading(ctx, user.ID, 10)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(latest) != 1 || latest[0].ChapterID != side.ID || latest[0].Chapter.Slug != side.Slug {
+		t.Errorf("Want continue reading at %s, got %+v", side.Slug, latest)
+	}
+	history, _ := db.Progress.History(ctx, user.ID, 10, 0)
+	if len(history) != 2 {
+		t.Errorf("Want 2 history entries, got %d", len(history))
+	}
+	// Taslağa alınan bölüm kalınan yer olarak gösterilmez
+	side.Status = database.ChapterDraft
+	db.Chapters.Update(ctx, side)
+	found, err := db.Progress.Find(ctx, user.ID, project.ID)
+	if err != nil || found.ChapterID != chapter.ID {
+		t.Errorf("Want progress at %s, got %s: %v", chapter.Slug, found.ChapterID, err)
+	}
+	side.Status = database.ChapterPublished
+	db.Chapters.Update(ctx, side)
+
+	if err := db.Progress.Clear(ctx, user.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Progress.Find(ctx, user.ID, project.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want history cleared, got %v", err)
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	entry, err := db.Library.Follow(ctx, database.LibraryEntry{UserID: user.ID, ProjectID: project.ID})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if entry.Status != database.ShelfReading || entry.Unread != 3 {
+		t.Errorf("Want reading with 3 unread, got %s with %d", entry.Status, entry.Unread)
+	}
+	if _, err := db.Library.Follow(ctx, database.LibraryEntry{UserID: user.ID, ProjectID: project.ID, Status: "someday"}); !errors.Is(err, database.ErrorInvalidLibraryEntry) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidLibraryEntry, err)
+	}
+
+	side, _ := db.Chapters.FindBySlug(ctx, "side-story")
+	first, _ := db.Chapters.FindBySlug(ctx, chapter.Slug)
+	db.Library.MarkOpened(ctx, user.ID, side)
+	// Geri gitmek okunmamış sayısını değiştirmez
+	db.Library.MarkOpened(ctx, user.ID, first)
+	entry, _ = db.Library.Find(ctx, user.ID, project.ID)
+	if entry.Unread != 1 {
+		t.Errorf("Want 1 unread, got %d", entry.Unread)
+	}
+
+	favourites, err := db.Shelves.Add(ctx, database.Shelf{UserID: user.ID, Name: " Favourites "})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Shelves.Add(ctx, database.Shelf{UserID: user.ID, Name: database.ShelfDropped}); !errors.Is(err, database.ErrorInvalidShelf) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidShelf, err)
+	}
+	entry, err = db.Library.SetShelves(ctx, entry, []string{favourites.ID})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(entry.Shelves) != 1 || entry.Shelves[0].Name != "Favourites" {
+		t.Errorf("Want entry on Favourites, got %+v", entry.Shelves)
+	}
+	entries, _ := db.Library.List(ctx, user.ID, "", favourites.ID, 10, 0)
+	if len(entries) != 1 || entries[0].Unread != 1 {
+		t.Errorf("Want 1 entry with 1 unread on shelf, got %+v", entries)
+	}
+	entries, _ = db.Library.List(ctx, user.ID, database.ShelfCompleted, "", 10, 0)
+	if len(entries) != 0 {
+		t.Errorf("Want no completed entries, got %d", len(entries))
+	}
+
+	if err := db.Shelves.Delete(ctx, favourites); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Library.Unfollow(ctx, user.ID, project.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Library.Find(ctx, user.ID, project.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want entry removed, got %v", err)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	var reviewers []database.User
+	for i := 0; i < 4; i++ {
+		reviewer := database.User{
+			Username: fmt.Sprintf("reviewer%d", i),
+			Email:    fmt.Sprintf("reviewer%d@gmail.com", i),
+			Name:     "Reviewer",
+			Password: "password",
+		}
+		if err := db.Users.Add(ctx, reviewer); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		reviewer, _ = db.Users.FindByUsername(ctx, reviewer.Username)
+		reviewers = append(reviewers, reviewer)
+	}
+	other, err := db.Projects.Add(ctx, database.Project{
+		Title:    "Single Review Project",
+		Synopsis: strings.Repeat("a project with a single perfect review ", 3),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+
+	if _, err := db.Reviews.Save(ctx, database.Review{UserID: user.ID, ProjectID: project.ID, Score: 6}); !errors.Is(err, database.ErrorInvalidReview) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidReview, err)
+	}
+	for i, score := range []int{5, 4, 5, 4} {
+		if _, err := db.Reviews.Save(ctx, database.Review{UserID: reviewers[i].ID, ProjectID: project.ID, Score: score}); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	}
+	// Aynı kullanıcı tekrar yazınca review güncellenir
+	review, err := db.Reviews.Save(ctx, database.Review{UserID: reviewers[3].ID, ProjectID: project.ID, Score: 5, Content: " Great "})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if review.Content != "Great" || review.User.Username != reviewers[3].Username {
+		t.Errorf("Want updated review, got %+v", review)
+	}
+	if _, err := db.Reviews.Save(ctx, database.Review{UserID: reviewers[0].ID, ProjectID: other.ID, Score: 5}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	// Genel ortalamayı düşüren bir proje, yoksa tek 5 puan ortalamanın üstünde kalır
+	low, err := db.Projects.Add(ctx, database.Project{
+		Title:    "Low Review Project",
+		Synopsis: strings.Repeat("a project with a single poor review ", 3),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Reviews.Save(ctx, database.Review{UserID: reviewers[1].ID, ProjectID: low.ID, Score: 1}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	project, _ = db.Projects.Find(ctx, project.ID)
+	if project.RatingCount != 4 || project.RatingAverage != 4.75 {
+		t.Errorf("Want 4 reviews averaging 4.75, got %d averaging %v", project.RatingCount, project.RatingAverage)
+	}
+
+	// Review'i olmayan projeler genel ortalamada kalır: 4 review'li proje, tek 5 puan, puansızlar, tek 1 puan
+	projects, err := db.Projects.ListByRating(ctx, -1, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(projects) < 4 {
+		t.Fatalf("Want every project listed, got %d", len(projects))
+	}
+	if projects[0].ID != project.ID || projects[1].ID != other.ID || projects[len(projects)-1].ID != low.ID {
+		var slugs []string
+		for _, p := range projects {
+			slugs = append(slugs, p.Slug)
+		}
+		t.Errorf("Want %s, %s first and %s last, got %v", project.Slug, other.Slug, low.Slug, slugs)
+	}
+
+	if _, err := db.Reviews.Vote(ctx, review, reviewers[3].ID, true); !errors.Is(err, database.ErrorInvalidVote) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidVote, err)
+	}
+	db.Reviews.Vote(ctx, review, reviewers[0].ID, true)
+	db.Reviews.Vote(ctx, review, reviewers[1].ID, true)
+	review, _ = db.Reviews.Vote(ctx, review, reviewers[1].ID, false)
+	if review.HelpfulCount != 1 || review.UnhelpfulCount != 1 {
+		t.Errorf("Want 1 helpful and 1 unhelpful, got %d and %d", review.HelpfulCount, review.UnhelpfulCount)
+	}
+
+	if err := db.Reviews.Delete(ctx, review); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	project, _ = db.Projects.Find(ctx, project.ID)
+	if project.RatingCount != 3 {
+		t.Errorf("Want 3 reviews after delete, got %d", project.RatingCount)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	reader, _ := db.Users.FindByUsername(ctx, "reviewer0")
+	db.Library.Follow(ctx, database.LibraryEntry{UserID: reader.ID, ProjectID: project.ID})
+	dropped, _ := db.Users.FindByUsername(ctx, "reviewer1")
+	db.Library.Follow(ctx, database.LibraryEntry{UserID: dropped.ID, ProjectID: project.ID, Status: database.ShelfDropped})
+	followers, err := db.Library.Followers(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(followers) != 1 || followers[0] != reader.ID {
+		t.Fatalf("Want only %s following, got %v", reader.Username, followers)
+	}
+
+	if _, err := db.Notifications.FanOut(ctx, database.Notification{Kind: "unknown", Message: "x"}, followers); !errors.Is(err, database.ErrorInvalidNotification) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidNotification, err)
+	}
+	for _, title := range []string{"One", "Two"} {
+		_, err := db.Notifications.FanOut(ctx, database.Notification{
+			Kind:      database.NotificationNewChapter,
+			Message:   "New chapter: " + title,
+			ProjectID: &project.ID,
+			ChapterID: &chapter.ID,
+		}, followers)
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	}
+	notifications, err := db.Notifications.List(ctx, reader.ID, true, 10, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(notifications) != 2 || notifications[0].Chapter == nil || notifications[0].Chapter.Slug != chapter.Slug {
+		t.Fatalf("Want 2 unread notifications linking %s, got %+v", chapter.Slug, notifications)
+	}
+	if err := db.Notifications.MarkRead(ctx, user.ID, notifications[0].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want %v marking someone else's notification, got %v", gorm.ErrRecordNotFound, err)
+	}
+	db.Notifications.MarkRead(ctx, reader.ID, notifications[0].ID)
+	if count, _ := db.Notifications.CountUnread(ctx, reader.ID); count != 1 {
+		t.Errorf("Want 1 unread, got %d", count)
+	}
+	db.Notifications.MarkAllRead(ctx, reader.ID)
+	if count, _ := db.Notifications.CountUnread(ctx, reader.ID); count != 0 {
+		t.Errorf("Want 0 unread, got %d", count)
+	}
+}
+
+func TestProjectCatalog(t *testing.T) {
+	tagged, err := db.Projects.Add(ctx, database.Project{
+		Title:    "Tagged Project",
+		Synopsis: strings.Repeat("a project with unusual tags for the catalog ", 2),
+		Author:   "Tester",
+		Status:   "ongoing",
+		Tags:     "Action, 100%_sure",
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	for tag, want := range map[string]int{"ACTION": 2, "act": 0, "100%": 0, "100%_sure": 1, "100_x_sure": 0} {
+		projects, err := db.Projects.ListByTag(ctx, tag, 10, 0)
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		if len(projects) != want {
+			t.Errorf("Want %d projects tagged %q, got %d", want, tag, len(projects))
+		}
+	}
+	tags, err := db.Projects.Tags(ctx)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	counts := make(map[string]int64)
+	for _, tag := range tags {
+		counts[tag.Tag] = tag.Count
+	}
+	if counts["action"] != 2 || counts["fantasy"] != 1 || counts["100%_sure"] != 1 {
+		t.Errorf("Want lowercased tag counts, got %+v", tags)
+	}
+	authors, err := db.Projects.Authors(ctx)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	found := false
+	for _, author := range authors {
+		if author.Author == tagged.Author {
+			found = author.Count == 2
+		}
+	}
+	if !found {
+		t.Errorf("Want 2 projects by %s, got %+v", tagged.Author, authors)
+	}
+	projects, _ := db.Projects.ListByAuthor(ctx, tagged.Author, 1, 0)
+	if len(projects) != 1 || projects[0].ID != tagged.ID {
+		t.Errorf("Want %s first by title, got %+v", tagged.Title, projects)
+	}
+	latest, err := db.Projects.ListLatest(ctx, 10, 0, false)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	for _, p := range latest {
+		if p.ID == tagged.ID {
+			t.Errorf("Want projects without chapters out of latest, got %s", p.Title)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	now := time.Now()
+	newSession := func(jti string) database.Session {
+		_, hash, err := authentication.NewRefreshToken()
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		return database.Session{
+			UserID:          user.ID,
+			TokenHash:       hash,
+			AccessJTI:       jti,
+			AccessExpiresAt: now.Add(15 * time.Minute),
+			ExpiresAt:       now.Add(time.Hour),
+		}
+	}
+	first, err := db.Sessions.Create(ctx, newSession("jti-1"))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	firstHash := first.TokenHash
+	rotated, err := db.Sessions.Rotate(ctx, firstHash, newSession("jti-2"))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if rotated.ID != first.ID || rotated.AccessJTI != "jti-2" || rotated.TokenHash == firstHash {
+		t.Errorf("Want %s rotated to jti-2, got %+v", first.ID, rotated)
+	}
+	if _, err := db.Sessions.Rotate(ctx, authentication.HashToken("unknown"), newSession("jti-x")); !errors.Is(err, database.ErrorInvalidSession) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidSession, err)
+	}
+
+	// Eski token tekrar gelince oturum kapanır ve son access token iptal edilir
+	if _, err := db.Sessions.Rotate(ctx, firstHash, newSession("jti-3")); !errors.Is(err, database.ErrorSessionReused) {
+		t.Errorf("Want %v, got %v", database.ErrorSessionReused, err)
+	}
+	if revoked, _ := db.Sessions.IsRevoked(ctx, "jti-2"); !revoked {
+		t.Errorf("Want jti-2 revoked after reuse")
+	}
+	if _, err := db.Sessions.Rotate(ctx, rotated.TokenHash, newSession("jti-4")); !errors.Is(err, database.ErrorInvalidSession) {
+		t.Errorf("Want revoked session to stay closed, got %v", err)
+	}
+
+	second, _ := db.Sessions.Create(ctx, newSession("jti-5"))
+	third, _ := db.Sessions.Create(ctx, newSession("jti-6"))
+	if err := db.Sessions.Revoke(ctx, "someone-else", second.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if revoked, _ := db.Sessions.IsRevoked(ctx, "jti-5"); revoked {
+		t.Errorf("Want other users unable to revoke %s", second.ID)
+	}
+	db.Sessions.Revoke(ctx, user.ID, second.ID)
+	if revoked, _ := db.Sessions.IsRevoked(ctx, "jti-5"); !revoked {
+		t.Errorf("Want jti-5 revoked")
+	}
+	if revoked, _ := db.Sessions.IsRevoked(ctx, "jti-6"); revoked {
+		t.Errorf("Want jti-6 still valid")
+	}
+	db.Sessions.RevokeAll(ctx, user.ID)
+	if revoked, _ := db.Sessions.IsRevoked(ctx, third.AccessJTI); !revoked {
+		t.Errorf("Want %s revoked by logout-all", third.AccessJTI)
+	}
+
+	// İki nesil önceki token da bütün aileyi kapatır
+	family, _ := db.Sessions.Create(ctx, newSession("jti-7"))
+	oldest := family.TokenHash
+	family, _ = db.Sessions.Rotate(ctx, family.TokenHash, newSession("jti-8"))
+	family, err = db.Sessions.Rotate(ctx, family.TokenHash, newSession("jti-9"))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Sessions.Rotate(ctx, oldest, newSession("jti-10")); !errors.Is(err, database.ErrorSessionReused) {
+		t.Errorf("Want %v for a token two generations old, got %v", database.ErrorSessionReused, err)
+	}
+	if revoked, _ := db.Sessions.IsRevoked(ctx, "jti-9"); !revoked {
+		t.Errorf("Want jti-9 revoked after reuse")
+	}
+	if _, err := db.Sessions.Rotate(ctx, family.TokenHash, newSession("jti-11")); !errors.Is(err, database.ErrorInvalidSession) {
+		t.Errorf("Want the whole family closed, got %v", err)
+	}
+
+	purged, err := db.Sessions.Purge(ctx, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if purged != 8 {
+		t.Errorf("Want 4 sessions and 4 revoked tokens purged, got %d", purged)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	roles, err := db.Roles.Roles(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(roles) != 1 || roles[0] != database.RoleReader {
+		t.Errorf("Want only reader by default, got %v", roles)
+	}
+	if _, err := db.Roles.SetRoles(ctx, user.ID, []string{"superuser"}); !errors.Is(err, database.ErrorInvalidRole) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidRole, err)
+	}
+	roles, err = db.Roles.SetRoles(ctx, user.ID, []string{database.RoleTranslator, database.RoleAdmin, database.RoleTranslator})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if strings.Join(roles, ",") != "admin,translator,reader" {
+		t.Errorf("Want admin,translator,reader, got %v", roles)
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionUserManage); !ok {
+		t.Errorf("Want admin to have %s", database.PermissionUserManage)
+	}
+	if _, err := db.Roles.SetRoles(ctx, user.ID, []string{database.RoleTranslator}); !errors.Is(err, database.ErrorLastAdmin) {
+		t.Errorf("Want %v, got %v", database.ErrorLastAdmin, err)
+	}
+
+	other, _ := db.Users.FindByUsername(ctx, "reviewer0")
+	db.Roles.SetRoles(ctx, other.ID, []string{database.RoleAdmin})
+	if _, err := db.Roles.SetRoles(ctx, user.ID, []string{database.RoleTranslator}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionChapterPublish); ok {
+		t.Errorf("Want translators unable to publish")
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionChapterCreate); ok {
+		t.Errorf("Want chapter permissions for translators to come from project teams")
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionProjectCreate); !ok {
+		t.Errorf("Want translators able to create projects")
+	}
+}
+
+func TestMigrateStaffFlags(t *testing.T) {
+	defer os.Remove("flags.db")
+	legacy, err := database.New("sqlite", "flags.db", &gorm.Config{})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	staff := database.User{Username: "staffer", Email: "staff@example.com", Name: "Staffer", Password: "password"}
+	if err := legacy.Users.Add(ctx, staff); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	legacy.DB.Exec("ALTER TABLE users ADD COLUMN `is_admin` numeric DEFAULT false")
+	legacy.DB.Exec("ALTER TABLE users ADD COLUMN `is_staff` numeric DEFAULT false")
+	legacy.DB.Exec("UPDATE users SET is_staff = ? WHERE username = ?", true, staff.Username)
+	sqlDB, _ := legacy.DB.DB()
+	sqlDB.Close()
+
+	migrated, err := database.New("sqlite", "flags.db", &gorm.Config{})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	defer func() {
+		sqlDB, _ := migrated.DB.DB()
+		sqlDB.Close()
+	}()
+	if migrated.DB.Migrator().HasColumn(&database.User{}, "is_staff") {
+		t.Errorf("Want is_staff column dropped")
+	}
+	staff, _ = migrated.Users.FindByUsername(ctx, staff.Username)
+	roles, _ := migrated.Roles.Roles(ctx, staff.ID)
+	if strings.Join(roles, ",") != "editor,moderator,reader" {
+		t.Errorf("Want staff migrated to editor and moderator, got %v", roles)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	owner, _ := db.Users.FindByUsername(ctx, "reviewer1")
+	invitee, _ := db.Users.FindByUsername(ctx, "reviewer2")
+	if _, err := db.Teams.AddMember(ctx, database.ProjectMember{ProjectID: project.ID, UserID: owner.ID, Role: database.TeamOwner}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if ok, _ := db.Teams.HasPermission(ctx, project.ID, invitee.ID, database.PermissionChapterCreate); ok {
+		t.Errorf("Want outsiders unable to add chapters")
+	}
+	if _, err := db.Teams.Invite(ctx, database.ProjectInvitation{ProjectID: project.ID, UserID: invitee.ID, InvitedByID: owner.ID, Role: "boss"}); !errors.Is(err, database.ErrorInvalidInvitation) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidInvitation, err)
+	}
+	if _, err := db.Teams.Invite(ctx, database.ProjectInvitation{ProjectID: project.ID, UserID: owner.ID, InvitedByID: invitee.ID, Role: database.TeamEditor}); !errors.Is(err, database.ErrorAlreadyMember) {
+		t.Errorf("Want %v, got %v", database.ErrorAlreadyMember, err)
+	}
+	first, err := db.Teams.Invite(ctx, database.ProjectInvitation{ProjectID: project.ID, UserID: invitee.ID, InvitedByID: owner.ID, Role: database.TeamEditor})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	again, err := db.Teams.Invite(ctx, database.ProjectInvitation{ProjectID: project.ID, UserID: invitee.ID, InvitedByID: owner.ID, Role: database.TeamTranslator})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if again.ID != first.ID || again.Role != database.TeamTranslator || again.Project.Slug != project.Slug {
+		t.Errorf("Want re-invite to update %s, got %+v", first.ID, again)
+	}
+	pending, _ := db.Teams.UserInvitations(ctx, invitee.ID)
+	if len(pending) != 1 {
+		t.Errorf("Want 1 pending invitation, got %d", len(pending))
+	}
+	if _, err := db.Teams.Respond(ctx, owner.ID, again.ID, true); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want only the invitee to respond, got %v", err)
+	}
+	accepted, err := db.Teams.Respond(ctx, invitee.ID, again.ID, true)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if accepted.Status != database.InvitationAccepted || accepted.RespondedAt == nil {
+		t.Errorf("Want accepted invitation, got %+v", accepted)
+	}
+	if _, err := db.Teams.Respond(ctx, invitee.ID, again.ID, false); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want answered invitations closed, got %v", err)
+	}
+	if ok, _ := db.Teams.HasPermission(ctx, project.ID, invitee.ID, database.PermissionChapterCreate); !ok {
+		t.Errorf("Want translators able to add chapters")
+	}
+	if ok, _ := db.Teams.HasPermission(ctx, project.ID, invitee.ID, database.PermissionChapterPublish); ok {
+		t.Errorf("Want translators unable to publish")
+	}
+
+	members, err := db.Teams.Members(ctx, project.ID)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(members) != 2 || members[0].User.Username != owner.Username || members[1].Role != database.TeamTranslator {
+		t.Errorf("Want owner then translator, got %+v", members)
+	}
+	if _, err := db.Teams.SetRole(ctx, project.ID, owner.ID, database.TeamEditor); !errors.Is(err, database.ErrorLastOwner) {
+		t.Errorf("Want %v, got %v", database.ErrorLastOwner, err)
+	}
+	if err := db.Teams.RemoveMember(ctx, project.ID, owner.ID); !errors.Is(err, database.ErrorLastOwner) {
+		t.Errorf("Want %v, got %v", database.ErrorLastOwner, err)
+	}
+	db.Teams.SetRole(ctx, project.ID, invitee.ID, database.TeamOwner)
+	if err := db.Teams.RemoveMember(ctx, project.ID, owner.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if ok, _ := db.Teams.HasPermission(ctx, project.ID, invitee.ID, database.PermissionTeamManage); !ok {
+		t.Errorf("Want new owner to manage the team")
+	}
+
+	// Site rolleri ekip dışındaki projelerde sadece moderasyon yetkisi verir
+	staff, _ := db.Users.FindByUsername(ctx, "reviewer3")
+	if _, err := db.Roles.SetRoles(ctx, staff.ID, []string{database.RoleEditor, database.RoleModerator}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	defer db.Roles.SetRoles(ctx, staff.ID, nil)
+	for _, permission := range []string{database.PermissionProjectEdit, database.PermissionChapterCreate, database.PermissionChapterDelete} {
+		if ok, _ := db.Teams.HasPermission(ctx, project.ID, staff.ID, permission); ok {
+			t.Errorf("Want site editor without membership denied %s", permission)
+		}
+	}
+	if ok, _ := db.Teams.HasPermission(ctx, project.ID, staff.ID, database.PermissionCommentModerate); !ok {
+		t.Errorf("Want moderators to moderate every project")
+	}
+}
+
+// Sahibi olmayan eski projeler yazar adına uyan kullanıcıya verilir, bir kere
+func TestMigrateProjectOwners(t *testing.T) {
+	var projects []database.Project
+	for _, author := range []string{"Test", "latecomer"} {
+		p, err := db.Projects.Add(ctx, database.Project{Title: "Ownerless by " + author, Synopsis: strings.Repeat("a project from before teams ", 3), Author: author, Status: "ongoing"}, "")
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		defer db.Projects.Delete(ctx, p)
+		projects = append(projects, p)
+	}
+	if err := db.DB.Exec("DELETE FROM settings WHERE key = ?", "migration.project_owners").Error; err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := database.New("sqlite", "test.db", &gorm.Config{}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	member, err := db.Teams.Member(ctx, projects[0].ID, user.ID)
+	if err != nil || member.Role != database.TeamOwner {
+		t.Errorf("Want %s owner of %s, got %+v: %v", user.Username, projects[0].Slug, member, err)
+	}
+	if err := db.Users.Add(ctx, database.User{Username: "latecomer", Email: "latecomer@gmail.com", Name: "Latecomer", Password: "password"}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := database.New("sqlite", "test.db", &gorm.Config{}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if members, _ := db.Teams.Members(ctx, projects[1].ID); len(members) != 0 {
+		t.Errorf("Want no owner for a user registered after the migration, got %+v", members)
+	}
+}
+
+func TestSlugRedirects(t *testing.T) {
+	renamed, chapters, err := db.Projects.AddWithChapters(ctx, database.Project{
+		Title:    "Old Name",
+		Synopsis: strings.Repeat("a project that gets renamed ", 3),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, []database.Chapter{{Title: "Old Chapter", Content: strings.Repeat("chapter content ", 5)}}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	renamed.Title = "x"
+	if _, err := db.Projects.Update(ctx, renamed); !errors.Is(err, database.ErrorInvalidProject) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidProject, err)
+	}
+	renamed.Title = "New Name"
+	renamed, err = db.Projects.Update(ctx, renamed)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if renamed.Slug != "new-name" {
+		t.Errorf("Want new-name, got %s", renamed.Slug)
+	}
+	if found, err := db.Projects.FindByOldSlug(ctx, "old-name"); err != nil || found.ID != renamed.ID {
+		t.Errorf("Want old-name redirected to %s, got %v", renamed.Slug, err)
+	}
+	// Eski slug başka projeye verilmez
+	other, err := db.Projects.Add(ctx, database.Project{Title: "Old Name", Synopsis: renamed.Synopsis, Author: "Someone", Status: "ongoing"}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if other.Slug != "old-name-2" {
+		t.Errorf("Want old-name-2, got %s", other.Slug)
+	}
+	renamed.Title = "Old Name"
+	renamed, _ = db.Projects.Update(ctx, renamed)
+	if renamed.Slug != "old-name" {
+		t.Errorf("Want old-name back, got %s", renamed.Slug)
+	}
+	if found, err := db.Projects.FindByOldSlug(ctx, "new-name"); err != nil || found.ID != renamed.ID {
+		t.Errorf("Want new-name redirected to old-name, got %v", err)
+	}
+
+	oldChapter := chapters[0]
+	oldChapter.Title = "New Chapter"
+	revised, err := db.Chapters.Revise(ctx, oldChapter, &user.ID, "rename")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if found, err := db.Chapters.FindByOldSlug(ctx, "old-chapter"); err != nil || found.Slug != revised.Slug {
+		t.Errorf("Want old-chapter redirected to %s, got %v", revised.Slug, err)
+	}
+
+	// Slug bulunduktan sonra başka bir istek aynı slug'la kayıt eklerse Add yeni slug'la tekrar dener
+	stolen := false
+	steal := func(tx *gorm.DB) {
+		pending, ok := tx.Statement.Dest.(*database.Project)
+		if stolen || !ok || pending.Title != "Racing Title" {
+			return
+		}
+		stolen = true
+		thief := database.Project{ID: uuid.NewString(), Slug: pending.Slug, Title: pending.Title, Synopsis: pending.Synopsis, Author: "Someone", Status: "ongoing"}
+		if err := tx.Session(&gorm.Session{NewDB: true}).Create(&thief).Error; err != nil {
+			t.Errorf("[ERROR] -> %v", err)
+		}
+	}
+	if err := db.DB.Callback().Create().Before("gorm:create").Register("test:steal_slug", steal); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	racing, err := db.Projects.Add(ctx, database.Project{Title: "Racing Title", Synopsis: renamed.Synopsis, Author: "Someone", Status: "ongoing"}, "")
+	db.DB.Callback().Create().Remove("test:steal_slug")
+	if err != nil || !stolen || racing.Slug != "racing-title" {
+		t.Errorf("Want racing-title after a retry, got %s (stolen %v): %v", racing.Slug, stolen, err)
+	}
+
+	if err := db.Projects.Delete(ctx, renamed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Chapters.FindBySlug(ctx, revised.Slug); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want chapters deleted with project, got %v", err)
+	}
+	if _, err := db.Projects.FindByOldSlug(ctx, "new-name"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want no redirect to deleted project, got %v", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	trashed, chapters, err := db.Projects.AddWithChapters(ctx, database.Project{
+		Title:    "Trashed Project",
+		Synopsis: strings.Repeat("a project that goes to the trash ", 3),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, []database.Chapter{
+		{Title: "Deleted Earlier", Content: strings.Repeat("chapter content ", 5)},
+		{Title: "Deleted With Project", Content: strings.Repeat("chapter content ", 5)},
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	comment, err := db.Comments.Add(ctx, database.Comment{Content: "on trashed", UserID: user.ID, ProjectID: &trashed.ID, ChapterID: &chapters[1].ID})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Chapters.Delete(ctx, chapters[0]); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := db.Projects.Delete(ctx, trashed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+
+	if _, err := db.Trash.List(ctx, "volumes", 10, 0); !errors.Is(err, database.ErrorInvalidTrashKind) {
+		t.Errorf("Want %v, got %v", database.ErrorInvalidTrashKind, err)
+	}
+	items, err := db.Trash.List(ctx, database.TrashChapters, 10, 0)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	var listed []string
+	for _, item := range items {
+		if item.Parent == trashed.Title {
+			listed = append(listed, item.ID)
+		}
+	}
+	if len(listed) != 1 || listed[0] != chapters[0].ID {
+		t.Errorf("Want only the chapter deleted on its own, got %+v", items)
+	}
+	if err := db.Trash.Restore(ctx, database.TrashChapters, chapters[0].ID); !errors.Is(err, database.ErrorParentDeleted) {
+		t.Errorf("Want %v, got %v", database.ErrorParentDeleted, err)
+	}
+	if err := db.Trash.Restore(ctx, database.TrashProjects, trashed.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Chapters.Find(ctx, chapters[1].ID); err != nil {
+		t.Errorf("Want chapter restored with project, got %v", err)
+	}
+	if _, err := db.Chapters.Find(ctx, chapters[0].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want earlier deleted chapter to stay in trash, got %v", err)
+	}
+	if err := db.Trash.Restore(ctx, database.TrashProjects, trashed.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want restored project out of trash, got %v", err)
+	}
+
+	if err := db.Trash.Purge(ctx, database.TrashChapters, chapters[1].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want only trashed rows purged, got %v", err)
+	}
+	if err := db.Trash.Purge(ctx, database.TrashChapters, chapters[0].ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Trash.Restore(ctx, database.TrashChapters, chapters[0].ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want purged chapter gone, got %v", err)
+	}
+
+	db.Projects.Delete(ctx, trashed)
+	if purged, err := db.Trash.PurgeOlderThan(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Errorf("Want nothing older than an hour purged, got %d, %v", purged, err)
+	}
+	if _, err := db.Trash.PurgeOlderThan(ctx, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Comments.Find(ctx, comment.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want comments purged with project, got %v", err)
+	}
+	if items, _ := db.Trash.List(ctx, database.TrashProjects, 10, 0); len(items) != 0 {
+		t.Errorf("Want empty trash, got %+v", items)
+	}
+}
+
+func TestPurgeStopsAtFirstError(t *testing.T) {
+	trashed, err := db.Projects.Add(ctx, database.Project{
+		Title:    "Half Purged Project",
+		Synopsis: strings.Repeat("a project whose purge fails halfway ", 3),
+		Author:   "Someone",
+		Status:   "ongoing",
+	}, "")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Projects.Delete(ctx, trashed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	failed := errors.New("reviews are locked")
+	stopped, after := false, []string{}
+	fail := func(tx *gorm.DB) {
+		if stopped {
+			after = append(after, tx.Statement.Table)
+		} else if tx.Statement.Table == "reviews" {
+			stopped = true
+			tx.AddError(failed)
+		}
+	}
+	if err := db.DB.Callback().Delete().Before("gorm:delete").Register("test:fail_reviews", fail); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	err = db.Trash.Purge(ctx, database.TrashProjects, trashed.ID)
+	db.DB.Callback().Delete().Remove("test:fail_reviews")
+	if !errors.Is(err, failed) {
+		t.Errorf("Want %v, got %v", failed, err)
+	}
+	if len(after) != 0 {
+		t.Errorf("Want no statements after the failed step, got %v", after)
+	}
+	if err := db.Trash.Purge(ctx, database.TrashProjects, trashed.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+}
+
+func TestUserTokens(t *testing.T) {
+	secret := []byte("secret")
+	token, id, err := authentication.NewSignedToken(secret, database.TokenResetPassword, "hash-1")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if got, ok := authentication.SignedTokenID(token); !ok || got != id {
+		t.Errorf("Want id %s, got %s", id, got)
+	}
+	if !authentication.CheckSignedToken(secret, database.TokenResetPassword, token, "hash-1") {
+		t.Errorf("Want token valid")
+	}
+	// Şifre değişince ya da başka amaçla kullanılınca imza tutmaz
+	if authentication.CheckSignedToken(secret, database.TokenResetPassword, token, "hash-2") ||
+		authentication.CheckSignedToken(secret, database.TokenVerifyEmail, token, "hash-1") ||
+		authentication.CheckSignedToken([]byte("other"), database.TokenResetPassword, token, "hash-1") {
+		t.Errorf("Want token rejected with different binding, purpose or secret")
+	}
+
+	now := time.Now()
+	first, err := db.Tokens.Create(ctx, database.UserToken{ID: id, UserID: user.ID, Purpose: database.TokenResetPassword, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	second, _ := db.Tokens.Create(ctx, database.UserToken{ID: id + "-2", UserID: user.ID, Purpose: database.TokenResetPassword, ExpiresAt: now.Add(time.Hour)})
+	db.Tokens.Create(ctx, database.UserToken{ID: id + "-3", UserID: user.ID, Purpose: database.TokenVerifyEmail, ExpiresAt: now.Add(time.Hour)})
+	if _, err := db.Tokens.Create(ctx, database.UserToken{ID: id + "-4", UserID: user.ID, Purpose: database.TokenVerifyEmail, ExpiresAt: now.Add(-time.Hour)}); !errors.Is(err, database.ErrorInvalidToken) {
+		t.Errorf("Want expired token rejected, got %v", err)
+	}
+	if count, _ := db.Tokens.CountSince(ctx, user.ID, database.TokenResetPassword, now.Add(-time.Minute)); count != 2 {
+		t.Errorf("Want 2 reset tokens, got %d", count)
+	}
+	if _, err := db.Tokens.Find(ctx, database.TokenVerifyEmail, first.ID); !errors.Is(err, database.ErrorInvalidToken) {
+		t.Errorf("Want token not found for another purpose, got %v", err)
+	}
+	found, err := db.Tokens.Find(ctx, database.TokenResetPassword, first.ID)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Tokens.Use(ctx, found); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.Tokens.Use(ctx, found); !errors.Is(err, database.ErrorInvalidToken) {
+		t.Errorf("Want token single use, got %v", err)
+	}
+	// Kullanılan token aynı amaçla verilmiş diğerlerini de geçersiz kılar
+	if _, err := db.Tokens.Find(ctx, database.TokenResetPassword, second.ID); !errors.Is(err, database.ErrorInvalidToken) {
+		t.Errorf("Want older reset token invalidated, got %v", err)
+	}
+	if _, err := db.Tokens.Find(ctx, database.TokenVerifyEmail, id+"-3"); err != nil {
+		t.Errorf("Want verification token still valid, got %v", err)
+	}
+	if purged, _ := db.Tokens.Purge(ctx, now.Add(2*time.Hour)); purged != 3 {
+		t.Errorf("Want 3 tokens purged, got %d", purged)
+	}
+}
+
+func TestSetPassword(t *testing.T) {
+	u := database.User{Password: "old"}
+	if err := u.SetPassword("new-password"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("new-password")) != nil {
+		t.Errorf("Want password hashed from argument")
+	}
+}
+
+func TestTwoFactor(t *testing.T) {
+	if _, err := db.Roles.SetRoles(ctx, user.ID, []string{database.RoleEditor, database.RoleTranslator}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	defer db.Roles.SetRoles(ctx, user.ID, []string{database.RoleTranslator})
+	if err := db.Settings.Set(ctx, database.SettingRequireStaffTwoFactor, "true"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	defer db.Settings.Set(ctx, database.SettingRequireStaffTwoFactor, "false")
+
+	// Politika açıkken staff rolleri yok sayılır, diğer roller geçerli kalır
+	if pending, _ := db.Roles.TwoFactorPending(ctx, user.ID); !pending {
+		t.Errorf("Want two-factor pending for editor")
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionChapterPublish); ok {
+		t.Errorf("Want editor permissions dropped until two-factor is enabled")
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionProjectCreate); !ok {
+		t.Errorf("Want translator permissions kept")
+	}
+
+	if err := db.TwoFactor.Setup(ctx, user.ID, "SECRET"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if enabled, _ := db.TwoFactor.IsEnabled(ctx, user.ID); enabled {
+		t.Errorf("Want two-factor disabled until confirmed")
+	}
+	if err := db.TwoFactor.Enable(ctx, user.ID, 100, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.TwoFactor.Setup(ctx, user.ID, "OTHER"); !errors.Is(err, database.ErrorTwoFactorEnabled) {
+		t.Errorf("Want %v, got %v", database.ErrorTwoFactorEnabled, err)
+	}
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionChapterPublish); !ok {
+		t.Errorf("Want editor permissions back after enabling two-factor")
+	}
+
+	if err := db.TwoFactor.UseStep(ctx, user.ID, 100); !errors.Is(err, database.ErrorInvalidCode) {
+		t.Errorf("Want used step rejected, got %v", err)
+	}
+	if err := db.TwoFactor.UseStep(ctx, user.ID, 101); err != nil {
+		t.Errorf("Want next step accepted, got %v", err)
+	}
+	if err := db.TwoFactor.UseRecoveryCode(ctx, user.ID, "hash-a"); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if err := db.TwoFactor.UseRecoveryCode(ctx, user.ID, "hash-a"); !errors.Is(err, database.ErrorInvalidCode) {
+		t.Errorf("Want recovery code single use, got %v", err)
+	}
+	if left, _ := db.TwoFactor.RecoveryCodesLeft(ctx, user.ID); left != 1 {
+		t.Errorf("Want 1 recovery code left, got %d", left)
+	}
+	db.TwoFactor.ReplaceRecoveryCodes(ctx, user.ID, []string{"hash-c"})
+	if err := db.TwoFactor.UseRecoveryCode(ctx, user.ID, "hash-b"); !errors.Is(err, database.ErrorInvalidCode) {
+		t.Errorf("Want old recovery codes replaced, got %v", err)
+	}
+
+	if err := db.TwoFactor.Disable(ctx, user.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if left, _ := db.TwoFactor.RecoveryCodesLeft(ctx, user.ID); left != 0 {
+		t.Errorf("Want recovery codes deleted, got %d", left)
+	}
+	db.Settings.Set(ctx, database.SettingRequireStaffTwoFactor, "false")
+	if ok, _ := db.Roles.HasPermission(ctx, user.ID, database.PermissionChapterPublish); !ok {
+		t.Errorf("Want editor permissions without policy")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	secret, prefix, hash, err := authentication.NewAPIKey()
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if !strings.HasPrefix(secret, prefix) || hash != authentication.HashToken(secret) {
+		t.Errorf("Want prefix and hash of %s, got %s %s", secret, prefix, hash)
+	}
+	key := database.APIKey{UserID: user.ID, Name: "uploader", Prefix: prefix, KeyHash: hash, Scopes: "chapter.create,chapter.edit"}
+	if _, err := db.APIKeys.Create(ctx, database.APIKey{UserID: user.ID, Name: "admin", Prefix: "bn_x", KeyHash: "x", Scopes: database.PermissionUserManage}); !errors.Is(err, database.ErrorInvalidAPIKey) {
+		t.Errorf("Want %s scope rejected, got %v", database.PermissionUserManage, err)
+	}
+	key, err = db.APIKeys.Create(ctx, key)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if !key.HasScope(database.PermissionChapterCreate) || key.HasScope(database.PermissionChapterPublish) {
+		t.Errorf("Unexpected scopes %v", key.ScopeList())
+	}
+	found, err := db.APIKeys.FindByHash(ctx, hash)
+	if err != nil || found.ID != key.ID {
+		t.Fatalf("Want key %s, got %s %v", key.ID, found.ID, err)
+	}
+	now := time.Now()
+	db.APIKeys.Touch(ctx, found, now)
+	found, _ = db.APIKeys.FindByHash(ctx, hash)
+	if found.LastUsedAt == nil {
+		t.Errorf("Want last used time set")
+	}
+
+	past := now.Add(-time.Hour)
+	db.DB.Model(&database.APIKey{}).Where("id = ?", key.ID).Update("expires_at", past)
+	if _, err := db.APIKeys.FindByHash(ctx, hash); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want expired key rejected, got %v", err)
+	}
+	db.DB.Model(&database.APIKey{}).Where("id = ?", key.ID).Update("expires_at", nil)
+
+	if err := db.APIKeys.Revoke(ctx, "someone-else", key.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want other users unable to revoke, got %v", err)
+	}
+	if err := db.APIKeys.Revoke(ctx, user.ID, key.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.APIKeys.FindByHash(ctx, hash); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want revoked key rejected, got %v", err)
+	}
+	if keys, _ := db.APIKeys.List(ctx, user.ID); len(keys) != 0 {
+		t.Errorf("Want revoked key hidden, got %+v", keys)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	if found, err := authentication.Authenticate(user.Username, "test", db.Users); err != nil || found.ID != user.ID {
+		t.Errorf("Want %s authenticated, got %v", user.ID, err)
+	}
+	// Yanlış şifre ve olmayan kullanıcı aynı hatayı vermeli
+	if _, err := authentication.Authenticate(user.Username, "wrong", db.Users); !errors.Is(err, authentication.ErrorInvalidCredentials) {
+		t.Errorf("Want %v, got %v", authentication.ErrorInvalidCredentials, err)
+	}
+	if _, err := authentication.Authenticate("nobody-here", "wrong", db.Users); !errors.Is(err, authentication.ErrorInvalidCredentials) {
+		t.Errorf("Want %v, got %v", authentication.ErrorInvalidCredentials, err)
+	}
+}
+
+func TestLoginLocks(t *testing.T) {
+	policy := database.LockoutPolicy{FreeAttempts: 3, BaseLockout: time.Minute, MaxLockout: 5 * time.Minute, Window: time.Hour}
+	for failures, want := range map[int]time.Duration{3: 0, 4: time.Minute, 5: 2 * time.Minute, 6: 4 * time.Minute, 7: 5 * time.Minute, 60: 5 * time.Minute} {
+		if got := policy.Lockout(failures); got != want {
+			t.Errorf("Want %v lockout after %d failures, got %v", want, failures, got)
+		}
+	}
+
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		lock, err := db.LoginLocks.Fail(ctx, "user:test", policy, now)
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		if lock.Failures != i || lock.LockedUntil != nil {
+			t.Errorf("Want %d failures without lock, got %+v", i, lock)
+		}
+	}
+	if until, _ := db.LoginLocks.LockedUntil(ctx, now, "user:test", "ip:127.0.0.1"); !until.IsZero() {
+		t.Errorf("Want no lock, got %v", until)
+	}
+	lock, err := db.LoginLocks.Fail(ctx, "user:test", policy, now)
+	if err != nil || lock.LockedUntil == nil || !lock.LockedUntil.Equal(now.Add(time.Minute)) {
+		t.Errorf("Want locked for a minute, got %+v %v", lock, err)
+	}
+	if until, _ := db.LoginLocks.LockedUntil(ctx, now, "ip:127.0.0.1", "user:test"); !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("Want locked until %v, got %v", now.Add(time.Minute), until)
+	}
+	if until, _ := db.LoginLocks.LockedUntil(ctx, now.Add(2*time.Minute), "user:test"); !until.IsZero() {
+		t.Errorf("Want lock expired, got %v", until)
+	}
+	if locks, _ := db.LoginLocks.ListLocked(ctx, now); len(locks) != 1 || locks[0].Key != "user:test" {
+		t.Errorf("Want one active lock, got %+v", locks)
+	}
+
+	// Window boyunca hata olmazsa sayaç baştan başlar
+	lock, _ = db.LoginLocks.Fail(ctx, "user:test", policy, now.Add(2*time.Hour))
+	if lock.Failures != 1 || lock.LockedUntil.After(now.Add(2*time.Hour)) {
+		t.Errorf("Want counter reset after window, got %+v", lock)
+	}
+
+	if removed, err := db.LoginLocks.Reset(ctx, "user:test"); err != nil || removed != 1 {
+		t.Errorf("Want lock removed, got %d %v", removed, err)
+	}
+	db.LoginLocks.Fail(ctx, "ip:127.0.0.1", policy, now.Add(-2*time.Hour))
+	if purged, err := db.LoginLocks.Purge(ctx, now.Add(-time.Hour)); err != nil || purged != 1 {
+		t.Errorf("Want old counter purged, got %d %v", purged, err)
+	}
+}
+
+func TestAuditEvents(t *testing.T) {
+	events := []database.AuditEvent{
+		{Action: database.AuditLoginFailed, UserID: user.ID, Subject: "test", IP: "10.0.0.1"},
+		{Action: database.AuditLoginFailed, Subject: "ghost", IP: "10.0.0.2"},
+		{Action: database.AuditLoginLocked, UserID: user.ID, Subject: "user:test", IP: "10.0.0.1"},
+	}
+	for _, event := range events {
+		if err := db.Audit.Add(ctx, event); err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+	}
+	if err := db.Audit.Add(ctx, database.AuditEvent{}); err == nil {
+		t.Errorf("Want event without action rejected")
+	}
+	if found, _ := db.Audit.List(ctx, database.AuditFilter{Action: database.AuditLoginFailed}, 50, 0); len(found) != 2 {
+		t.Errorf("Want 2 failed logins, got %d", len(found))
+	}
+	if found, _ := db.Audit.List(ctx, database.AuditFilter{Subjects: []string{"ghost", "user:ghost"}}, 50, 0); len(found) != 1 || found[0].IP != "10.0.0.2" {
+		t.Errorf("Want attempt for unknown username, got %+v", found)
+	}
+	found, _ := db.Audit.List(ctx, database.AuditFilter{UserID: user.ID, Subjects: []string{"test"}, IP: "10.0.0.1"}, 50, 0)
+	if len(found) != 2 || found[0].Action != database.AuditLoginLocked {
+		t.Errorf("Want 2 events for user newest first, got %+v", found)
+	}
+	if purged, err := db.Audit.Purge(ctx, time.Now().Add(time.Minute)); err != nil || purged != 3 {
+		t.Errorf("Want 3 events purged, got %d %v", purged, err)
+	}
+}
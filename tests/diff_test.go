@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/batt0s/batnovels/diff"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ one
+-two
++2
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if got := diff.Unified("a", "b", a, b, 3); got != want {
+		t.Errorf("Want:\n%s\nGot:\n%s", want, got)
+	}
+	if got := diff.Unified("a", "b", a, a, 3); got != "" {
+		t.Errorf("Want empty diff for equal texts, got %q", got)
+	}
+}
+
+// Baştan ve sondan değişen uzun bir metin, n*m tablo gerekseydi gigabaytlarca bellek isterdi
+func TestUnifiedDiffLargeInput(t *testing.T) {
+	lines := make([]string, 50000)
+	for i := range lines {
+		lines[i] = "line " + strconv.Itoa(i)
+	}
+	a := strings.Join(lines, "\n") + "\n"
+	changed := append([]string{"first"}, lines[1:len(lines)-1]...)
+	changed = append(changed, "last")
+	b := strings.Join(changed, "\n") + "\n"
+
+	got := diff.Unified("a", "b", a, b, 1)
+	want := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-line 0\n+first\n line 1\n@@ -49999,2 +49999,2 @@\n line 49998\n-line 49999\n+last\n"
+	if got != want {
+		t.Errorf("Want:\n%s\nGot:\n%s", want, got)
+	}
+}
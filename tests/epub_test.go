@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/batt0s/batnovels/epub"
+)
+
+func TestEpubWrite(t *testing.T) {
+	book := epub.Book{
+		Identifier:  "urn:uuid:4f6f0a5e-6a0e-4a55-9d3c-2f1f5b8c8f11",
+		Title:       "Tom & Jerry <Chronicles>",
+		Author:      "Tester",
+		Description: "A synopsis.",
+		Subjects:    []string{"fantasy", "action"},
+		Cover:       &epub.Image{Data: []byte("\x89PNG\r\n\x1a\n"), MediaType: "image/png"},
+		Chapters: []epub.Chapter{
+			{Title: "One", Content: "First paragraph.\n\nSecond \x01paragraph."},
+			{Title: "Two", Content: "Another chapter."},
+		},
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if archive.File[0].Name != "mimetype" || archive.File[0].Method != zip.Store {
+		t.Errorf("Want stored mimetype as first entry, got %s", archive.File[0].Name)
+	}
+	names := map[string]bool{}
+	for _, file := range archive.File {
+		names[file.Name] = true
+		if !strings.HasSuffix(file.Name, ".xhtml") && !strings.HasSuffix(file.Name, ".opf") &&
+			!strings.HasSuffix(file.Name, ".ncx") && !strings.HasSuffix(file.Name, ".xml") {
+			continue
+		}
+		reader, _ := file.Open()
+		decoder := xml.NewDecoder(reader)
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s is not well-formed: %v", file.Name, err)
+				break
+			}
+		}
+		reader.Close()
+	}
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml",
+		"OEBPS/chapter-001.xhtml", "OEBPS/chapter-002.xhtml", "OEBPS/cover.png"} {
+		if !names[name] {
+			t.Errorf("Missing %s", name)
+		}
+	}
+}
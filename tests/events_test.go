@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/events"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := events.NewHub(2)
+	sub, missed, complete := hub.Subscribe("alice", "")
+	if len(missed) != 0 || !complete {
+		t.Fatalf("Want fresh subscription, got %d missed", len(missed))
+	}
+	first := hub.Publish("alice", "new_chapter", []byte(`{"n":1}`))
+	hub.Publish("bob", "new_chapter", []byte(`{}`))
+	if event := <-sub.C; event.ID != first.ID || string(event.Data) != `{"n":1}` {
+		t.Errorf("Want %s delivered, got %+v", first.ID, event)
+	}
+	select {
+	case event := <-sub.C:
+		t.Errorf("Want only alice's events, got %+v", event)
+	default:
+	}
+	sub.Close()
+
+	second := hub.Publish("alice", "comment_reply", nil)
+	third := hub.Publish("alice", "project_status", nil)
+	sub, missed, complete = hub.Subscribe("alice", first.ID)
+	if !complete || len(missed) != 2 || missed[0].ID != second.ID || missed[1].ID != third.ID {
+		t.Errorf("Want %s and %s replayed, got %+v", second.ID, third.ID, missed)
+	}
+	sub.Close()
+
+	// Geçmiş iki olay tutuyor, first'ten sonrakilerden biri düştü
+	hub.Publish("alice", "new_chapter", nil)
+	sub, missed, complete = hub.Subscribe("alice", first.ID)
+	if complete || len(missed) != 2 {
+		t.Errorf("Want incomplete replay of 2 events, got complete=%v with %d", complete, len(missed))
+	}
+	if _, _, complete := hub.Subscribe("alice", "otherepoch-1"); complete {
+		t.Errorf("Want resync after restart")
+	}
+
+	hub.Close()
+	if _, ok := <-sub.C; ok {
+		t.Errorf("Want subscription closed on hub close")
+	}
+}
+
+func TestEventHubSweep(t *testing.T) {
+	hub := events.NewHub(10)
+	old := hub.Publish("carol", "new_chapter", nil)
+	time.Sleep(5 * time.Millisecond)
+	cutoff := time.Now()
+	kept := hub.Publish("dave", "new_chapter", nil)
+	if swept := hub.Sweep(cutoff); swept != 1 {
+		t.Errorf("Want 1 event swept, got %d", swept)
+	}
+	_, missed, complete := hub.Subscribe("carol", old.ID)
+	if !complete || len(missed) != 0 {
+		t.Errorf("Want nothing missed after %s, got complete=%v with %d", old.ID, complete, len(missed))
+	}
+	// Silinen olaydan önceki bir id ile bağlanan istemci eksik kalmış olabilir
+	if _, _, complete := hub.Subscribe("dave", strings.Replace(kept.ID, "-2", "-0", 1)); complete {
+		t.Errorf("Want resync when an older event might have been swept")
+	}
+	if _, missed, _ := hub.Subscribe("dave", strings.Replace(kept.ID, "-2", "-1", 1)); len(missed) != 1 || missed[0].ID != kept.ID {
+		t.Errorf("Want %s kept after sweep, got %+v", kept.ID, missed)
+	}
+	hub.Close()
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/feed"
+)
+
+func testFeed() feed.Feed {
+	updated := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return feed.Feed{
+		ID:       "urn:uuid:project",
+		Title:    "Dragons & Co",
+		Link:     "https://example.com/project/dragons",
+		SelfLink: "https://example.com/feeds/project/dragons.atom",
+		Author:   "Someone",
+		Updated:  updated,
+		Entries: []feed.Entry{{
+			ID:        "urn:uuid:chapter",
+			Title:     "Chapter <1>",
+			Link:      "https://example.com/chapter/one",
+			Summary:   "It begins.",
+			Published: updated.Add(-time.Hour),
+			Updated:   updated,
+		}},
+	}
+}
+
+func TestAtomFeed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteAtom(&buf); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	var parsed struct {
+		Title   string `xml:"title"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			Title     string `xml:"title"`
+			Published string `xml:"published"`
+			Summary   string `xml:"summary"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if parsed.Title != "Dragons & Co" || parsed.Updated != "2024-05-01T12:00:00Z" {
+		t.Errorf("Want feed header, got %+v", parsed)
+	}
+	if len(parsed.Entries) != 1 || parsed.Entries[0].Title != "Chapter <1>" || parsed.Entries[0].Published != "2024-05-01T11:00:00Z" {
+		t.Errorf("Want one escaped entry, got %+v", parsed.Entries)
+	}
+}
+
+func TestRSSFeed(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testFeed().WriteRSS(&buf); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if !strings.Contains(buf.String(), "<pubDate>Wed, 01 May 2024 11:00:00 +0000</pubDate>") {
+		t.Errorf("Want RFC 1123 pubDate, got:\n%s", buf.String())
+	}
+	var parsed struct {
+		Channel struct {
+			Items []struct {
+				GUID string `xml:"guid"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(parsed.Channel.Items) != 1 || parsed.Channel.Items[0].GUID != "urn:uuid:chapter" {
+		t.Errorf("Want one item with guid, got %+v", parsed.Channel.Items)
+	}
+}
@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/batt0s/batnovels/epub"
+	"github.com/batt0s/batnovels/importer"
+)
+
+func TestImportEpub(t *testing.T) {
+	book := epub.Book{
+		Identifier:  "urn:uuid:8d1f3c2e-1b7a-4f7e-9a44-3c2b1d0e9f00",
+		Title:       "Imported Novel",
+		Author:      "Someone",
+		Description: "<p>An imported synopsis.</p>",
+		Subjects:    []string{"drama", "romance"},
+		Chapters: []epub.Chapter{
+			{Title: "Prologue", Content: "It begins.\nIt continues."},
+			{Title: "Chapter 1", Content: "The story goes on."},
+		},
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	draft, err := importer.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if draft.Project.Title != book.Title || draft.Project.Author != book.Author {
+		t.Errorf("Want %s by %s, got %+v", book.Title, book.Author, draft.Project)
+	}
+	if draft.Project.Synopsis != "An imported synopsis." || draft.Project.Tags != "drama,romance" {
+		t.Errorf("Unexpected metadata %+v", draft.Project)
+	}
+	if len(draft.Chapters) != 2 {
+		t.Fatalf("Want 2 chapters, got %d", len(draft.Chapters))
+	}
+	if draft.Chapters[0].Title != "Prologue" || draft.Chapters[0].Content != "It begins.\n\nIt continues." {
+		t.Errorf("Unexpected first chapter %+v", draft.Chapters[0])
+	}
+}
+
+func TestImportMarkdown(t *testing.T) {
+	files := map[string]string{
+		"novel/project.md":    "---\ntitle: \"Markdown Novel\"\nauthor: Writer\ntags: [a, b]\n---\nThe synopsis.\n",
+		"novel/chapter-10.md": "---\ntitle: Ten\n---\nTenth.",
+		"novel/chapter-2.md":  "# Two\nSecond.",
+		"novel/side.md":       "---\norder: 2.5\n---\nSide story.",
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, _ := archive.Create(name)
+		file.Write([]byte(content))
+	}
+	archive.Close()
+
+	draft, err := importer.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if draft.Project.Title != "Markdown Novel" || draft.Project.Tags != "a,b" || draft.Project.Synopsis != "The synopsis." {
+		t.Errorf("Unexpected project %+v", draft.Project)
+	}
+	var titles []string
+	for _, chapter := range draft.Chapters {
+		titles = append(titles, chapter.Title)
+	}
+	if strings.Join(titles, ",") != "Two,side,Ten" {
+		t.Errorf("Want Two,side,Ten got %v", titles)
+	}
+	if draft.Chapters[0].Content != "Second." {
+		t.Errorf("Want heading stripped from content, got %q", draft.Chapters[0].Content)
+	}
+}
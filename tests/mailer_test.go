@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/mailer"
+)
+
+func TestMailerBuild(t *testing.T) {
+	msg, err := mailer.VerifyEmail.Render("reader@example.com", map[string]string{
+		"Name":    "Ayşe <script>",
+		"Link":    "https://example.com/verify-email?token=a.b",
+		"Expires": "48 hours",
+	})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if strings.Contains(msg.HTML, "<script>") || !strings.Contains(msg.Text, "Ayşe <script>") {
+		t.Errorf("Want HTML escaped and text left alone, got:\n%s\n%s", msg.HTML, msg.Text)
+	}
+	msg.Subject = "Doğrulama"
+	data, err := mailer.Build("BatNovels <noreply@example.com>", msg, time.Now())
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != "Doğrulama" || parsed.Header.Get("To") != "<reader@example.com>" {
+		t.Errorf("Want decoded headers, got %q %q", subject, parsed.Header.Get("To"))
+	}
+	media, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || media != "multipart/alternative" {
+		t.Fatalf("Want multipart/alternative, got %q %v", media, err)
+	}
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var kinds []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("[ERROR] -> %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		kind, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		kinds = append(kinds, kind)
+		if !strings.Contains(string(body), "https://example.com/verify-email?token=a.b") {
+			t.Errorf("Want link in %s part, got:\n%s", kind, body)
+		}
+	}
+	if strings.Join(kinds, ",") != "text/plain,text/html" {
+		t.Errorf("Want text and html parts, got %v", kinds)
+	}
+
+	if _, err := mailer.Build("BatNovels <noreply@example.com>", mailer.Message{To: "not an address"}, time.Now()); err == nil {
+		t.Errorf("Want invalid recipient rejected")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := mailer.FileMailer{Dir: dir, From: "noreply@example.com"}
+	if err := m.Send(context.Background(), mailer.Message{To: "reader@example.com", Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Want 1 message, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	if parsed.Header.Get("Subject") != "Hi" || strings.TrimSpace(string(body)) != "Hello" {
+		t.Errorf("Want plain message, got %q %q", parsed.Header.Get("Subject"), body)
+	}
+}
@@ -0,0 +1,207 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/database"
+	"github.com/batt0s/batnovels/oidc"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"gorm.io/gorm"
+)
+
+// Discovery, JWKS ve token endpoint'i olan yerel OIDC sağlayıcısı. Token endpoint'i
+// PKCE verifier'ını ve istemci bilgilerini kontrol edip claims'i imzalı ID token olarak döner.
+type mockProvider struct {
+	*httptest.Server
+	t         *testing.T
+	key       jwk.Key
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	key, _ := jwk.FromRaw(raw)
+	key.Set(jwk.KeyIDKey, "test-key")
+	key.Set(jwk.AlgorithmKey, jwa.RS256)
+	p := &mockProvider{t: t, key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		public, _ := p.key.PublicKey()
+		set := jwk.NewSet()
+		set.AddKey(public)
+		json.NewEncoder(w).Encode(set)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "batnovels" || secret != "s3cret" || r.FormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if oidc.CodeChallenge(r.FormValue("code_verifier")) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant", "error_description": "pkce"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(p.claims), "token_type": "Bearer"})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *mockProvider) sign(claims map[string]interface{}) string {
+	token := jwt.New()
+	token.Set(jwt.IssuerKey, p.URL)
+	token.Set(jwt.AudienceKey, "batnovels")
+	token.Set(jwt.IssuedAtKey, time.Now())
+	token.Set(jwt.ExpirationKey, time.Now().Add(5*time.Minute))
+	for k, v := range claims {
+		token.Set(k, v)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, p.key))
+	if err != nil {
+		p.t.Fatalf("[ERROR] -> %v", err)
+	}
+	return string(signed)
+}
+
+func TestOIDCClient(t *testing.T) {
+	provider := newMockProvider(t)
+	defer provider.Close()
+	client := oidc.NewClient(oidc.Config{
+		Name:         "mock",
+		Issuer:       provider.URL,
+		ClientID:     "batnovels",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://batnovels.test/auth/mock/callback",
+	})
+	ctx := context.Background()
+
+	address, err := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	parsed, _ := url.Parse(address)
+	query := parsed.Query()
+	if !strings.HasPrefix(address, provider.URL+"/authorize?") || query.Get("state") != "state-1" || query.Get("nonce") != "nonce-1" ||
+		query.Get("code_challenge") != oidc.CodeChallenge("verifier-1") || query.Get("scope") != "openid email profile" {
+		t.Errorf("Unexpected authorization url %s", address)
+	}
+
+	provider.challenge = oidc.CodeChallenge("verifier-1")
+	provider.claims = map[string]interface{}{"sub": "42", "email": "reader@example.com", "email_verified": "true", "nonce": "nonce-1", "name": "Reader"}
+	if _, err := client.Exchange(ctx, "good-code", "wrong-verifier"); !errors.Is(err, oidc.ErrorExchange) {
+		t.Errorf("Want PKCE mismatch rejected, got %v", err)
+	}
+	raw, err := client.Exchange(ctx, "good-code", "verifier-1")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	claims, err := client.Verify(ctx, raw, "nonce-1")
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if claims.Subject != "42" || claims.Email != "reader@example.com" || !claims.EmailVerified || claims.Name != "Reader" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+	if _, err := client.Verify(ctx, raw, "nonce-2"); !errors.Is(err, oidc.ErrorInvalidToken) {
+		t.Errorf("Want nonce mismatch rejected, got %v", err)
+	}
+
+	bad := map[string]map[string]interface{}{
+		"audience": {"sub": "42", "nonce": "nonce-1", "aud": "someone-else"},
+		"issuer":   {"sub": "42", "nonce": "nonce-1", "iss": "https://evil.test"},
+		"expired":  {"sub": "42", "nonce": "nonce-1", "exp": time.Now().Add(-time.Hour)},
+		"azp":      {"sub": "42", "nonce": "nonce-1", "aud": []string{"batnovels", "other"}, "azp": "other"},
+	}
+	for name, claims := range bad {
+		if _, err := client.Verify(ctx, provider.sign(claims), "nonce-1"); !errors.Is(err, oidc.ErrorInvalidToken) {
+			t.Errorf("Want token with bad %s rejected, got %v", name, err)
+		}
+	}
+
+	// Sağlayıcının JWKS'inde olmayan anahtarla imzalanmış token
+	raw_key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	forged, _ := jwk.FromRaw(raw_key)
+	forged.Set(jwk.KeyIDKey, "test-key")
+	token := jwt.New()
+	token.Set(jwt.IssuerKey, provider.URL)
+	token.Set(jwt.AudienceKey, "batnovels")
+	token.Set(jwt.SubjectKey, "42")
+	token.Set(jwt.ExpirationKey, time.Now().Add(time.Minute))
+	token.Set("nonce", "nonce-1")
+	signed, _ := jwt.Sign(token, jwt.WithKey(jwa.RS256, forged))
+	if _, err := client.Verify(ctx, string(signed), "nonce-1"); !errors.Is(err, oidc.ErrorInvalidToken) {
+		t.Errorf("Want forged signature rejected, got %v", err)
+	}
+
+	wrong := oidc.NewClient(oidc.Config{Name: "mock", Issuer: provider.URL + "/other", ClientID: "batnovels"})
+	if _, err := wrong.Metadata(ctx); !errors.Is(err, oidc.ErrorDiscovery) {
+		t.Errorf("Want discovery for another issuer rejected, got %v", err)
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	identity, err := db.Identities.Link(ctx, database.Identity{UserID: user.ID, Provider: "mock", Subject: "42", Email: user.Email})
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	again, err := db.Identities.Link(ctx, database.Identity{UserID: user.ID, Provider: "mock", Subject: "42"})
+	if err != nil || again.ID != identity.ID {
+		t.Errorf("Want existing identity %s, got %s %v", identity.ID, again.ID, err)
+	}
+	if _, err := db.Identities.Link(ctx, database.Identity{UserID: "someone-else", Provider: "mock", Subject: "42"}); !errors.Is(err, database.ErrorIdentityLinked) {
+		t.Errorf("Want %v, got %v", database.ErrorIdentityLinked, err)
+	}
+	if found, err := db.Identities.Find(ctx, "mock", "42"); err != nil || found.UserID != user.ID {
+		t.Errorf("Want identity of %s, got %+v %v", user.ID, found, err)
+	}
+
+	state := database.AuthState{StateHash: "state-hash", Provider: "mock", Nonce: "n", Verifier: "v", ExpiresAt: time.Now().Add(time.Minute)}
+	if err := db.Identities.CreateState(ctx, state); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if _, err := db.Identities.TakeState(ctx, "other", "state-hash"); !errors.Is(err, database.ErrorInvalidToken) {
+		t.Errorf("Want state bound to provider, got %v", err)
+	}
+	if taken, err := db.Identities.TakeState(ctx, "mock", "state-hash"); err != nil || taken.Nonce != "n" {
+		t.Errorf("Want state taken, got %+v %v", taken, err)
+	}
+	if _, err := db.Identities.TakeState(ctx, "mock", "state-hash"); !errors.Is(err, database.ErrorInvalidToken) {
+		t.Errorf("Want state single use, got %v", err)
+	}
+
+	if err := db.Identities.Unlink(ctx, "someone-else", identity.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Want other users unable to unlink, got %v", err)
+	}
+	if err := db.Identities.Unlink(ctx, user.ID, identity.ID); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if identities, _ := db.Identities.List(ctx, user.ID); len(identities) != 0 {
+		t.Errorf("Want no identities, got %+v", identities)
+	}
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/feed"
+)
+
+func TestOPDSCatalog(t *testing.T) {
+	catalog := feed.Catalog{
+		ID:      "tag:example.com,2024:opds:latest",
+		Title:   "Latest",
+		Updated: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Links: []feed.Link{
+			{Rel: "self", Type: feed.AcquisitionType, Href: "https://example.com/opds/latest"},
+			{Rel: feed.RelSearch, Type: feed.OpenSearchType, Href: "https://example.com/opds/opensearch.xml"},
+		},
+		Entries: []feed.CatalogEntry{{
+			ID:         "urn:uuid:project",
+			Title:      "Dragons & Co",
+			Author:     "Someone",
+			Summary:    "It begins.",
+			Categories: []string{"fantasy"},
+			Published:  time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Updated:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			Links: []feed.Link{
+				{Rel: feed.RelAcquisition, Type: "application/epub+zip", Href: "https://example.com/api/project/dragons/export.epub"},
+			},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := catalog.WriteOPDS(&buf); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	var parsed struct {
+		Links []struct {
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			Title    string `xml:"title"`
+			Issued   string `xml:"http://purl.org/dc/terms/ issued"`
+			Category struct {
+				Term string `xml:"term,attr"`
+			} `xml:"category"`
+			Links []struct {
+				Rel  string `xml:"rel,attr"`
+				Type string `xml:"type,attr"`
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(parsed.Links) != 2 || parsed.Links[1].Type != feed.OpenSearchType {
+		t.Errorf("Want self and search links, got %+v", parsed.Links)
+	}
+	if len(parsed.Entries) != 1 {
+		t.Fatalf("Want one entry, got:\n%s", buf.String())
+	}
+	entry := parsed.Entries[0]
+	if entry.Title != "Dragons & Co" || entry.Issued != "2024-01-02" || entry.Category.Term != "fantasy" {
+		t.Errorf("Want entry metadata, got %+v", entry)
+	}
+	if len(entry.Links) != 1 || entry.Links[0].Rel != feed.RelAcquisition || entry.Links[0].Type != "application/epub+zip" {
+		t.Errorf("Want an EPUB acquisition link, got %+v", entry.Links)
+	}
+}
+
+func TestOpenSearchDescription(t *testing.T) {
+	var buf bytes.Buffer
+	description := feed.OpenSearchDescription{
+		ShortName: "BatNovels",
+		Template:  "https://example.com/opds/search?q={searchTerms}",
+	}
+	if err := description.Write(&buf); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if !strings.Contains(buf.String(), `xmlns="http://a9.com/-/spec/opensearch/1.1/"`) {
+		t.Errorf("Want OpenSearch namespace, got:\n%s", buf.String())
+	}
+	var parsed struct {
+		URL struct {
+			Type     string `xml:"type,attr"`
+			Template string `xml:"template,attr"`
+		} `xml:"Url"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if parsed.URL.Type != feed.AcquisitionType || parsed.URL.Template != description.Template {
+		t.Errorf("Want acquisition search template, got %+v", parsed.URL)
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batt0s/batnovels/authentication"
+)
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 ek B, SHA1 anahtarı, 8 haneli kodların son 6 hanesi
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		if got, err := authentication.TOTPCode(secret, time.Unix(unix, 0)); err != nil || got != want {
+			t.Errorf("Want %s at %d, got %s %v", want, unix, got, err)
+		}
+	}
+
+	now := time.Unix(1111111111, 0)
+	code, _ := authentication.TOTPCode(secret, now.Add(-30*time.Second))
+	if step, ok := authentication.CheckTOTP(secret, code, now); !ok || step != now.Unix()/30-1 {
+		t.Errorf("Want previous step accepted, got %d %v", step, ok)
+	}
+	code, _ = authentication.TOTPCode(secret, now.Add(-90*time.Second))
+	if _, ok := authentication.CheckTOTP(secret, code, now); ok {
+		t.Errorf("Want old code rejected")
+	}
+	if _, ok := authentication.CheckTOTP(secret, "12345", now); ok {
+		t.Errorf("Want short code rejected")
+	}
+
+	uri := authentication.TOTPURI("BatNovels", "alice", "ABC")
+	if !strings.HasPrefix(uri, "otpauth://totp/BatNovels:alice?") || !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=BatNovels") {
+		t.Errorf("Unexpected provisioning uri %s", uri)
+	}
+
+	codes, err := authentication.NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("[ERROR] -> %v", err)
+	}
+	if len(codes) != 10 || len(codes[0]) != 11 || codes[0][5] != '-' {
+		t.Errorf("Unexpected recovery codes %v", codes)
+	}
+	if authentication.NormalizeRecoveryCode(" "+strings.ToUpper(codes[0])) != strings.ReplaceAll(codes[0], "-", "") {
+		t.Errorf("Want recovery code normalized, got %s", authentication.NormalizeRecoveryCode(codes[0]))
+	}
+}